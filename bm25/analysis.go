@@ -0,0 +1,120 @@
+package bm25
+
+import (
+    "errors"
+    "sort"
+    "strings"
+    "unicode/utf8"
+)
+
+// CharFilter transforms the raw text of a document or query before it is
+// tokenized.
+type CharFilter func(text string) string
+
+// Tokenizer splits text into tokens. Any func(string) []string, such as the
+// tokenizer functions accepted by the constructors, is a Tokenizer.
+type Tokenizer func(text string) []string
+
+// TokenFilter transforms the tokens produced by a tokenizer. It may change,
+// drop or add tokens, and may modify the slice it is given in place.
+type TokenFilter func(tokens []string) []string
+
+// Analyzer turns text into the terms that are indexed and searched: the char
+// filters are applied to the text in order, the result is split by the
+// tokenizer, and the token filters are applied to the tokens in order.
+//
+// An analyzer given to a constructor with WithAnalyzer is applied to every
+// document and, through SearchText, to every query string, so that documents
+// and queries are always analyzed the same way.
+type Analyzer struct {
+    CharFilters  []CharFilter
+    Tokenizer    Tokenizer
+    TokenFilters []TokenFilter
+}
+
+// NewAnalyzer creates an analyzer with the given tokenizer and token filters
+// and no char filters.
+func NewAnalyzer(tokenizer Tokenizer, filters ...TokenFilter) *Analyzer {
+    return &Analyzer{Tokenizer: tokenizer, TokenFilters: filters}
+}
+
+// Analyze returns the terms of the given text.
+func (a *Analyzer) Analyze(text string) []string {
+    for _, filter := range a.CharFilters {
+        text = filter(text)
+    }
+    tokens := a.Tokenizer(text)
+    for _, filter := range a.TokenFilters {
+        if len(tokens) == 0 {
+            break
+        }
+        tokens = filter(tokens)
+    }
+    return tokens
+}
+
+// validate checks that the analyzer can be used.
+func (a *Analyzer) validate() error {
+    if a == nil || a.Tokenizer == nil {
+        return errors.New("analyzer must have a tokenizer")
+    }
+    for _, filter := range a.CharFilters {
+        if filter == nil {
+            return errors.New("char filter cannot be nil")
+        }
+    }
+    for _, filter := range a.TokenFilters {
+        if filter == nil {
+            return errors.New("token filter cannot be nil")
+        }
+    }
+    return nil
+}
+
+// WhitespaceTokenizer splits text around runs of Unicode white space.
+func WhitespaceTokenizer(text string) []string {
+    return strings.Fields(text)
+}
+
+// MappingCharFilter returns a char filter that replaces every occurrence of
+// each key of the mapping with its value. Where keys overlap, the one that
+// appears first in the text wins, and of those the longest.
+func MappingCharFilter(mapping map[string]string) CharFilter {
+    // strings.Replacer tries the keys in argument order, so longer keys go
+    // first.
+    keys := sortedTerms(mapping)
+    sort.SliceStable(keys, func(i, j int) bool {
+        return len(keys[i]) > len(keys[j])
+    })
+    pairs := make([]string, 0, 2*len(keys))
+    for _, from := range keys {
+        if from != "" {
+            pairs = append(pairs, from, mapping[from])
+        }
+    }
+    replacer := strings.NewReplacer(pairs...)
+    return replacer.Replace
+}
+
+// LowercaseFilter lowercases every token.
+func LowercaseFilter(tokens []string) []string {
+    for i, token := range tokens {
+        tokens[i] = strings.ToLower(token)
+    }
+    return tokens
+}
+
+// LengthFilter returns a token filter that drops the tokens shorter than min
+// or longer than max characters. A max of zero means no upper limit.
+func LengthFilter(min, max int) TokenFilter {
+    return func(tokens []string) []string {
+        kept := tokens[:0]
+        for _, token := range tokens {
+            n := utf8.RuneCountInString(token)
+            if n >= min && (max == 0 || n <= max) {
+                kept = append(kept, token)
+            }
+        }
+        return kept
+    }
+}
@@ -1,12 +1,38 @@
 package bm25
 
 import (
+    "context"
     "errors"
     "sync"
 )
 
 // GetScoresBatched returns the BM25 scores for the given query using parallel computation with batching.
+// Each goroutine scores a disjoint range of documents, so they never write to
+// the same score.
 func (b *bm25Base) GetScoresBatched(query []string, bm25 BM25, batchSize int) ([]float64, error) {
+    return b.GetScoresBatchedContext(context.Background(), query, bm25, batchSize)
+}
+
+// GetScoresBatchedContext is like GetScoresBatched but stops every goroutine
+// and returns the error of ctx once it is done.
+func (b *bm25Base) GetScoresBatchedContext(ctx context.Context, query []string, bm25 BM25, batchSize int) ([]float64, error) {
+    scorer, err := asTermScorer(bm25)
+    if err != nil {
+        return nil, err
+    }
+
+    scores, err := b.batchedScores(ctx, query, scorer, batchSize)
+    if err != nil {
+        return nil, err
+    }
+    b.addFloor(scores, nil, b.queryFloor(query, scorer))
+
+    return scores, nil
+}
+
+// batchedScores scores every document against the query with one goroutine
+// per batch of documents, without the query floor.
+func (b *bm25Base) batchedScores(ctx context.Context, query []string, scorer termScorer, batchSize int) ([]float64, error) {
     if len(query) == 0 {
         return nil, errors.New("query cannot be empty")
     }
@@ -15,53 +41,68 @@ func (b *bm25Base) GetScoresBatched(query []string, bm25 BM25, batchSize int) ([
         return nil, errors.New("batch size must be a positive integer")
     }
 
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    weights := b.queryWeights(query, scorer)
+    segs := b.segmentsSnapshot()
+
     var wg sync.WaitGroup
-    scores := make([]float64, b.corpusSize)
-    numBatches := (b.corpusSize + batchSize - 1) / batchSize
+    scores := make([]float64, b.maxDoc())
+    numBatches := (b.maxDoc() + batchSize - 1) / batchSize
+    errs := make([]error, numBatches)
     wg.Add(numBatches)
 
     for i := 0; i < numBatches; i++ {
         start := i * batchSize
-        end := Min(start+batchSize, b.corpusSize)
-        go func(start, end int) {
+        end := Min(start+batchSize, b.maxDoc())
+        go func(i, start, end int) {
             defer wg.Done()
-            for _, q := range query {
-                qFreq := make([]float64, end-start)
-                for j := start; j < end; j++ {
-                    doc := JoinTokens(b.corpus[j], " ")
-                    freq, err := CountTermFreq(q, doc, b.tokenizer)
-                    if err != nil {
-                        if b.logger != nil {
-                            b.logger.Printf("Error counting term frequency for term '%s' in document %d: %v", q, j, err)
-                        }
-                        continue
-                    }
-                    qFreq[j-start] = float64(freq)
-                }
-
-                idf, err := b.IDF(q)
-                if err != nil {
-                    if b.logger != nil {
-                        b.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-                    }
+            cc := newCanceller(ctx)
+            for qi, q := range query {
+                weight := weights[qi]
+                if weight == nil {
                     continue
                 }
 
-                for j := start; j < end; j++ {
-                    docLen := b.docLengths[j]
-                    k := computeK(bm25, docLen)
-                    scores[j] += idf * computeScore(bm25, qFreq[j-start], k)
+                // Only the slice of the postings that falls inside this batch is visited.
+                for _, seg := range segs {
+                    p, ok := seg.postingList(q)
+                    if !ok || seg.base >= end || seg.base+seg.numDocs <= start {
+                        continue
+                    }
+                    it := p.iterator()
+                    for it.advance(start); it.valid() && it.doc() < end; it.next() {
+                        if errs[i] = cc.step(); errs[i] != nil {
+                            return
+                        }
+                        docID := it.doc()
+                        if b.isDeleted(docID) {
+                            continue
+                        }
+                        scores[docID] += weight(float64(it.freq()), b.docLengths.at(docID))
+                    }
                 }
             }
-        }(start, end)
+        }(i, start, end)
     }
 
     wg.Wait()
+    if err := firstError(errs); err != nil {
+        return nil, err
+    }
     return scores, nil
 }
 
 // GetBatchScoresBatched returns the BM25 scores for the given query and a subset of documents using parallel computation with batching.
 func (b *bm25Base) GetBatchScoresBatched(query []string, docIDs []int, bm25 BM25, batchSize int) ([]float64, error) {
+    return b.GetBatchScoresBatchedContext(context.Background(), query, docIDs, bm25, batchSize)
+}
+
+// GetBatchScoresBatchedContext is like GetBatchScoresBatched but stops every
+// goroutine and returns the error of ctx once it is done.
+func (b *bm25Base) GetBatchScoresBatchedContext(ctx context.Context, query []string, docIDs []int, bm25 BM25, batchSize int) ([]float64, error) {
     if len(query) == 0 {
         return nil, errors.New("query cannot be empty")
     }
@@ -74,93 +115,81 @@ func (b *bm25Base) GetBatchScoresBatched(query []string, docIDs []int, bm25 BM25
         return nil, errors.New("batch size must be a positive integer")
     }
 
+    if err := b.validateDocIDs(docIDs); err != nil {
+        return nil, err
+    }
+
+    scorer, err := asTermScorer(bm25)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    weights := b.queryWeights(query, scorer)
+    segs := b.segmentsSnapshot()
+
     var wg sync.WaitGroup
     scores := make([]float64, len(docIDs))
     numBatches := (len(docIDs) + batchSize - 1) / batchSize
+    errs := make([]error, numBatches)
     wg.Add(numBatches)
 
     for i := 0; i < numBatches; i++ {
         start := i * batchSize
         end := Min(start+batchSize, len(docIDs))
-        go func(start, end int) {
+        go func(i, start, end int) {
             defer wg.Done()
-            for _, q := range query {
-                qFreq := make([]float64, end-start)
-                for j := start; j < end; j++ {
-                    docID := docIDs[j]
-                    if docID < 0 || docID >= b.corpusSize {
-                        if b.logger != nil {
-                            b.logger.Printf("Invalid document ID: %d", docID)
-                        }
-                        continue
-                    }
-                    doc := JoinTokens(b.corpus[docID], " ")
-                    freq, err := CountTermFreq(q, doc, b.tokenizer)
-                    if err != nil {
-                        if b.logger != nil {
-                            b.logger.Printf("Error counting term frequency for term '%s' in document %d: %v", q, docID, err)
-                        }
-                        continue
-                    }
-                    qFreq[j-start] = float64(freq)
-                }
-
-                idf, err := b.IDF(q)
-                if err != nil {
-                    if b.logger != nil {
-                        b.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-                    }
+            cc := newCanceller(ctx)
+            for qi, q := range query {
+                weight := weights[qi]
+                if weight == nil {
                     continue
                 }
 
                 for j := start; j < end; j++ {
+                    if errs[i] = cc.step(); errs[i] != nil {
+                        return
+                    }
                     docID := docIDs[j]
-                    if docID < 0 || docID >= b.corpusSize {
-                        continue
+                    if freq := termFreq(segs, q, docID); freq > 0 && !b.isDeleted(docID) {
+                        scores[j] += weight(float64(freq), b.docLengths.at(docID))
                     }
-                    docLen := b.docLengths[docID]
-                    k := computeK(bm25, docLen)
-                    scores[j-start] += idf * computeScore(bm25, qFreq[j-start], k)
                 }
             }
-        }(start, end)
+        }(i, start, end)
     }
 
     wg.Wait()
+    if err := firstError(errs); err != nil {
+        return nil, err
+    }
+    b.addFloor(scores, docIDs, b.queryFloor(query, scorer))
+
     return scores, nil
 }
 
 // GetTopNBatched returns the top N documents for the given query using parallel computation with batching.
 func (b *bm25Base) GetTopNBatched(query []string, n int, bm25 BM25, batchSize int) ([]string, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
-
-    if n <= 0 {
-        if b.logger != nil {
-            b.logger.Printf("Invalid value for n: %d. Returning empty slice.", n)
-        }
-        return []string{}, nil
-    }
-
-    if batchSize <= 0 {
-        return nil, errors.New("batch size must be a positive integer")
-    }
+    return b.GetTopNBatchedContext(context.Background(), query, n, bm25, batchSize)
+}
 
-    scores, err := b.GetScoresBatched(query, bm25, batchSize)
+// GetTopNBatchedContext is like GetTopNBatched but stops every goroutine and
+// returns the error of ctx once it is done.
+func (b *bm25Base) GetTopNBatchedContext(ctx context.Context, query []string, n int, bm25 BM25, batchSize int) ([]string, error) {
+    scorer, err := asTermScorer(bm25)
     if err != nil {
         return nil, err
     }
 
-    topNIndices, err := TopNIndices(scores, n)
+    // The query floor is the same for every document, so the documents are
+    // ranked without it.
+    scores, err := b.batchedScores(ctx, query, scorer, batchSize)
     if err != nil {
         return nil, err
     }
 
-    topDocs := make([]string, len(topNIndices))
-    for i, idx := range topNIndices {
-        topDocs[i] = JoinTokens(b.corpus[idx], " ")
-    }
-
-    return topDocs, nil
+    return b.topN(scores, n)
 }
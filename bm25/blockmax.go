@@ -0,0 +1,91 @@
+package bm25
+
+import (
+    "math"
+    "sort"
+)
+
+// blockBound returns the maximum score of the block of the cursor's postings
+// that would hold target, along with the last document ID of that block. It
+// reports false if the cursor has no posting at or after target.
+func (c *wandCursor) blockBound(target int) (float64, int, bool) {
+    p := c.postings.p
+    blk := c.postings.block()
+    if p.blockLast(blk) < target {
+        // Search the later blocks for the first one ending at or after target.
+        blk += sort.Search(p.numBlocks()-blk, func(i int) bool {
+            return p.blockLast(blk+i) >= target
+        })
+        if blk == p.numBlocks() {
+            return 0, 0, false
+        }
+    }
+
+    return c.blockMax[blk], p.blockLast(blk), true
+}
+
+// blockMaxWAND collects the best documents of a segment into the heap using
+// Block-Max WAND (Ding and Suel, "Faster Top-k Document Retrieval Using
+// Block-Max Indexes"). A WAND pivot is only scored if the maximum scores of
+// the blocks holding it can also beat the current n-th best score; otherwise
+// the evaluation jumps past the end of the shortest of those blocks.
+func (b *bm25Base) blockMaxWAND(cursors []*wandCursor, h *topKHeap, cc *canceller) error {
+    active := append([]*wandCursor(nil), cursors...)
+    for {
+        if err := cc.step(); err != nil {
+            return err
+        }
+        active = liveCursors(active)
+        if len(active) == 0 {
+            break
+        }
+
+        pivot := findPivot(active, h)
+        if pivot < 0 {
+            break
+        }
+
+        // Every cursor positioned on the pivot document may contribute to
+        // it, so they all take part in the block-max check.
+        pivotDoc := active[pivot].doc()
+        for pivot+1 < len(active) && active[pivot+1].doc() == pivotDoc {
+            pivot++
+        }
+
+        var bound float64
+        next := math.MaxInt
+        for _, c := range active[:pivot+1] {
+            blockMax, last, ok := c.blockBound(pivotDoc)
+            if ok {
+                bound += blockMax
+                next = Min(next, last+1)
+            }
+        }
+
+        if !competitive(h, bound) {
+            // No document up to the end of the shortest block can enter the
+            // heap: documents before the pivot are ruled out by the WAND
+            // bounds, and the others by the block bounds.
+            if pivot+1 < len(active) {
+                next = Min(next, active[pivot+1].doc())
+            }
+            for _, c := range active[:pivot+1] {
+                c.skipTo(next)
+            }
+            continue
+        }
+
+        if active[0].doc() == pivotDoc {
+            h.offer(pivotDoc, b.scoreDoc(cursors, pivotDoc))
+            continue
+        }
+
+        for _, c := range active {
+            if c.doc() >= pivotDoc {
+                break
+            }
+            c.skipTo(pivotDoc)
+        }
+    }
+    return nil
+}
@@ -1,77 +1,255 @@
 package bm25
 
 import (
+    "context"
+    "encoding/binary"
     "errors"
+    "io"
     "log"
-    "math"
+    "strconv"
+    "sync"
 )
 
 // BM25 is an interface that defines the common methods for all BM25 variants.
+//
+// Every implementation is safe for concurrent use by multiple goroutines for
+// queries: the scoring, top-N and search methods, IDF and the other read-only
+// accessors, including the parallel and batched scoring methods. Per-term
+// values that are computed lazily, such as score bounds and the parameters of
+// BM25Adpt and BM25T, are guarded by locks. The methods that modify the index,
+// AddDocuments, DeleteDocument, UpdateDocument and Compact, must not run
+// concurrently with any other method.
 type BM25 interface {
     CorpusSize() int
     AvgDocLen() float64
     DocLengths() []int
+    DocFreq(term string) int
+    CollectionFreq(term string) int
     IDF(term string) (float64, error)
     GetScores(query []string) ([]float64, error)
     GetBatchScores(query []string, docIDs []int) ([]float64, error)
     GetTopN(query []string, n int) ([]string, error)
+    Search(query []string, n int) ([]Hit, error)
+    SearchText(text string, n int) ([]Hit, error)
+    Analyze(text string) []string
+    GetScoresContext(ctx context.Context, query []string) ([]float64, error)
+    GetBatchScoresContext(ctx context.Context, query []string, docIDs []int) ([]float64, error)
+    GetTopNContext(ctx context.Context, query []string, n int) ([]string, error)
+    SearchContext(ctx context.Context, query []string, n int) ([]Hit, error)
+    SearchTextContext(ctx context.Context, text string, n int) ([]Hit, error)
+    Document(docID int) (string, error)
+    AddDocuments(docs ...string) error
+    DeleteDocument(docID int) error
+    UpdateDocument(docID int, doc string) (int, error)
+    Compact() []int
+    Save(w io.Writer) error
+    SaveMapped(w io.Writer) error
+    Terms(prefix string, fn func(term string, docFreq int) bool)
+    TermRange(lo, hi string, fn func(term string, docFreq int) bool)
+    NumSegments() int
+    WaitForMerges()
+}
+
+// termScorer is implemented by every BM25 variant to supply its term weighting.
+// termWeight returns a function that scores a single posting of the term given
+// its frequency in a document and the length of that document.
+type termScorer interface {
+    termWeight(term string) (func(tf float64, docLen int) float64, error)
+}
+
+// termFloorer is implemented by the variants that give a query term a weight
+// in every document, including the documents that do not contain it, such as
+// the delta of BM25Plus. termWeight then scores a posting on top of that
+// floor. As the floors of a query are the same for every document, they do
+// not change the ranking and are only added to the reported scores.
+type termFloorer interface {
+    termFloor(term string) (float64, error)
+}
+
+// docLengthTable holds the length of every document, in terms. The lengths of
+// a memory-mapped index are read from the file, as uint32 values, rather than
+// copied when it is opened.
+type docLengthTable struct {
+    lengths []int
+    mapped  []byte
+}
+
+// at returns the length of the given document.
+func (t docLengthTable) at(docID int) int {
+    if t.mapped != nil {
+        return int(binary.LittleEndian.Uint32(t.mapped[4*docID:]))
+    }
+    return t.lengths[docID]
+}
+
+// len returns the number of documents in the table.
+func (t docLengthTable) len() int {
+    if t.mapped != nil {
+        return len(t.mapped) / 4
+    }
+    return len(t.lengths)
 }
 
 // bm25Base is a base struct that holds common fields and methods for all BM25 variants.
 type bm25Base struct {
-    corpus      [][]string
-    corpusSize  int
-    avgDocLen   float64
-    docLengths  []int
-    termFreqs   map[string]int
-    idfCache    map[string]float64
-    tokenizer   func(string) []string
-    logger      *log.Logger
+    docs            []string
+    externalIDs     []string
+    corpusSize      int
+    avgDocLen       float64
+    totalDocLen     int
+    docLengths      docLengthTable
+    deleted         bitmap
+    numDeleted      int
+    segments        []*segment
+    segMu           sync.RWMutex
+    merges          sync.WaitGroup
+    merging         bool
+    mergePolicy     MergePolicy
+    maxBufferedDocs int
+    codec           PostingCodec
+    vocab           *vocabulary
+    file            *mappedFile
+    idf             IDFFunc
+    evaluation      Evaluation
+    floorIDF        bool
+    epsilon         float64
+    averageIDF      float64
+    scorer          termScorer
+    tokenizer       func(string) []string
+    analyzer        *Analyzer
+    unscored        Stopwords
+    logger          *log.Logger
 }
 
 // NewBM25Base creates a new instance of the bm25Base struct.
-func NewBM25Base(corpus []string, tokenizer func(string) []string, logger *log.Logger) (*bm25Base, error) {
+// The inverted index is built once here so that scoring only has to visit the
+// documents that contain the query terms. Unless overridden with WithIDF, IDF
+// values are computed with RobertsonSparckJonesIDF. The tokenizer may be nil if
+// an analyzer is given with WithAnalyzer.
+func NewBM25Base(corpus []string, tokenizer func(string) []string, logger *log.Logger, opts ...Option) (*bm25Base, error) {
     if len(corpus) == 0 {
         return nil, errors.New("corpus cannot be empty")
     }
 
-    if tokenizer == nil {
+    base := &bm25Base{
+        docs:            append([]string(nil), corpus...),
+        vocab:           newVocabulary(),
+        idf:             RobertsonSparckJonesIDF,
+        mergePolicy:     DefaultTieredMergePolicy(),
+        maxBufferedDocs: DefaultMaxBufferedDocs,
+        codec:           DefaultPostingCodec,
+        tokenizer:       tokenizer,
+        logger:          logger,
+    }
+
+    for _, opt := range opts {
+        if err := opt(base); err != nil {
+            return nil, err
+        }
+    }
+
+    if base.tokenizer == nil {
         return nil, errors.New("tokenizer function cannot be nil")
     }
 
-    base := &bm25Base{
-        corpus:     make([][]string, len(corpus)),
-        termFreqs:  make(map[string]int),
-        idfCache:   make(map[string]float64),
-        tokenizer:  tokenizer,
-        logger:     logger,
+    if base.externalIDs != nil && len(base.externalIDs) != len(corpus) {
+        return nil, errors.New("number of external IDs must match the corpus size")
     }
 
-    var totalDocLen int
+    tokenized := make([][]string, len(corpus))
     for i, doc := range corpus {
-        tokens := tokenizer(doc)
-        if len(tokens) == 0 {
-            return nil, errors.New("tokenizer function returned an empty slice for document at index " + string(i))
-        }
-        base.corpus[i] = tokens
-        base.docLengths = append(base.docLengths, len(tokens))
-        totalDocLen += len(tokens)
-
-        for _, token := range tokens {
-            base.termFreqs[token]++
+        tokenized[i] = base.tokenizer(doc)
+        if len(tokenized[i]) == 0 {
+            return nil, errors.New("tokenizer function returned an empty slice for document at index " + strconv.Itoa(i))
         }
     }
+    base.addSegments(base.flush(tokenized)...)
 
     base.corpusSize = len(corpus)
-    base.avgDocLen = float64(totalDocLen) / float64(base.corpusSize)
+    base.avgDocLen = float64(base.totalDocLen) / float64(base.corpusSize)
+
+    if base.floorIDF {
+        base.computeIDFFloor()
+    }
 
     if base.logger != nil {
-        base.logger.Printf("Corpus size: %d, Average document length: %.2f", base.corpusSize, base.avgDocLen)
+        base.logger.Printf("Corpus size: %d, Average document length: %.2f, Vocabulary size: %d", base.corpusSize, base.avgDocLen, base.vocabularySize())
     }
 
     return base, nil
 }
 
+// flush indexes tokenized documents after the existing ones into new
+// segments of at most maxBufferedDocs documents, adding them to the document
+// lengths and the collection statistics. The caller publishes the segments.
+func (b *bm25Base) flush(tokenized [][]string) []*segment {
+    var builders []*segmentBuilder
+    var sb *segmentBuilder
+    for _, tokens := range tokenized {
+        docID := b.maxDoc()
+        if sb == nil || sb.seg.numDocs == b.maxBufferedDocs {
+            sb = newSegmentBuilder(docID)
+            builders = append(builders, sb)
+        }
+
+        b.docLengths.lengths = append(b.docLengths.lengths, len(tokens))
+        b.totalDocLen += len(tokens)
+
+        termCounts := make(map[string]int)
+        for _, token := range tokens {
+            termCounts[token]++
+        }
+        for token, freq := range termCounts {
+            b.vocab.add(token, 1, freq)
+        }
+        b.vocab.maybeRebuild()
+        sb.add(docID, termCounts)
+    }
+
+    segs := make([]*segment, len(builders))
+    for i, sb := range builders {
+        segs[i] = sb.build(b.codec)
+    }
+    return segs
+}
+
+// AddDocuments adds documents to the index without rebuilding it. The new
+// documents get the next document IDs, in order, and an empty external ID.
+// They are flushed into new segments, which may trigger a background merge.
+// If any of them tokenizes to an empty slice, none of them is added.
+//
+// The corpus size and average document length change with every addition, so
+// the per-term score bounds and parameters are recomputed on demand.
+func (b *bm25Base) AddDocuments(docs ...string) error {
+    if b.file != nil {
+        return ErrReadOnly
+    }
+
+    tokenized := make([][]string, len(docs))
+    for i, doc := range docs {
+        tokenized[i] = b.tokenizer(doc)
+        if len(tokenized[i]) == 0 {
+            return errors.New("tokenizer function returned an empty slice for document at index " + strconv.Itoa(i))
+        }
+    }
+
+    segs := b.flush(tokenized)
+    b.docs = append(b.docs, docs...)
+    if b.externalIDs != nil {
+        b.externalIDs = append(b.externalIDs, make([]string, len(docs))...)
+    }
+
+    b.corpusSize += len(docs)
+    b.statsChanged()
+    b.addSegments(segs...)
+
+    if b.logger != nil {
+        b.logger.Printf("Added %d documents, Corpus size: %d, Average document length: %.2f, Vocabulary size: %d", len(docs), b.corpusSize, b.avgDocLen, b.vocabularySize())
+    }
+
+    return nil
+}
+
 // CorpusSize returns the size of the corpus.
 func (b *bm25Base) CorpusSize() int {
     return b.corpusSize
@@ -82,36 +260,146 @@ func (b *bm25Base) AvgDocLen() float64 {
     return b.avgDocLen
 }
 
-// DocLengths returns the lengths of all documents in the corpus.
+// DocLengths returns the lengths of all documents in the corpus. The lengths
+// of a memory-mapped index are read from the file into a new slice.
 func (b *bm25Base) DocLengths() []int {
-    return b.docLengths
+    if b.docLengths.mapped == nil {
+        return b.docLengths.lengths
+    }
+    lengths := make([]int, b.docLengths.len())
+    for docID := range lengths {
+        lengths[docID] = b.docLengths.at(docID)
+    }
+    return lengths
 }
 
-// IDF returns the inverse document frequency (IDF) of the given term.
-func (b *bm25Base) IDF(term string) (float64, error) {
-    if term == "" {
-        return 0, errors.New("term cannot be empty")
+// DocFreq returns the number of documents that contain the given term.
+func (b *bm25Base) DocFreq(term string) int {
+    docFreq, _, _ := b.termStats(term)
+    return docFreq
+}
+
+// CollectionFreq returns the total number of occurrences of the given term
+// across all documents in the corpus.
+func (b *bm25Base) CollectionFreq(term string) int {
+    _, collFreq, _ := b.termStats(term)
+    return collFreq
+}
+
+// termStats returns the document and collection frequencies of the term, and
+// whether it is in the vocabulary.
+func (b *bm25Base) termStats(term string) (docFreq, collFreq int, ok bool) {
+    if b.file != nil {
+        return b.file.termStats(term)
     }
+    f, ok := b.vocab.stats(term)
+    return f.docFreq, f.collFreq, ok
+}
 
-    if idf, ok := b.idfCache[term]; ok {
-        return idf, nil
+// vocabularySize returns the number of terms in the vocabulary.
+func (b *bm25Base) vocabularySize() int {
+    if b.file != nil {
+        return b.file.numTerms
     }
+    return b.vocab.size
+}
 
-    termFreq, ok := b.termFreqs[term]
-    if !ok {
-        b.idfCache[term] = 0.0
-        return 0.0, nil
+// forEachTerm calls fn for every term of the vocabulary, in order.
+func (b *bm25Base) forEachTerm(fn func(term string, docFreq int)) {
+    b.termRange("", "", func(term string, docFreq int) bool {
+        fn(term, docFreq)
+        return true
+    })
+}
+
+// termRange calls fn, in order, for every term of the vocabulary from lo up
+// to but excluding hi, until fn returns false. An empty hi has no upper bound.
+func (b *bm25Base) termRange(lo, hi string, fn func(term string, docFreq int) bool) {
+    if b.file != nil {
+        b.file.termRange(lo, hi, fn)
+        return
+    }
+    b.vocab.forRange(lo, hi, func(term string, f termFreqs) bool {
+        return fn(term, f.docFreq)
+    })
+}
+
+// Terms calls fn, in order, for every term of the vocabulary that starts with
+// prefix, along with its document frequency, until fn returns false. An empty
+// prefix enumerates the whole vocabulary.
+func (b *bm25Base) Terms(prefix string, fn func(term string, docFreq int) bool) {
+    b.termRange(prefix, prefixEnd(prefix), fn)
+}
+
+// TermRange calls fn, in order, for every term of the vocabulary from lo up
+// to but excluding hi, along with its document frequency, until fn returns
+// false. An empty hi enumerates up to the end of the vocabulary.
+func (b *bm25Base) TermRange(lo, hi string, fn func(term string, docFreq int) bool) {
+    b.termRange(lo, hi, fn)
+}
+
+// document returns the original text of the document.
+func (b *bm25Base) document(docID int) string {
+    if b.file != nil {
+        return b.file.document(docID)
     }
+    return b.docs[docID]
+}
 
-    if termFreq == 0 || termFreq >= b.corpusSize {
-        return 0, errors.New("invalid term frequency for term: " + term)
+// externalID returns the external identifier of the document, or the empty
+// string if no external identifiers were given.
+func (b *bm25Base) externalID(docID int) string {
+    if b.file != nil {
+        return b.file.externalID(docID)
+    }
+    if b.externalIDs == nil {
+        return ""
     }
+    return b.externalIDs[docID]
+}
+
+// AverageIDF returns the average IDF over the vocabulary, as used by the
+// epsilon floor. It is zero unless the floor is enabled with WithEpsilon.
+func (b *bm25Base) AverageIDF() float64 {
+    return b.averageIDF
+}
 
-    idf := math.Log((float64(b.corpusSize) - float64(termFreq) + 0.5) / (float64(termFreq) + 0.5))
-    b.idfCache[term] = idf
+// computeIDFFloor computes the average IDF over the vocabulary. As rank_bm25
+// does, IDF then replaces negative values with epsilon times the average.
+func (b *bm25Base) computeIDFFloor() {
+    var idfSum float64
+    negative := 0
+    b.forEachTerm(func(term string, docFreq int) {
+        idf := b.idf(docFreq, b.corpusSize)
+        idfSum += idf
+        if idf < 0 {
+            negative++
+        }
+    })
+
+    b.averageIDF = idfSum / float64(b.vocabularySize())
 
     if b.logger != nil {
-        b.logger.Printf("IDF for term '%s': %.2f", term, idf)
+        b.logger.Printf("Average IDF: %.2f, %d negative IDF values floored to %.2f", b.averageIDF, negative, b.epsilon*b.averageIDF)
+    }
+}
+
+// IDF returns the inverse document frequency (IDF) of the given term. It is
+// computed from the document frequency in the term dictionary, so no per-term
+// IDF values are kept.
+func (b *bm25Base) IDF(term string) (float64, error) {
+    if term == "" {
+        return 0, errors.New("term cannot be empty")
+    }
+
+    docFreq, _, ok := b.termStats(term)
+    if !ok {
+        return 0.0, nil
+    }
+
+    idf := b.idf(docFreq, b.corpusSize)
+    if b.floorIDF && idf < 0 {
+        idf = b.epsilon * b.averageIDF
     }
 
     return idf, nil
@@ -131,3 +419,212 @@ func (b *bm25Base) GetBatchScores(query []string, docIDs []int) ([]float64, erro
 func (b *bm25Base) GetTopN(query []string, n int) ([]string, error) {
     return nil, errors.New("not implemented")
 }
+
+// lookupWeight looks up the weighting function of a query term, logging and
+// returning nil if the variant cannot score it. It returns nil for the
+// stopwords given with WithUnscoredStopwords, so that every scoring path
+// skips them alike.
+func (b *bm25Base) lookupWeight(s termScorer, term string) func(tf float64, docLen int) float64 {
+    if b.unscored[term] {
+        return nil
+    }
+    weight, err := s.termWeight(term)
+    if err != nil {
+        if b.logger != nil {
+            b.logger.Printf("Error calculating IDF for term '%s': %v", term, err)
+        }
+        return nil
+    }
+    return weight
+}
+
+// scoreQuery scores every document in the corpus against the query by walking
+// the postings of each query term, and adds the query floor to the live
+// documents. Deleted documents score zero. It returns the error of the context
+// if it is done before scoring ends.
+func (b *bm25Base) scoreQuery(ctx context.Context, query []string, s termScorer) ([]float64, error) {
+    scores, err := b.scorePostings(ctx, query, s)
+    if err != nil {
+        return nil, err
+    }
+    b.addFloor(scores, nil, b.queryFloor(query, s))
+    return scores, nil
+}
+
+// scorePostings scores every document in the corpus against the query by
+// walking the postings of each query term, without the query floor, so that
+// documents without any query term score zero.
+func (b *bm25Base) scorePostings(ctx context.Context, query []string, s termScorer) ([]float64, error) {
+    if len(query) == 0 {
+        return nil, errors.New("query cannot be empty")
+    }
+
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    cc := newCanceller(ctx)
+    segs := b.segmentsSnapshot()
+    scores := make([]float64, b.maxDoc())
+    for _, q := range query {
+        if _, _, ok := b.termStats(q); !ok {
+            continue
+        }
+
+        weight := b.lookupWeight(s, q)
+        if weight == nil {
+            continue
+        }
+
+        for _, seg := range segs {
+            p, ok := seg.postingList(q)
+            if !ok {
+                continue
+            }
+            for it := p.iterator(); it.valid(); it.next() {
+                if err := cc.step(); err != nil {
+                    return nil, err
+                }
+                docID := it.doc()
+                if b.isDeleted(docID) {
+                    continue
+                }
+                scores[docID] += weight(float64(it.freq()), b.docLengths.at(docID))
+            }
+        }
+    }
+
+    return scores, nil
+}
+
+// queryFloor returns the score that every live document gets for the query
+// whether or not it contains the query terms: the sum of the floors of the
+// query terms that can be scored. It is zero for the variants without floors.
+func (b *bm25Base) queryFloor(query []string, s termScorer) float64 {
+    f, ok := s.(termFloorer)
+    if !ok {
+        return 0
+    }
+
+    var floor float64
+    for i, weight := range b.queryWeights(query, s) {
+        if weight == nil {
+            continue
+        }
+        if termFloor, err := f.termFloor(query[i]); err == nil {
+            floor += termFloor
+        }
+    }
+    return floor
+}
+
+// addFloor adds the query floor to the scores of the live documents. The
+// scores are those of the given documents, or of every document if docIDs is
+// nil.
+func (b *bm25Base) addFloor(scores []float64, docIDs []int, floor float64) {
+    if floor == 0 {
+        return
+    }
+    for i := range scores {
+        docID := i
+        if docIDs != nil {
+            docID = docIDs[i]
+        }
+        if !b.isDeleted(docID) {
+            scores[i] += floor
+        }
+    }
+}
+
+// validateDocIDs checks that every document ID refers to a document in the corpus.
+func (b *bm25Base) validateDocIDs(docIDs []int) error {
+    if len(docIDs) == 0 {
+        return errors.New("document IDs cannot be empty")
+    }
+
+    for _, docID := range docIDs {
+        if docID < 0 || docID >= b.maxDoc() {
+            return errors.New("invalid document ID: " + strconv.Itoa(docID))
+        }
+    }
+
+    return nil
+}
+
+// scoreDocs scores the given subset of documents against the query, looking up
+// each term frequency in the term's postings and adding the query floor.
+// Deleted documents score zero. It returns the error of the context if it is
+// done before scoring ends.
+func (b *bm25Base) scoreDocs(ctx context.Context, query []string, docIDs []int, s termScorer) ([]float64, error) {
+    if len(query) == 0 {
+        return nil, errors.New("query cannot be empty")
+    }
+
+    if err := b.validateDocIDs(docIDs); err != nil {
+        return nil, err
+    }
+
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    cc := newCanceller(ctx)
+    segs := b.segmentsSnapshot()
+    scores := make([]float64, len(docIDs))
+    for _, q := range query {
+        if _, _, ok := b.termStats(q); !ok {
+            continue
+        }
+
+        weight := b.lookupWeight(s, q)
+        if weight == nil {
+            continue
+        }
+
+        for i, docID := range docIDs {
+            if err := cc.step(); err != nil {
+                return nil, err
+            }
+            if freq := termFreq(segs, q, docID); freq > 0 && !b.isDeleted(docID) {
+                scores[i] += weight(float64(freq), b.docLengths.at(docID))
+            }
+        }
+    }
+    b.addFloor(scores, docIDs, b.queryFloor(query, s))
+
+    return scores, nil
+}
+
+// rankScores ranks the live documents with the given scores as TopNIndices
+// does, optionally leaving out zero scores, and returns the top N.
+func (b *bm25Base) rankScores(scores []float64, n int, skipZero bool) ([]scoredDoc, error) {
+    if n <= 0 {
+        return nil, errors.New("n must be a positive integer")
+    }
+
+    h := newTopKHeap(Min(n, b.corpusSize))
+    for docID, score := range scores {
+        if (skipZero && score == 0) || b.isDeleted(docID) {
+            continue
+        }
+        h.offer(docID, score)
+    }
+
+    return h.sorted(), nil
+}
+
+// topN ranks the corpus with the given scores and returns the original text
+// of the top N documents.
+func (b *bm25Base) topN(scores []float64, n int) ([]string, error) {
+    docs, err := b.rankScores(scores, n, false)
+    if err != nil {
+        return nil, err
+    }
+
+    topDocs := make([]string, len(docs))
+    for i, d := range docs {
+        topDocs[i] = b.document(d.docID)
+    }
+
+    return topDocs, nil
+}
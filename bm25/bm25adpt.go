@@ -1,20 +1,45 @@
 package bm25
 
 import (
+    "context"
     "errors"
     "log"
+    "math"
+    "sync"
 )
 
-// BM25Adpt is an implementation of the BM25Adpt variant.
+// maxAdptGainPoints bounds the number of points of the information gain curve
+// that are used to fit the term-specific k1 of BM25Adpt.
+const maxAdptGainPoints = 64
+
+// adptTermParams holds the parameters BM25Adpt estimates for a single term.
+// fitted is false if the term's gain curve could not be estimated.
+type adptTermParams struct {
+    k1     float64
+    idf    float64
+    fitted bool
+}
+
+// BM25Adpt is an implementation of the BM25-Adpt variant (Lv and Zhai,
+// "Adaptive Term Frequency Normalization for BM25"). For every term it
+// measures the information gain of seeing one more occurrence of the term,
+// uses the first gain G¹ as the IDF and fits a term-specific k1 to the rest of
+// the gain curve. Terms whose curve cannot be estimated, because too few
+// documents repeat them, fall back to the configured IDF and k1. A positive
+// delta adds idf * delta to the score of every document for each query term,
+// as in BM25Plus; with a zero delta the scores are those of Lv and Zhai.
 type BM25Adpt struct {
     *bm25Base
-    k1    float64
-    b     float64
-    delta float64
+    k1       float64
+    b        float64
+    delta    float64
+    paramsMu sync.RWMutex
+    params   map[string]adptTermParams
 }
 
 // NewBM25Adpt creates a new instance of the BM25Adpt struct.
-func NewBM25Adpt(corpus []string, tokenizer func(string) []string, k1 float64, b float64, delta float64, logger *log.Logger) (*BM25Adpt, error) {
+// The per-term parameters are estimated once here and cached with the index.
+func NewBM25Adpt(corpus []string, tokenizer func(string) []string, k1 float64, b float64, delta float64, logger *log.Logger, opts ...Option) (*BM25Adpt, error) {
     if k1 < 0 {
         return nil, errors.New("k1 must be non-negative")
     }
@@ -27,124 +52,257 @@ func NewBM25Adpt(corpus []string, tokenizer func(string) []string, k1 float64, b
         return nil, errors.New("delta must be non-negative")
     }
 
-    base, err := NewBM25Base(corpus, tokenizer, logger)
+    base, err := NewBM25Base(corpus, tokenizer, logger, opts...)
     if err != nil {
         return nil, err
     }
 
-    return &BM25Adpt{
+    a := &BM25Adpt{
         bm25Base: base,
         k1:       k1,
         b:        b,
         delta:    delta,
-    }, nil
+    }
+    a.estimateParams()
+    a.computeMaxScores(a)
+
+    return a, nil
 }
 
-// GetScores returns the BM25 scores for the given query.
-func (a *BM25Adpt) GetScores(query []string) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
+// estimateParams fits the G¹ IDF and k1 of every term in the vocabulary.
+func (a *BM25Adpt) estimateParams() {
+    a.paramsMu.Lock()
+    a.params = make(map[string]adptTermParams, a.vocabularySize())
+    a.paramsMu.Unlock()
 
-    scores := make([]float64, a.corpusSize)
-    for _, q := range query {
-        qFreq := make([]float64, a.corpusSize)
-        for i, doc := range a.corpus {
-            docStr := JoinTokens(doc, " ")
-            freq, _ := CountTermFreq(q, docStr, a.tokenizer) // Ignore the error for now
-            qFreq[i] = float64(freq)
+    var fitted int
+    a.forEachTerm(func(term string, docFreq int) {
+        if a.termParams(term).fitted {
+            fitted++
         }
+    })
 
-        idf, err := a.IDF(q)
-        if err != nil {
-            if a.logger != nil {
-                a.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-            }
-            continue
-        }
+    if a.logger != nil {
+        a.logger.Printf("BM25Adpt fitted parameters for %d of %d terms", fitted, a.vocabularySize())
+    }
+}
+
+// termParams returns the parameters of the given term, fitting them if the
+// index changed since they were last fitted.
+func (a *BM25Adpt) termParams(term string) adptTermParams {
+    a.paramsMu.RLock()
+    params, ok := a.params[term]
+    a.paramsMu.RUnlock()
+    if ok {
+        return params
+    }
+
+    if _, _, ok := a.termStats(term); !ok {
+        return adptTermParams{}
+    }
+
+    params, ok = a.fitTerm(term)
+    params.fitted = ok
+    a.paramsMu.Lock()
+    a.params[term] = params
+    a.paramsMu.Unlock()
+    return params
+}
 
-        for i, docLen := range a.docLengths {
-            k := a.k1 * (1 - a.b + a.b*float64(docLen)/a.avgDocLen)
-            scores[i] += idf * (a.delta + (qFreq[i] * (1 + k)) / (qFreq[i] + k))
+// resetTermParams drops the fitted parameters after the collection
+// statistics the gain curves are computed from changed. They are refitted on
+// demand.
+func (a *BM25Adpt) resetTermParams() {
+    a.paramsMu.Lock()
+    defer a.paramsMu.Unlock()
+    a.params = make(map[string]adptTermParams)
+}
+
+// fitTerm computes the information gain curve of a term and fits its k1.
+// It reports false if the curve cannot be estimated from the corpus.
+func (a *BM25Adpt) fitTerm(term string) (adptTermParams, bool) {
+    // counts[m] is the number of documents whose normalised term frequency ctd
+    // rounds to m, so that df_r = |{D : ctd >= r - 0.5}| is a suffix sum.
+    var counts []int
+    a.forEachPosting(term, func(docID, freq int) {
+        ctd := float64(freq) / (1 - a.b + a.b*float64(a.docLengths.at(docID))/a.avgDocLen)
+        m := int(math.Floor(ctd + 0.5))
+        if m > maxAdptGainPoints+1 {
+            m = maxAdptGainPoints + 1
+        }
+        for len(counts) <= m {
+            counts = append(counts, 0)
         }
+        counts[m]++
+    })
+    docFreq, _, _ := a.termStats(term)
+
+    dfs := make([]int, maxAdptGainPoints+2)
+    suffix := 0
+    for m := len(counts) - 1; m >= 0; m-- {
+        suffix += counts[m]
+        dfs[m] = suffix
     }
+    dfs[0] = a.corpusSize
+    dfs[1] = docFreq
 
-    return scores, nil
+    // G^r = log2((df_{r+1} + 0.5) / (df_r + 1)) - log2((df + 0.5) / (N + 1)).
+    // The curve is cut where no document reaches r + 1 occurrences, as the
+    // smoothed gain is meaningless past that point.
+    background := math.Log2((float64(docFreq) + 0.5) / (float64(a.corpusSize) + 1))
+    gains := []float64{0}
+    for r := 1; r <= maxAdptGainPoints && dfs[r+1] > 0; r++ {
+        gains = append(gains, math.Log2((float64(dfs[r+1])+0.5)/(float64(dfs[r])+1))-background)
+    }
+
+    if len(gains) < 3 || gains[1] <= 0 {
+        return adptTermParams{}, false
+    }
+
+    return adptTermParams{k1: fitK1(gains), idf: gains[1]}, true
 }
 
-// GetBatchScores returns the BM25 scores for the given query and a subset of documents.
-func (a *BM25Adpt) GetBatchScores(query []string, docIDs []int) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
-
-    if len(docIDs) == 0 {
-        return nil, errors.New("document IDs cannot be empty")
-    }
-
-    scores := make([]float64, len(docIDs))
-    for _, q := range query {
-        qFreq := make([]float64, len(docIDs))
-        for i, docID := range docIDs {
-            if docID < 0 || docID >= a.corpusSize {
-                if a.logger != nil {
-                    a.logger.Printf("Invalid document ID: %d", docID)
-                }
-                continue
-            }
-            docStr := JoinTokens(a.corpus[docID], " ")
-            freq, _ := CountTermFreq(q, docStr, a.tokenizer) // Ignore the error for now
-            qFreq[i] = float64(freq)
+// fitK1 returns the k1 minimising sum_r (G^r / G^1 - (k1 + 1) r / (k1 + r))^2.
+func fitK1(gains []float64) float64 {
+    loss := func(k1 float64) float64 {
+        var sum float64
+        for r := 1; r < len(gains); r++ {
+            d := gains[r]/gains[1] - (k1+1)*float64(r)/(k1+float64(r))
+            sum += d * d
         }
+        return sum
+    }
 
-        idf, err := a.IDF(q)
-        if err != nil {
-            if a.logger != nil {
-                a.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-            }
-            continue
+    // Coarse logarithmic scan followed by a golden-section refinement around
+    // the best grid point.
+    const lowK1, highK1, steps = 0.001, 100.0, 60
+    ratio := math.Pow(highK1/lowK1, 1.0/steps)
+    best, bestLoss := lowK1, loss(lowK1)
+    for i, k1 := 1, lowK1*ratio; i <= steps; i, k1 = i+1, k1*ratio {
+        if l := loss(k1); l < bestLoss {
+            best, bestLoss = k1, l
         }
+    }
 
-        for i, docID := range docIDs {
-            if docID < 0 || docID >= a.corpusSize {
-                continue
-            }
-            docLen := a.docLengths[docID]
-            k := a.k1 * (1 - a.b + a.b*float64(docLen)/a.avgDocLen)
-            scores[i] += idf * (a.delta + (qFreq[i] * (1 + k)) / (qFreq[i] + k))
+    lo, hi := best/ratio, best*ratio
+    invPhi := (math.Sqrt(5) - 1) / 2
+    x1, x2 := hi-invPhi*(hi-lo), lo+invPhi*(hi-lo)
+    f1, f2 := loss(x1), loss(x2)
+    for i := 0; i < 50; i++ {
+        if f1 < f2 {
+            hi, x2, f2 = x2, x1, f1
+            x1 = hi - invPhi*(hi-lo)
+            f1 = loss(x1)
+        } else {
+            lo, x1, f1 = x1, x2, f2
+            x2 = lo + invPhi*(hi-lo)
+            f2 = loss(x2)
         }
     }
 
-    return scores, nil
+    if k1 := (lo + hi) / 2; loss(k1) < bestLoss {
+        return k1
+    }
+    return best
 }
 
-// GetTopN returns the top N documents for the given query.
-func (a *BM25Adpt) GetTopN(query []string, n int) ([]string, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
+// IDF returns the G¹ information gain of the given term, or the configured IDF
+// if the term's gain curve could not be estimated.
+func (a *BM25Adpt) IDF(term string) (float64, error) {
+    if params := a.termParams(term); params.fitted {
+        return params.idf, nil
     }
+    return a.bm25Base.IDF(term)
+}
 
-    if n <= 0 {
-        if a.logger != nil {
-            a.logger.Printf("Invalid value for n: %d. Returning empty slice.", n)
-        }
-        return []string{}, nil
+// TermK1 returns the k1 used for the given term and whether it was fitted from
+// the term's information gain curve rather than taken from the constructor.
+func (a *BM25Adpt) TermK1(term string) (float64, bool) {
+    if params := a.termParams(term); params.fitted {
+        return params.k1, true
     }
+    return a.k1, false
+}
 
-    scores, err := a.GetScores(query)
-    if err != nil {
-        return nil, err
-    }
+// GetScores returns the BM25 scores for the given query.
+func (a *BM25Adpt) GetScores(query []string) ([]float64, error) {
+    return a.scoreQuery(context.Background(), query, a)
+}
+
+// GetScoresContext is like GetScores but stops scoring and returns the error
+// of ctx once it is done.
+func (a *BM25Adpt) GetScoresContext(ctx context.Context, query []string) ([]float64, error) {
+    return a.scoreQuery(ctx, query, a)
+}
+
+// GetBatchScores returns the BM25 scores for the given query and a subset of documents.
+func (a *BM25Adpt) GetBatchScores(query []string, docIDs []int) ([]float64, error) {
+    return a.scoreDocs(context.Background(), query, docIDs, a)
+}
+
+// GetBatchScoresContext is like GetBatchScores but stops scoring and returns
+// the error of ctx once it is done.
+func (a *BM25Adpt) GetBatchScoresContext(ctx context.Context, query []string, docIDs []int) ([]float64, error) {
+    return a.scoreDocs(ctx, query, docIDs, a)
+}
+
+// GetTopN returns the top N documents for the given query.
+func (a *BM25Adpt) GetTopN(query []string, n int) ([]string, error) {
+    return a.topNDocuments(context.Background(), query, n, a)
+}
 
-    topNIndices, err := TopNIndices(scores, n)
+// GetTopNContext is like GetTopN but stops the evaluation and returns the
+// error of ctx once it is done.
+func (a *BM25Adpt) GetTopNContext(ctx context.Context, query []string, n int) ([]string, error) {
+    return a.topNDocuments(ctx, query, n, a)
+}
+
+// Search returns the top N hits for the given query.
+func (a *BM25Adpt) Search(query []string, n int) ([]Hit, error) {
+    return a.search(context.Background(), query, n, a)
+}
+
+// SearchContext is like Search but stops the evaluation and returns the error
+// of ctx once it is done.
+func (a *BM25Adpt) SearchContext(ctx context.Context, query []string, n int) ([]Hit, error) {
+    return a.search(ctx, query, n, a)
+}
+
+// SearchText analyzes the query text as the documents were analyzed and
+// returns the top N hits for the resulting terms.
+func (a *BM25Adpt) SearchText(text string, n int) ([]Hit, error) {
+    return a.searchText(context.Background(), text, n, a)
+}
+
+// SearchTextContext is like SearchText but stops the evaluation and returns
+// the error of ctx once it is done.
+func (a *BM25Adpt) SearchTextContext(ctx context.Context, text string, n int) ([]Hit, error) {
+    return a.searchText(ctx, text, n, a)
+}
+
+// termWeight returns the BM25Adpt weighting function for the given term:
+// idf * (k1 + 1) * tf / (k1 * (1 - b + b * dl / avgdl) + tf) with the
+// term-specific idf and k1, without the delta that termFloor gives every
+// document.
+func (a *BM25Adpt) termWeight(term string) (func(tf float64, docLen int) float64, error) {
+    idf, err := a.IDF(term)
     if err != nil {
         return nil, err
     }
+    k1, _ := a.TermK1(term)
 
-    topDocs := make([]string, len(topNIndices))
-    for i, idx := range topNIndices {
-        topDocs[i] = JoinTokens(a.corpus[idx], " ")
-    }
+    return func(tf float64, docLen int) float64 {
+        k := k1 * (1 - a.b + a.b*float64(docLen)/a.avgDocLen)
+        return idf * (k1 + 1) * tf / (k + tf)
+    }, nil
+}
 
-    return topDocs, nil
+// termFloor returns idf * delta, the lower bound that BM25Adpt adds to the
+// score of every document for the term, with the term-specific idf.
+func (a *BM25Adpt) termFloor(term string) (float64, error) {
+    idf, err := a.IDF(term)
+    if err != nil {
+        return 0, err
+    }
+    return idf * a.delta, nil
 }
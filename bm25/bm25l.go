@@ -1,19 +1,27 @@
 package bm25
 
 import (
+    "context"
     "errors"
     "log"
 )
 
-// BM25L is an implementation of the BM25L variant.
+// DefaultBM25LDelta is the delta recommended by Lv and Zhai for BM25L.
+const DefaultBM25LDelta = 0.5
+
+// BM25L is an implementation of the BM25L variant (Lv and Zhai, "When
+// Documents Are Very Long, BM25 Fails!"). Term frequencies are normalised by
+// document length before being shifted by delta, so that long documents are
+// not over-penalised.
 type BM25L struct {
     *bm25Base
-    k1 float64
-    b  float64
+    k1    float64
+    b     float64
+    delta float64
 }
 
 // NewBM25L creates a new instance of the BM25L struct.
-func NewBM25L(corpus []string, tokenizer func(string) []string, k1 float64, b float64, logger *log.Logger) (*BM25L, error) {
+func NewBM25L(corpus []string, tokenizer func(string) []string, k1 float64, b float64, delta float64, logger *log.Logger, opts ...Option) (*BM25L, error) {
     if k1 < 0 {
         return nil, errors.New("k1 must be non-negative")
     }
@@ -22,123 +30,93 @@ func NewBM25L(corpus []string, tokenizer func(string) []string, k1 float64, b fl
         return nil, errors.New("b must be between 0 and 1")
     }
 
-    base, err := NewBM25Base(corpus, tokenizer, logger)
+    if delta < 0 {
+        return nil, errors.New("delta must be non-negative")
+    }
+
+    base, err := NewBM25Base(corpus, tokenizer, logger, opts...)
     if err != nil {
         return nil, err
     }
 
-    return &BM25L{
+    l := &BM25L{
         bm25Base: base,
         k1:       k1,
         b:        b,
-    }, nil
+        delta:    delta,
+    }
+    l.computeMaxScores(l)
+
+    return l, nil
 }
 
 // GetScores returns the BM25 scores for the given query.
 func (l *BM25L) GetScores(query []string) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
-
-    scores := make([]float64, l.corpusSize)
-    for _, q := range query {
-        qFreq := make([]float64, l.corpusSize)
-        for i, doc := range l.corpus {
-            docStr := JoinTokens(doc, " ")
-            freq, _ := CountTermFreq(q, docStr, l.tokenizer) // Ignore the error for now
-            qFreq[i] = float64(freq)
-        }
-
-        idf, err := l.IDF(q)
-        if err != nil {
-            if l.logger != nil {
-                l.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-            }
-            continue
-        }
-
-        for i, docLen := range l.docLengths {
-            k := l.k1 * (1 - l.b + l.b*float64(docLen)/l.avgDocLen)
-            scores[i] += idf * (qFreq[i] / (qFreq[i] + k))
-        }
-    }
+    return l.scoreQuery(context.Background(), query, l)
+}
 
-    return scores, nil
+// GetScoresContext is like GetScores but stops scoring and returns the error
+// of ctx once it is done.
+func (l *BM25L) GetScoresContext(ctx context.Context, query []string) ([]float64, error) {
+    return l.scoreQuery(ctx, query, l)
 }
 
 // GetBatchScores returns the BM25 scores for the given query and a subset of documents.
 func (l *BM25L) GetBatchScores(query []string, docIDs []int) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
-
-    if len(docIDs) == 0 {
-        return nil, errors.New("document IDs cannot be empty")
-    }
-
-    scores := make([]float64, len(docIDs))
-    for _, q := range query {
-        qFreq := make([]float64, len(docIDs))
-        for i, docID := range docIDs {
-            if docID < 0 || docID >= l.corpusSize {
-                if l.logger != nil {
-                    l.logger.Printf("Invalid document ID: %d", docID)
-                }
-                continue
-            }
-            docStr := JoinTokens(l.corpus[docID], " ")
-            freq, _ := CountTermFreq(q, docStr, l.tokenizer) // Ignore the error for now
-            qFreq[i] = float64(freq)
-        }
-
-        idf, err := l.IDF(q)
-        if err != nil {
-            if l.logger != nil {
-                l.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-            }
-            continue
-        }
-
-        for i, docID := range docIDs {
-            if docID < 0 || docID >= l.corpusSize {
-                continue
-            }
-            docLen := l.docLengths[docID]
-            k := l.k1 * (1 - l.b + l.b*float64(docLen)/l.avgDocLen)
-            scores[i] += idf * (qFreq[i] / (qFreq[i] + k))
-        }
-    }
+    return l.scoreDocs(context.Background(), query, docIDs, l)
+}
 
-    return scores, nil
+// GetBatchScoresContext is like GetBatchScores but stops scoring and returns
+// the error of ctx once it is done.
+func (l *BM25L) GetBatchScoresContext(ctx context.Context, query []string, docIDs []int) ([]float64, error) {
+    return l.scoreDocs(ctx, query, docIDs, l)
 }
 
 // GetTopN returns the top N documents for the given query.
 func (l *BM25L) GetTopN(query []string, n int) ([]string, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
+    return l.topNDocuments(context.Background(), query, n, l)
+}
 
-    if n <= 0 {
-        if l.logger != nil {
-            l.logger.Printf("Invalid value for n: %d. Returning empty slice.", n)
-        }
-        return []string{}, nil
-    }
+// GetTopNContext is like GetTopN but stops the evaluation and returns the
+// error of ctx once it is done.
+func (l *BM25L) GetTopNContext(ctx context.Context, query []string, n int) ([]string, error) {
+    return l.topNDocuments(ctx, query, n, l)
+}
 
-    scores, err := l.GetScores(query)
-    if err != nil {
-        return nil, err
-    }
+// Search returns the top N hits for the given query.
+func (l *BM25L) Search(query []string, n int) ([]Hit, error) {
+    return l.search(context.Background(), query, n, l)
+}
+
+// SearchContext is like Search but stops the evaluation and returns the error
+// of ctx once it is done.
+func (l *BM25L) SearchContext(ctx context.Context, query []string, n int) ([]Hit, error) {
+    return l.search(ctx, query, n, l)
+}
+
+// SearchText analyzes the query text as the documents were analyzed and
+// returns the top N hits for the resulting terms.
+func (l *BM25L) SearchText(text string, n int) ([]Hit, error) {
+    return l.searchText(context.Background(), text, n, l)
+}
+
+// SearchTextContext is like SearchText but stops the evaluation and returns
+// the error of ctx once it is done.
+func (l *BM25L) SearchTextContext(ctx context.Context, text string, n int) ([]Hit, error) {
+    return l.searchText(ctx, text, n, l)
+}
 
-    topNIndices, err := TopNIndices(scores, n)
+// termWeight returns the BM25L weighting function for the given term:
+// idf * (k1 + 1) * (ctd + delta) / (k1 + ctd + delta), where
+// ctd = tf / (1 - b + b * dl / avgdl).
+func (l *BM25L) termWeight(term string) (func(tf float64, docLen int) float64, error) {
+    idf, err := l.IDF(term)
     if err != nil {
         return nil, err
     }
 
-    topDocs := make([]string, len(topNIndices))
-    for i, idx := range topNIndices {
-        topDocs[i] = JoinTokens(l.corpus[idx], " ")
-    }
-
-    return topDocs, nil
+    return func(tf float64, docLen int) float64 {
+        ctd := tf / (1 - l.b + l.b*float64(docLen)/l.avgDocLen)
+        return idf * (l.k1 + 1) * (ctd + l.delta) / (l.k1 + ctd + l.delta)
+    }, nil
 }
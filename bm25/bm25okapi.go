@@ -1,6 +1,7 @@
 package bm25
 
 import (
+    "context"
     "errors"
     "log"
 )
@@ -13,7 +14,7 @@ type BM25Okapi struct {
 }
 
 // NewBM25Okapi creates a new instance of the BM25Okapi struct.
-func NewBM25Okapi(corpus []string, tokenizer func(string) []string, k1 float64, b float64, logger *log.Logger) (*BM25Okapi, error) {
+func NewBM25Okapi(corpus []string, tokenizer func(string) []string, k1 float64, b float64, logger *log.Logger, opts ...Option) (*BM25Okapi, error) {
     if k1 < 0 {
         return nil, errors.New("k1 must be non-negative")
     }
@@ -22,123 +23,86 @@ func NewBM25Okapi(corpus []string, tokenizer func(string) []string, k1 float64,
         return nil, errors.New("b must be between 0 and 1")
     }
 
-    base, err := NewBM25Base(corpus, tokenizer, logger)
+    base, err := NewBM25Base(corpus, tokenizer, logger, opts...)
     if err != nil {
         return nil, err
     }
 
-    return &BM25Okapi{
+    o := &BM25Okapi{
         bm25Base: base,
         k1:       k1,
         b:        b,
-    }, nil
+    }
+    o.computeMaxScores(o)
+
+    return o, nil
 }
 
 // GetScores returns the BM25 scores for the given query.
 func (o *BM25Okapi) GetScores(query []string) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
-
-    scores := make([]float64, o.corpusSize)
-    for _, q := range query {
-        qFreq := make([]float64, o.corpusSize)
-        for i, doc := range o.corpus {
-            docStr := JoinTokens(doc, " ")
-            freq, _ := CountTermFreq(q, docStr, o.tokenizer) // Ignore the error for now
-            qFreq[i] = float64(freq)
-        }
-
-        idf, err := o.IDF(q)
-        if err != nil {
-            if o.logger != nil {
-                o.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-            }
-            continue
-        }
-
-        for i, docLen := range o.docLengths {
-            k := o.k1 * (1 - o.b + o.b*float64(docLen)/o.avgDocLen)
-            scores[i] += idf * (qFreq[i] / (qFreq[i] + k))
-        }
-    }
+    return o.scoreQuery(context.Background(), query, o)
+}
 
-    return scores, nil
+// GetScoresContext is like GetScores but stops scoring and returns the error
+// of ctx once it is done.
+func (o *BM25Okapi) GetScoresContext(ctx context.Context, query []string) ([]float64, error) {
+    return o.scoreQuery(ctx, query, o)
 }
 
 // GetBatchScores returns the BM25 scores for the given query and a subset of documents.
 func (o *BM25Okapi) GetBatchScores(query []string, docIDs []int) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
-
-    if len(docIDs) == 0 {
-        return nil, errors.New("document IDs cannot be empty")
-    }
-
-    scores := make([]float64, len(docIDs))
-    for _, q := range query {
-        qFreq := make([]float64, len(docIDs))
-        for i, docID := range docIDs {
-            if docID < 0 || docID >= o.corpusSize {
-                if o.logger != nil {
-                    o.logger.Printf("Invalid document ID: %d", docID)
-                }
-                continue
-            }
-            docStr := JoinTokens(o.corpus[docID], " ")
-            freq, _ := CountTermFreq(q, docStr, o.tokenizer) // Ignore the error for now
-            qFreq[i] = float64(freq)
-        }
-
-        idf, err := o.IDF(q)
-        if err != nil {
-            if o.logger != nil {
-                o.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-            }
-            continue
-        }
-
-        for i, docID := range docIDs {
-            if docID < 0 || docID >= o.corpusSize {
-                continue
-            }
-            docLen := o.docLengths[docID]
-            k := o.k1 * (1 - o.b + o.b*float64(docLen)/o.avgDocLen)
-            scores[i] += idf * (qFreq[i] / (qFreq[i] + k))
-        }
-    }
+    return o.scoreDocs(context.Background(), query, docIDs, o)
+}
 
-    return scores, nil
+// GetBatchScoresContext is like GetBatchScores but stops scoring and returns
+// the error of ctx once it is done.
+func (o *BM25Okapi) GetBatchScoresContext(ctx context.Context, query []string, docIDs []int) ([]float64, error) {
+    return o.scoreDocs(ctx, query, docIDs, o)
 }
 
 // GetTopN returns the top N documents for the given query.
 func (o *BM25Okapi) GetTopN(query []string, n int) ([]string, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
+    return o.topNDocuments(context.Background(), query, n, o)
+}
 
-    if n <= 0 {
-        if o.logger != nil {
-            o.logger.Printf("Invalid value for n: %d. Returning empty slice.", n)
-        }
-        return []string{}, nil
-    }
+// GetTopNContext is like GetTopN but stops the evaluation and returns the
+// error of ctx once it is done.
+func (o *BM25Okapi) GetTopNContext(ctx context.Context, query []string, n int) ([]string, error) {
+    return o.topNDocuments(ctx, query, n, o)
+}
 
-    scores, err := o.GetScores(query)
-    if err != nil {
-        return nil, err
-    }
+// Search returns the top N hits for the given query.
+func (o *BM25Okapi) Search(query []string, n int) ([]Hit, error) {
+    return o.search(context.Background(), query, n, o)
+}
+
+// SearchContext is like Search but stops the evaluation and returns the error
+// of ctx once it is done.
+func (o *BM25Okapi) SearchContext(ctx context.Context, query []string, n int) ([]Hit, error) {
+    return o.search(ctx, query, n, o)
+}
 
-    topNIndices, err := TopNIndices(scores, n)
+// SearchText analyzes the query text as the documents were analyzed and
+// returns the top N hits for the resulting terms.
+func (o *BM25Okapi) SearchText(text string, n int) ([]Hit, error) {
+    return o.searchText(context.Background(), text, n, o)
+}
+
+// SearchTextContext is like SearchText but stops the evaluation and returns
+// the error of ctx once it is done.
+func (o *BM25Okapi) SearchTextContext(ctx context.Context, text string, n int) ([]Hit, error) {
+    return o.searchText(ctx, text, n, o)
+}
+
+// termWeight returns the BM25Okapi weighting function for the given term.
+func (o *BM25Okapi) termWeight(term string) (func(tf float64, docLen int) float64, error) {
+    idf, err := o.IDF(term)
     if err != nil {
         return nil, err
     }
 
-    topDocs := make([]string, len(topNIndices))
-    for i, idx := range topNIndices {
-        topDocs[i] = JoinTokens(o.corpus[idx], " ")
-    }
-
-    return topDocs, nil
+    return func(tf float64, docLen int) float64 {
+        k := o.k1 * (1 - o.b + o.b*float64(docLen)/o.avgDocLen)
+        return idf * (tf / (tf + k))
+    }, nil
 }
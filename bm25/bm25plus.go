@@ -1,6 +1,7 @@
 package bm25
 
 import (
+    "context"
     "errors"
     "log"
 )
@@ -8,14 +9,16 @@ import (
 // BM25Plus is an implementation of the BM25Plus variant.
 type BM25Plus struct {
     *bm25Base
-    k1      float64
-    b       float64
-    delta   float64
-    epsilon float64
+    k1    float64
+    b     float64
+    delta float64
 }
 
 // NewBM25Plus creates a new instance of the BM25Plus struct.
-func NewBM25Plus(corpus []string, tokenizer func(string) []string, k1 float64, b float64, delta float64, epsilon float64, logger *log.Logger) (*BM25Plus, error) {
+// IDF values are computed with BM25PlusIDF unless overridden with WithIDF, and
+// negative IDF values are floored to epsilon times the average IDF. With the
+// default IDF, scores match those of rank_bm25's BM25Plus.
+func NewBM25Plus(corpus []string, tokenizer func(string) []string, k1 float64, b float64, delta float64, epsilon float64, logger *log.Logger, opts ...Option) (*BM25Plus, error) {
     if k1 < 0 {
         return nil, errors.New("k1 must be non-negative")
     }
@@ -32,125 +35,100 @@ func NewBM25Plus(corpus []string, tokenizer func(string) []string, k1 float64, b
         return nil, errors.New("epsilon must be non-negative")
     }
 
-    base, err := NewBM25Base(corpus, tokenizer, logger)
+    base, err := NewBM25Base(corpus, tokenizer, logger, append([]Option{WithIDF(BM25PlusIDF), WithEpsilon(epsilon)}, opts...)...)
     if err != nil {
         return nil, err
     }
 
-    return &BM25Plus{
+    p := &BM25Plus{
         bm25Base: base,
         k1:       k1,
         b:        b,
         delta:    delta,
-        epsilon:  epsilon,
-    }, nil
+    }
+    p.computeMaxScores(p)
+
+    return p, nil
 }
 
 // GetScores returns the BM25 scores for the given query.
 func (p *BM25Plus) GetScores(query []string) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
-
-    scores := make([]float64, p.corpusSize)
-    for _, q := range query {
-        qFreq := make([]float64, p.corpusSize)
-        for i, doc := range p.corpus {
-            docStr := JoinTokens(doc, " ")
-            freq, _ := CountTermFreq(q, docStr, p.tokenizer) // Ignore the error for now
-            qFreq[i] = float64(freq)
-        }
-
-        idf, err := p.IDF(q)
-        if err != nil {
-            if p.logger != nil {
-                p.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-            }
-            continue
-        }
-
-        for i, docLen := range p.docLengths {
-            k := p.k1 * (1 - p.b + p.b*float64(docLen)/p.avgDocLen)
-            scores[i] += idf * (p.delta + (qFreq[i] / (qFreq[i] + k)))
-        }
-    }
+    return p.scoreQuery(context.Background(), query, p)
+}
 
-    return scores, nil
+// GetScoresContext is like GetScores but stops scoring and returns the error
+// of ctx once it is done.
+func (p *BM25Plus) GetScoresContext(ctx context.Context, query []string) ([]float64, error) {
+    return p.scoreQuery(ctx, query, p)
 }
 
 // GetBatchScores returns the BM25 scores for the given query and a subset of documents.
 func (p *BM25Plus) GetBatchScores(query []string, docIDs []int) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
-
-    if len(docIDs) == 0 {
-        return nil, errors.New("document IDs cannot be empty")
-    }
-
-    scores := make([]float64, len(docIDs))
-    for _, q := range query {
-        qFreq := make([]float64, len(docIDs))
-        for i, docID := range docIDs {
-            if docID < 0 || docID >= p.corpusSize {
-                if p.logger != nil {
-                    p.logger.Printf("Invalid document ID: %d", docID)
-                }
-                continue
-            }
-            docStr := JoinTokens(p.corpus[docID], " ")
-            freq, _ := CountTermFreq(q, docStr, p.tokenizer) // Ignore the error for now
-            qFreq[i] = float64(freq)
-        }
-
-        idf, err := p.IDF(q)
-        if err != nil {
-            if p.logger != nil {
-                p.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-            }
-            continue
-        }
-
-        for i, docID := range docIDs {
-            if docID < 0 || docID >= p.corpusSize {
-                continue
-            }
-            docLen := p.docLengths[docID]
-            k := p.k1 * (1 - p.b + p.b*float64(docLen)/p.avgDocLen)
-            scores[i] += idf * (p.delta + (qFreq[i] / (qFreq[i] + k)))
-        }
-    }
+    return p.scoreDocs(context.Background(), query, docIDs, p)
+}
 
-    return scores, nil
+// GetBatchScoresContext is like GetBatchScores but stops scoring and returns
+// the error of ctx once it is done.
+func (p *BM25Plus) GetBatchScoresContext(ctx context.Context, query []string, docIDs []int) ([]float64, error) {
+    return p.scoreDocs(ctx, query, docIDs, p)
 }
 
 // GetTopN returns the top N documents for the given query.
 func (p *BM25Plus) GetTopN(query []string, n int) ([]string, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
+    return p.topNDocuments(context.Background(), query, n, p)
+}
 
-    if n <= 0 {
-        if p.logger != nil {
-            p.logger.Printf("Invalid value for n: %d. Returning empty slice.", n)
-        }
-        return []string{}, nil
-    }
+// GetTopNContext is like GetTopN but stops the evaluation and returns the
+// error of ctx once it is done.
+func (p *BM25Plus) GetTopNContext(ctx context.Context, query []string, n int) ([]string, error) {
+    return p.topNDocuments(ctx, query, n, p)
+}
 
-    scores, err := p.GetScores(query)
-    if err != nil {
-        return nil, err
-    }
+// Search returns the top N hits for the given query.
+func (p *BM25Plus) Search(query []string, n int) ([]Hit, error) {
+    return p.search(context.Background(), query, n, p)
+}
+
+// SearchContext is like Search but stops the evaluation and returns the error
+// of ctx once it is done.
+func (p *BM25Plus) SearchContext(ctx context.Context, query []string, n int) ([]Hit, error) {
+    return p.search(ctx, query, n, p)
+}
+
+// SearchText analyzes the query text as the documents were analyzed and
+// returns the top N hits for the resulting terms.
+func (p *BM25Plus) SearchText(text string, n int) ([]Hit, error) {
+    return p.searchText(context.Background(), text, n, p)
+}
+
+// SearchTextContext is like SearchText but stops the evaluation and returns
+// the error of ctx once it is done.
+func (p *BM25Plus) SearchTextContext(ctx context.Context, text string, n int) ([]Hit, error) {
+    return p.searchText(ctx, text, n, p)
+}
 
-    topNIndices, err := TopNIndices(scores, n)
+// termWeight returns the BM25Plus weighting function for the given term:
+// idf * tf * (k1 + 1) / (tf + k1 * (1 - b + b * dl / avgdl)), without the
+// delta that termFloor gives every document.
+func (p *BM25Plus) termWeight(term string) (func(tf float64, docLen int) float64, error) {
+    idf, err := p.IDF(term)
     if err != nil {
         return nil, err
     }
 
-    topDocs := make([]string, len(topNIndices))
-    for i, idx := range topNIndices {
-        topDocs[i] = JoinTokens(p.corpus[idx], " ")
-    }
+    return func(tf float64, docLen int) float64 {
+        k := p.k1 * (1 - p.b + p.b*float64(docLen)/p.avgDocLen)
+        return idf * (tf * (p.k1 + 1) / (tf + k))
+    }, nil
+}
 
-    return topDocs, nil
+// termFloor returns idf * delta, the lower bound that BM25Plus adds to the
+// score of every document for the term, whether or not the document contains
+// it.
+func (p *BM25Plus) termFloor(term string) (float64, error) {
+    idf, err := p.IDF(term)
+    if err != nil {
+        return 0, err
+    }
+    return idf * p.delta, nil
 }
@@ -1,20 +1,40 @@
 package bm25
 
 import (
+    "context"
     "errors"
     "log"
+    "math"
+    "sync"
 )
 
-// BM25T is an implementation of the BM25T variant.
+// BM25T is an implementation of the BM25T variant (Lv and Zhai, "A
+// Log-logistic Model-based Interpretation of TF Normalization of BM25"). Each
+// term gets its own k1, solved with Newton-Raphson at construction so that the
+// log-logistic elite-set condition
+//
+//     k1 * ln(k1) / (k1 - 1) = mean over D containing t of ln(1 + ctd)
+//
+// holds, where ctd = tf / (1 - b + b * dl / avgdl).
 type BM25T struct {
     *bm25Base
-    k1    float64
-    b     float64
-    delta float64
+    k1       float64
+    b        float64
+    termK1Mu sync.RWMutex
+    termK1   map[string]eliteK1
+}
+
+// eliteK1 holds the k1 BM25T solved for a single term. solved is false if the
+// Newton-Raphson iterations did not converge.
+type eliteK1 struct {
+    k1     float64
+    solved bool
 }
 
 // NewBM25T creates a new instance of the BM25T struct.
-func NewBM25T(corpus []string, tokenizer func(string) []string, k1 float64, b float64, delta float64, logger *log.Logger) (*BM25T, error) {
+// k1 is the starting point of the Newton-Raphson iterations and the fallback
+// for terms whose k1 cannot be solved.
+func NewBM25T(corpus []string, tokenizer func(string) []string, k1 float64, b float64, logger *log.Logger, opts ...Option) (*BM25T, error) {
     if k1 < 0 {
         return nil, errors.New("k1 must be non-negative")
     }
@@ -23,128 +43,202 @@ func NewBM25T(corpus []string, tokenizer func(string) []string, k1 float64, b fl
         return nil, errors.New("b must be between 0 and 1")
     }
 
-    if delta < 0 {
-        return nil, errors.New("delta must be non-negative")
-    }
-
-    base, err := NewBM25Base(corpus, tokenizer, logger)
+    base, err := NewBM25Base(corpus, tokenizer, logger, opts...)
     if err != nil {
         return nil, err
     }
 
-    return &BM25T{
+    t := &BM25T{
         bm25Base: base,
         k1:       k1,
         b:        b,
-        delta:    delta,
-    }, nil
+    }
+    t.solveTermK1()
+    t.computeMaxScores(t)
+
+    return t, nil
 }
 
-// GetScores returns the BM25 scores for the given query.
-func (t *BM25T) GetScores(query []string) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
+// solveTermK1 solves the k1 of every term in the vocabulary.
+func (t *BM25T) solveTermK1() {
+    t.termK1Mu.Lock()
+    t.termK1 = make(map[string]eliteK1, t.vocabularySize())
+    t.termK1Mu.Unlock()
 
-    scores := make([]float64, t.corpusSize)
-    for _, q := range query {
-        qFreq := make([]float64, t.corpusSize)
-        for i, doc := range t.corpus {
-            docStr := JoinTokens(doc, " ")
-            freq, _ := CountTermFreq(q, docStr, t.tokenizer) // Ignore the error for now
-            qFreq[i] = float64(freq)
+    var solved int
+    t.forEachTerm(func(term string, docFreq int) {
+        if t.solveK1(term).solved {
+            solved++
         }
+    })
 
-        idf, err := t.IDF(q)
-        if err != nil {
-            if t.logger != nil {
-                t.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-            }
-            continue
-        }
+    if t.logger != nil {
+        t.logger.Printf("BM25T solved k1 for %d of %d terms", solved, t.vocabularySize())
+    }
+}
 
-        for i, docLen := range t.docLengths {
-            k := t.k1 * (1 - t.b + t.b*float64(docLen)/t.avgDocLen)
-            scores[i] += idf * (t.delta + (qFreq[i] * (1 + k)) / (qFreq[i] + k))
-        }
+// solveK1 returns the k1 of the given term, solving it if the index changed
+// since it was last solved.
+func (t *BM25T) solveK1(term string) eliteK1 {
+    t.termK1Mu.RLock()
+    solved, ok := t.termK1[term]
+    t.termK1Mu.RUnlock()
+    if ok {
+        return solved
     }
 
-    return scores, nil
+    docFreq, _, ok := t.termStats(term)
+    if !ok {
+        return eliteK1{}
+    }
+
+    var sum float64
+    t.forEachPosting(term, func(docID, freq int) {
+        ctd := float64(freq) / (1 - t.b + t.b*float64(t.docLengths.at(docID))/t.avgDocLen)
+        sum += math.Log(1 + ctd)
+    })
+
+    k1, ok := solveEliteK1(sum/float64(docFreq), t.k1)
+    solved = eliteK1{k1: k1, solved: ok}
+    t.termK1Mu.Lock()
+    t.termK1[term] = solved
+    t.termK1Mu.Unlock()
+    return solved
 }
 
-// GetBatchScores returns the BM25 scores for the given query and a subset of documents.
-func (t *BM25T) GetBatchScores(query []string, docIDs []int) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
+// resetTermParams drops the solved k1 values after the average document
+// length they depend on changed. They are solved again on demand.
+func (t *BM25T) resetTermParams() {
+    t.termK1Mu.Lock()
+    defer t.termK1Mu.Unlock()
+    t.termK1 = make(map[string]eliteK1)
+}
+
+// eliteK1Func evaluates g(k1) = k1 * ln(k1) / (k1 - 1) and its derivative,
+// using the limit g(1) = 1, g'(1) = 1/2 around k1 = 1.
+func eliteK1Func(k1 float64) (float64, float64) {
+    d := k1 - 1
+    if math.Abs(d) < 1e-6 {
+        return 1 + d/2, 0.5 - d/6
     }
+    ln := math.Log(k1)
+    return k1 * ln / d, (d - ln) / (d * d)
+}
 
-    if len(docIDs) == 0 {
-        return nil, errors.New("document IDs cannot be empty")
+// solveEliteK1 solves g(k1) = target with Newton-Raphson starting from start.
+// It reports false if the iterations do not converge.
+func solveEliteK1(target, start float64) (float64, bool) {
+    if target <= 0 || math.IsNaN(target) || math.IsInf(target, 0) {
+        return 0, false
     }
 
-    scores := make([]float64, len(docIDs))
-    for _, q := range query {
-        qFreq := make([]float64, len(docIDs))
-        for i, docID := range docIDs {
-            if docID < 0 || docID >= t.corpusSize {
-                if t.logger != nil {
-                    t.logger.Printf("Invalid document ID: %d", docID)
-                }
-                continue
-            }
-            docStr := JoinTokens(t.corpus[docID], " ")
-            freq, _ := CountTermFreq(q, docStr, t.tokenizer) // Ignore the error for now
-            qFreq[i] = float64(freq)
-        }
+    k1 := start
+    if k1 <= 0 {
+        k1 = 1
+    }
 
-        idf, err := t.IDF(q)
-        if err != nil {
-            if t.logger != nil {
-                t.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-            }
-            continue
+    for i := 0; i < 100; i++ {
+        g, dg := eliteK1Func(k1)
+        diff := g - target
+        if math.Abs(diff) < 1e-12 {
+            return k1, true
+        }
+        if dg <= 0 {
+            return 0, false
         }
 
-        for i, docID := range docIDs {
-            if docID < 0 || docID >= t.corpusSize {
-                continue
-            }
-            docLen := t.docLengths[docID]
-            k := t.k1 * (1 - t.b + t.b*float64(docLen)/t.avgDocLen)
-            scores[i] += idf * (t.delta + (qFreq[i] * (1 + k)) / (qFreq[i] + k))
+        next := k1 - diff/dg
+        // g is only defined for positive k1; step back towards zero instead of
+        // overshooting past it.
+        if next <= 0 {
+            next = k1 / 2
         }
+        if math.Abs(next-k1) < 1e-12*k1 {
+            return next, true
+        }
+        k1 = next
+    }
+
+    return 0, false
+}
+
+// TermK1 returns the k1 used for the given term and whether it was solved from
+// the term's statistics rather than taken from the constructor.
+func (t *BM25T) TermK1(term string) (float64, bool) {
+    if k1 := t.solveK1(term); k1.solved {
+        return k1.k1, true
     }
+    return t.k1, false
+}
+
+// GetScores returns the BM25 scores for the given query.
+func (t *BM25T) GetScores(query []string) ([]float64, error) {
+    return t.scoreQuery(context.Background(), query, t)
+}
 
-    return scores, nil
+// GetScoresContext is like GetScores but stops scoring and returns the error
+// of ctx once it is done.
+func (t *BM25T) GetScoresContext(ctx context.Context, query []string) ([]float64, error) {
+    return t.scoreQuery(ctx, query, t)
+}
+
+// GetBatchScores returns the BM25 scores for the given query and a subset of documents.
+func (t *BM25T) GetBatchScores(query []string, docIDs []int) ([]float64, error) {
+    return t.scoreDocs(context.Background(), query, docIDs, t)
+}
+
+// GetBatchScoresContext is like GetBatchScores but stops scoring and returns
+// the error of ctx once it is done.
+func (t *BM25T) GetBatchScoresContext(ctx context.Context, query []string, docIDs []int) ([]float64, error) {
+    return t.scoreDocs(ctx, query, docIDs, t)
 }
 
 // GetTopN returns the top N documents for the given query.
 func (t *BM25T) GetTopN(query []string, n int) ([]string, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
+    return t.topNDocuments(context.Background(), query, n, t)
+}
 
-    if n <= 0 {
-        if t.logger != nil {
-            t.logger.Printf("Invalid value for n: %d. Returning empty slice.", n)
-        }
-        return []string{}, nil
-    }
+// GetTopNContext is like GetTopN but stops the evaluation and returns the
+// error of ctx once it is done.
+func (t *BM25T) GetTopNContext(ctx context.Context, query []string, n int) ([]string, error) {
+    return t.topNDocuments(ctx, query, n, t)
+}
 
-    scores, err := t.GetScores(query)
-    if err != nil {
-        return nil, err
-    }
+// Search returns the top N hits for the given query.
+func (t *BM25T) Search(query []string, n int) ([]Hit, error) {
+    return t.search(context.Background(), query, n, t)
+}
+
+// SearchContext is like Search but stops the evaluation and returns the error
+// of ctx once it is done.
+func (t *BM25T) SearchContext(ctx context.Context, query []string, n int) ([]Hit, error) {
+    return t.search(ctx, query, n, t)
+}
 
-    topNIndices, err := TopNIndices(scores, n)
+// SearchText analyzes the query text as the documents were analyzed and
+// returns the top N hits for the resulting terms.
+func (t *BM25T) SearchText(text string, n int) ([]Hit, error) {
+    return t.searchText(context.Background(), text, n, t)
+}
+
+// SearchTextContext is like SearchText but stops the evaluation and returns
+// the error of ctx once it is done.
+func (t *BM25T) SearchTextContext(ctx context.Context, text string, n int) ([]Hit, error) {
+    return t.searchText(ctx, text, n, t)
+}
+
+// termWeight returns the BM25T weighting function for the given term:
+// idf * (k1 + 1) * tf / (k1 * (1 - b + b * dl / avgdl) + tf) with the
+// term-specific k1.
+func (t *BM25T) termWeight(term string) (func(tf float64, docLen int) float64, error) {
+    idf, err := t.IDF(term)
     if err != nil {
         return nil, err
     }
+    k1, _ := t.TermK1(term)
 
-    topDocs := make([]string, len(topNIndices))
-    for i, idx := range topNIndices {
-        topDocs[i] = JoinTokens(t.corpus[idx], " ")
-    }
-
-    return topDocs, nil
+    return func(tf float64, docLen int) float64 {
+        k := k1 * (1 - t.b + t.b*float64(docLen)/t.avgDocLen)
+        return idf * (k1 + 1) * tf / (k + tf)
+    }, nil
 }
@@ -0,0 +1,31 @@
+package bm25
+
+import "context"
+
+// cancelCheckInterval is the number of steps of a scoring loop between two
+// checks of the context, which keeps the cost of the checks negligible while
+// still returning promptly once the context is done.
+const cancelCheckInterval = 1024
+
+// canceller checks a context from inside a scoring loop. It is not safe for
+// concurrent use: every goroutine of a parallel computation has its own.
+type canceller struct {
+    ctx   context.Context
+    steps int
+}
+
+// newCanceller creates a canceller for the given context.
+func newCanceller(ctx context.Context) *canceller {
+    return &canceller{ctx: ctx}
+}
+
+// step counts one step of the loop and returns the error of the context,
+// which is only checked every cancelCheckInterval steps.
+func (c *canceller) step() error {
+    c.steps++
+    if c.steps < cancelCheckInterval {
+        return nil
+    }
+    c.steps = 0
+    return c.ctx.Err()
+}
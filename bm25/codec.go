@@ -0,0 +1,156 @@
+package bm25
+
+import "strconv"
+
+// PostingCodec selects how the posting lists of an index are compressed. The
+// codec is recorded in the files written by Save and SaveMapped, by its
+// numeric value, so that Load and OpenMapped restore it.
+type PostingCodec uint8
+
+const (
+    // VByteCodec stores every value in variable-byte encoding. Most document
+    // ID gaps and frequencies take a single byte.
+    VByteCodec PostingCodec = iota + 1
+    // BitpackCodec packs all the values of a block with the bit width of the
+    // largest one, in the style of SIMD-BP128. It is plain bit-packing, with
+    // no exceptions as in PFor, so a single large gap or frequency widens
+    // every value of its block.
+    BitpackCodec
+)
+
+// DefaultPostingCodec is the posting codec of the indexes built without a
+// WithPostingCodec option.
+const DefaultPostingCodec = VByteCodec
+
+// String returns the name of the posting codec.
+func (c PostingCodec) String() string {
+    switch c {
+    case VByteCodec:
+        return "vbyte"
+    case BitpackCodec:
+        return "bitpack"
+    }
+    return "PostingCodec(" + strconv.Itoa(int(c)) + ")"
+}
+
+// blockCodec returns the implementation of the posting codec, or nil if the
+// codec is unknown.
+func (c PostingCodec) blockCodec() blockCodec {
+    switch c {
+    case VByteCodec:
+        return vbyteCodec{}
+    case BitpackCodec:
+        return bitpackCodec{}
+    }
+    return nil
+}
+
+// blockCodec encodes the values of a posting block: the document ID gaps or
+// the frequencies, both stored minus one so that they start at zero.
+type blockCodec interface {
+    // appendBlock appends the encoded values to dst.
+    appendBlock(dst []byte, vals []uint32) []byte
+    // readBlock decodes len(vals) values from src and returns the number of
+    // bytes read, or -1 if src is too short or malformed.
+    readBlock(src []byte, vals []uint32) int
+}
+
+// vbyteCodec stores every value in variable-byte encoding: seven bits per
+// byte, least significant group first, with the high bit set on all bytes but
+// the last. Small gaps and frequencies, the common case, take a single byte.
+type vbyteCodec struct{}
+
+func (vbyteCodec) appendBlock(dst []byte, vals []uint32) []byte {
+    for _, v := range vals {
+        for v >= 0x80 {
+            dst = append(dst, byte(v)|0x80)
+            v >>= 7
+        }
+        dst = append(dst, byte(v))
+    }
+    return dst
+}
+
+func (vbyteCodec) readBlock(src []byte, vals []uint32) int {
+    pos := 0
+    for i := range vals {
+        var v uint32
+        for shift := uint(0); ; shift += 7 {
+            if pos == len(src) || shift > 28 {
+                return -1
+            }
+            c := src[pos]
+            pos++
+            v |= uint32(c&0x7f) << shift
+            if c < 0x80 {
+                break
+            }
+        }
+        vals[i] = v
+    }
+    return pos
+}
+
+// bitpackCodec packs all the values of a block with the same number of bits,
+// the width of the largest value, in the manner of SIMD-BP128 but with scalar
+// code. The block starts with a byte holding the width, followed by the values
+// packed least significant bit first. Decoding is branch-free within a block.
+// There are no exceptions: a single value much larger than the others makes
+// the whole block wider.
+type bitpackCodec struct{}
+
+func (bitpackCodec) appendBlock(dst []byte, vals []uint32) []byte {
+    var or uint32
+    for _, v := range vals {
+        or |= v
+    }
+    width := uint(0)
+    for or != 0 {
+        width++
+        or >>= 1
+    }
+    dst = append(dst, byte(width))
+
+    var acc uint64
+    var bits uint
+    for _, v := range vals {
+        acc |= uint64(v) << bits
+        bits += width
+        for bits >= 8 {
+            dst = append(dst, byte(acc))
+            acc >>= 8
+            bits -= 8
+        }
+    }
+    if bits > 0 {
+        dst = append(dst, byte(acc))
+    }
+    return dst
+}
+
+func (bitpackCodec) readBlock(src []byte, vals []uint32) int {
+    if len(src) == 0 || src[0] > 32 {
+        return -1
+    }
+    width := uint(src[0])
+    size := 1 + (len(vals)*int(width)+7)/8
+    if len(src) < size {
+        return -1
+    }
+
+    mask := uint64(1)<<width - 1
+    var acc uint64
+    var bits uint
+    pos := 1
+    for i := range vals {
+        for bits < width {
+            acc |= uint64(src[pos]) << bits
+            pos++
+            bits += 8
+        }
+        vals[i] = uint32(acc & mask)
+        acc >>= width
+        bits -= width
+    }
+    return size
+}
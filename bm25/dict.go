@@ -0,0 +1,127 @@
+package bm25
+
+import (
+    "encoding/binary"
+    "sort"
+)
+
+// dictBlockSize is the number of terms in each front-coded block of a term
+// dictionary.
+const dictBlockSize = 16
+
+// termDict is an immutable dictionary of sorted terms, mapping each term to
+// its ordinal, its position in sorted order. Terms are front-coded in blocks
+// of dictBlockSize: the first term of a block is stored in full and each of
+// the others as the length of the prefix it shares with the previous term
+// followed by the rest of the term. Sorted terms share long prefixes, so the
+// dictionary takes a fraction of the memory of a map, and a lookup is a binary
+// search over the first terms of the blocks followed by a scan of one block.
+type termDict struct {
+    numTerms int
+    data     []byte
+    blocks   []uint32
+}
+
+// newTermDict builds a dictionary of the given terms, which must be sorted
+// and unique.
+func newTermDict(terms []string) *termDict {
+    d := &termDict{numTerms: len(terms)}
+    var tmp [binary.MaxVarintLen64]byte
+    for i, term := range terms {
+        shared := 0
+        if i%dictBlockSize == 0 {
+            d.blocks = append(d.blocks, uint32(len(d.data)))
+        } else {
+            prev := terms[i-1]
+            for shared < len(prev) && shared < len(term) && prev[shared] == term[shared] {
+                shared++
+            }
+            d.data = append(d.data, tmp[:binary.PutUvarint(tmp[:], uint64(shared))]...)
+        }
+        d.data = append(d.data, tmp[:binary.PutUvarint(tmp[:], uint64(len(term)-shared))]...)
+        d.data = append(d.data, term[shared:]...)
+    }
+    return d
+}
+
+// blockFirst returns the first term of the given block.
+func (d *termDict) blockFirst(blk int) []byte {
+    data := d.data[d.blocks[blk]:]
+    n, read := binary.Uvarint(data)
+    return data[read : read+int(n)]
+}
+
+// dictIterator walks the terms of a dictionary in order, rebuilding each
+// front-coded term from the previous one.
+type dictIterator struct {
+    d    *termDict
+    ord  int
+    pos  int
+    term []byte
+}
+
+// iterator returns an iterator positioned on the term with the given ordinal.
+func (d *termDict) iterator(ord int) *dictIterator {
+    it := &dictIterator{d: d, ord: ord - ord%dictBlockSize}
+    if it.ord < d.numTerms {
+        it.pos = int(d.blocks[it.ord/dictBlockSize])
+        it.decode()
+    }
+    for it.ord < ord {
+        it.next()
+    }
+    return it
+}
+
+// valid reports whether the iterator is positioned on a term.
+func (it *dictIterator) valid() bool {
+    return it.ord < it.d.numTerms
+}
+
+// next moves the iterator to the next term.
+func (it *dictIterator) next() {
+    it.ord++
+    if it.valid() {
+        it.decode()
+    }
+}
+
+// decode reads the term at the current position.
+func (it *dictIterator) decode() {
+    data := it.d.data
+    shared := 0
+    if it.ord%dictBlockSize != 0 {
+        v, read := binary.Uvarint(data[it.pos:])
+        shared = int(v)
+        it.pos += read
+    }
+    n, read := binary.Uvarint(data[it.pos:])
+    it.pos += read
+    it.term = append(it.term[:shared], data[it.pos:it.pos+int(n)]...)
+    it.pos += int(n)
+}
+
+// seek returns an iterator positioned on the first term that is not less
+// than term.
+func (d *termDict) seek(term string) *dictIterator {
+    // The last block whose first term is not greater than term holds it, if
+    // any block does.
+    blk := sort.Search(len(d.blocks), func(i int) bool {
+        return string(d.blockFirst(i)) > term
+    }) - 1
+    if blk < 0 {
+        blk = 0
+    }
+    it := d.iterator(blk * dictBlockSize)
+    for it.valid() && string(it.term) < term {
+        it.next()
+    }
+    return it
+}
+
+// lookup returns the ordinal of the term, and whether it is in the
+// dictionary.
+func (d *termDict) lookup(term string) (int, bool) {
+    it := d.seek(term)
+    return it.ord, it.valid() && string(it.term) == term
+}
@@ -0,0 +1,76 @@
+package bm25
+
+import (
+    "context"
+    "strconv"
+)
+
+// Evaluation selects the algorithm GetTopN and Search use to find the best
+// documents for a query. All of them return exactly the same documents and
+// scores as exhaustive scoring; they differ in how many postings they skip.
+type Evaluation int
+
+const (
+    // EvalWAND skips documents whose term upper bounds cannot beat the current
+    // N-th best score. It is the default.
+    EvalWAND Evaluation = iota
+    // EvalBlockMaxWAND refines WAND with the upper bounds of each block of
+    // postings, skipping whole blocks of low-scoring documents.
+    EvalBlockMaxWAND
+    // EvalMaxScore splits the query terms into essential and non-essential
+    // terms and only looks up the non-essential terms for candidate documents.
+    EvalMaxScore
+    // EvalExhaustive scores every document containing a query term.
+    EvalExhaustive
+)
+
+// String returns the name of the evaluation strategy.
+func (e Evaluation) String() string {
+    switch e {
+    case EvalWAND:
+        return "WAND"
+    case EvalBlockMaxWAND:
+        return "BlockMaxWAND"
+    case EvalMaxScore:
+        return "MaxScore"
+    case EvalExhaustive:
+        return "Exhaustive"
+    }
+    return "Evaluation(" + strconv.Itoa(int(e)) + ")"
+}
+
+// evaluate returns the n best matching documents for the query with the
+// configured evaluation strategy. It reports false if the strategy cannot be
+// used for the query, in which case the caller scores exhaustively, and
+// returns the error of the context if it is done before the evaluation ends.
+func (b *bm25Base) evaluate(ctx context.Context, query []string, n int, s termScorer) ([]scoredDoc, bool, error) {
+    var strategy func(cursors []*wandCursor, h *topKHeap, cc *canceller) error
+    switch b.evaluation {
+    case EvalWAND:
+        strategy = b.wand
+    case EvalBlockMaxWAND:
+        strategy = b.blockMaxWAND
+    case EvalMaxScore:
+        strategy = b.maxScore
+    default:
+        return nil, false, nil
+    }
+
+    // Segments hold increasing ranges of document IDs, so evaluating them in
+    // order with a shared heap visits the documents in the same order as a
+    // single index would, and the threshold carries over between segments.
+    weights := b.queryWeights(query, s)
+    h := newTopKHeap(n)
+    cc := newCanceller(ctx)
+    for _, seg := range b.segmentsSnapshot() {
+        cursors, ok := b.queryCursors(seg, query, weights)
+        if !ok {
+            return nil, false, nil
+        }
+        if err := strategy(cursors, h, cc); err != nil {
+            return nil, false, err
+        }
+    }
+
+    return h.sorted(), true, nil
+}
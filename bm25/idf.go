@@ -0,0 +1,50 @@
+package bm25
+
+import "math"
+
+// IDFFunc computes the inverse document frequency of a term that occurs in
+// docFreq of the corpusSize documents in the corpus.
+type IDFFunc func(docFreq, corpusSize int) float64
+
+// LuceneIDF is the IDF used by Lucene and Elasticsearch:
+// log(1 + (N - n + 0.5) / (n + 0.5)). It is always positive, so terms found in
+// every document still contribute to the score.
+func LuceneIDF(docFreq, corpusSize int) float64 {
+    n, N := float64(docFreq), float64(corpusSize)
+    return math.Log(1 + (N-n+0.5)/(n+0.5))
+}
+
+// RobertsonSparckJonesIDF is the classic BM25 IDF log((N - n + 0.5) / (n + 0.5))
+// used by rank_bm25's BM25Okapi. It becomes negative for terms found in more
+// than half of the documents.
+func RobertsonSparckJonesIDF(docFreq, corpusSize int) float64 {
+    n, N := float64(docFreq), float64(corpusSize)
+    return math.Log((N - n + 0.5) / (n + 0.5))
+}
+
+// SmoothedIDF is the IDF used by BM25L: log((N + 1) / (n + 0.5)).
+func SmoothedIDF(docFreq, corpusSize int) float64 {
+    n, N := float64(docFreq), float64(corpusSize)
+    return math.Log((N + 1) / (n + 0.5))
+}
+
+// BM25PlusIDF is the IDF of BM25+ as published by Lv and Zhai and used by
+// rank_bm25's BM25Plus: log((N + 1) / n). It is always positive for the terms
+// of the corpus, and zero for unseen terms.
+func BM25PlusIDF(docFreq, corpusSize int) float64 {
+    if docFreq <= 0 {
+        return 0
+    }
+    n, N := float64(docFreq), float64(corpusSize)
+    return math.Log((N + 1) / n)
+}
+
+// ProbabilisticIDF is the probabilistic IDF log((N - n) / n), clamped at zero
+// for terms found in half of the documents or more.
+func ProbabilisticIDF(docFreq, corpusSize int) float64 {
+    if docFreq <= 0 || 2*docFreq >= corpusSize {
+        return 0
+    }
+    n, N := float64(docFreq), float64(corpusSize)
+    return math.Log((N - n) / n)
+}
@@ -0,0 +1,499 @@
+package bm25
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "hash/crc32"
+    "io"
+    "log"
+    "math"
+    "sort"
+)
+
+// MappedFormatVersion is the version of the memory-mapped index file format
+// written by SaveMapped. Version 3 added the total document length and the
+// average IDF to the header.
+const MappedFormatVersion = 3
+
+// mappedMagic starts every memory-mapped index file.
+var mappedMagic = [8]byte{'B', 'M', '2', '5', 'M', 'A', 'P', 0}
+
+// ErrReadOnly is returned by the methods that would modify a memory-mapped
+// index.
+var ErrReadOnly = errors.New("bm25: memory-mapped index is read-only")
+
+// The sections of a memory-mapped index file, in file order. Fixed-width
+// tables come before the variable-width data they index into, so that a
+// lookup only touches the pages it needs.
+const (
+    sectionIDFName    = iota // name of the IDF function
+    sectionDocLengths        // uint32 per document
+    sectionDocOffsets        // uint64 per document, plus the end offset
+    sectionDocText           // document texts
+    sectionIDOffsets         // uint64 per document, plus the end offset, if external IDs are given
+    sectionIDText            // external IDs
+    sectionTermOffsets       // uint64 per term, plus the end offset
+    sectionTermText          // terms, in order
+    sectionTermStats         // document frequency, collection frequency and postings offset per term
+    sectionPostings          // skip entries and compressed blocks, per term
+    numSections
+)
+
+// The header holds the magic, the format version, the posting codec, the
+// variant, its parameters, the counts, the total document length, the
+// average IDF, the CRC-32 of the sections and the section offsets.
+const (
+    mappedHeaderFields = 14
+    mappedHeaderSize   = 8 + 8*mappedHeaderFields + 8*(numSections+1)
+    termStatsSize      = 24
+)
+
+// mappedFile is a memory-mapped index file. Everything is read from the
+// mapping on demand.
+type mappedFile struct {
+    data     []byte
+    sections [numSections][]byte
+    numDocs  int
+    numTerms int
+    hasIDs   bool
+    codec    PostingCodec
+}
+
+// MappedIndex is a read-only scorer backed by a memory-mapped index file. It
+// starts without reading the corpus: the collection statistics come from the
+// header, and the document lengths, the term dictionary, the postings and the
+// documents are read from the mapping as queries need them, so the operating
+// system shares the pages of the file between processes.
+//
+// MappedIndex implements BM25 through the scorer of the saved variant.
+// AddDocuments, DeleteDocument, UpdateDocument and Save return ErrReadOnly.
+// The scorer must not be used after Close.
+type MappedIndex struct {
+    BM25
+    file  *mappedFile
+    unmap func() error
+}
+
+// SaveMapped writes the index of the BM25Okapi to w in the memory-mapped
+// format read by OpenMapped.
+func (o *BM25Okapi) SaveMapped(w io.Writer) error {
+    return o.saveMapped(w, variantParams{kind: variantOkapi, k1: o.k1, b: o.b})
+}
+
+// SaveMapped writes the index of the BM25L to w in the memory-mapped format
+// read by OpenMapped.
+func (l *BM25L) SaveMapped(w io.Writer) error {
+    return l.saveMapped(w, variantParams{kind: variantL, k1: l.k1, b: l.b, delta: l.delta})
+}
+
+// SaveMapped writes the index of the BM25Plus to w in the memory-mapped
+// format read by OpenMapped.
+func (p *BM25Plus) SaveMapped(w io.Writer) error {
+    return p.saveMapped(w, variantParams{kind: variantPlus, k1: p.k1, b: p.b, delta: p.delta})
+}
+
+// SaveMapped writes the index of the BM25Adpt to w in the memory-mapped
+// format read by OpenMapped.
+func (a *BM25Adpt) SaveMapped(w io.Writer) error {
+    return a.saveMapped(w, variantParams{kind: variantAdpt, k1: a.k1, b: a.b, delta: a.delta})
+}
+
+// SaveMapped writes the index of the BM25T to w in the memory-mapped format
+// read by OpenMapped.
+func (t *BM25T) SaveMapped(w io.Writer) error {
+    return t.saveMapped(w, variantParams{kind: variantT, k1: t.k1, b: t.b})
+}
+
+// saveMapped writes the memory-mapped index file. The file has no room for
+// deleted documents, so the index must be compacted first. A memory-mapped
+// index is written back as is.
+func (b *bm25Base) saveMapped(w io.Writer, params variantParams) error {
+    if b.file != nil {
+        _, err := w.Write(b.file.data)
+        return err
+    }
+    if b.numDeleted > 0 {
+        return errors.New("index has deleted documents, compact it before saving it as a memory-mapped index")
+    }
+
+    var sections [numSections]encoder
+    sections[sectionIDFName].buf.WriteString(idfName(b.idf))
+    for docID, doc := range b.docs {
+        sections[sectionDocLengths].uint32(uint32(b.docLengths.at(docID)))
+        sections[sectionDocOffsets].uint64(uint64(sections[sectionDocText].buf.Len()))
+        sections[sectionDocText].buf.WriteString(doc)
+    }
+    sections[sectionDocOffsets].uint64(uint64(sections[sectionDocText].buf.Len()))
+    if b.externalIDs != nil {
+        for _, id := range b.externalIDs {
+            sections[sectionIDOffsets].uint64(uint64(sections[sectionIDText].buf.Len()))
+            sections[sectionIDText].buf.WriteString(id)
+        }
+        sections[sectionIDOffsets].uint64(uint64(sections[sectionIDText].buf.Len()))
+    }
+
+    // The postings of a term are the concatenation of its postings in every
+    // segment, which are already in document order.
+    segs := b.segmentsSnapshot()
+    b.vocab.forRange("", "", func(term string, f termFreqs) bool {
+        sections[sectionTermOffsets].uint64(uint64(sections[sectionTermText].buf.Len()))
+        sections[sectionTermText].buf.WriteString(term)
+        sections[sectionTermStats].uint64(uint64(f.docFreq))
+        sections[sectionTermStats].uint64(uint64(f.collFreq))
+        sections[sectionTermStats].uint64(uint64(sections[sectionPostings].buf.Len()))
+        pb := &postingsBuilder{}
+        for _, seg := range segs {
+            p, ok := seg.postingList(term)
+            if !ok {
+                continue
+            }
+            for it := p.iterator(); it.valid(); it.next() {
+                pb.add(it.doc(), it.freq())
+            }
+        }
+        p := pb.build(b.codec)
+        sections[sectionPostings].buf.Write(p.skips)
+        sections[sectionPostings].buf.Write(p.data)
+        return true
+    })
+    sections[sectionTermOffsets].uint64(uint64(sections[sectionTermText].buf.Len()))
+
+    crc := crc32.NewIEEE()
+    for i := range sections {
+        crc.Write(sections[i].buf.Bytes())
+    }
+
+    header := &encoder{}
+    header.buf.Write(mappedMagic[:])
+    header.uint64(MappedFormatVersion)
+    header.uint64(uint64(b.codec))
+    header.uint64(uint64(params.kind))
+    header.float(params.k1)
+    header.float(params.b)
+    header.float(params.delta)
+    header.float(b.epsilon)
+    if b.floorIDF {
+        header.uint64(1)
+    } else {
+        header.uint64(0)
+    }
+    header.uint64(uint64(b.evaluation))
+    header.uint64(uint64(len(b.docs)))
+    header.uint64(uint64(b.vocab.size))
+    header.uint64(uint64(b.totalDocLen))
+    header.float(b.averageIDF)
+    header.uint64(uint64(crc.Sum32()))
+    offset := uint64(mappedHeaderSize)
+    for i := range sections {
+        header.uint64(offset)
+        offset += uint64(sections[i].buf.Len())
+    }
+    header.uint64(offset)
+
+    if _, err := w.Write(header.buf.Bytes()); err != nil {
+        return err
+    }
+    for i := range sections {
+        if _, err := w.Write(sections[i].buf.Bytes()); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// OpenMapped opens a memory-mapped index file written by SaveMapped and
+// returns a read-only scorer of the saved variant. The options are applied
+// after the saved settings; an index built with a custom IDF function can only
+// be opened with a WithIDF option.
+//
+// The structure of the file is checked when it is opened, but its contents are
+// not read, so a damaged file may give wrong scores; Verify checks its
+// checksum. OpenMapped returns ErrNotIndexFile, a *VersionError or
+// ErrCorruptIndex if the file is not a valid memory-mapped index file.
+func OpenMapped(path string, logger *log.Logger, opts ...Option) (*MappedIndex, error) {
+    data, unmap, err := mapFile(path)
+    if err != nil {
+        return nil, err
+    }
+
+    m, err := newMappedIndex(data, logger, opts...)
+    if err != nil {
+        unmap()
+        return nil, err
+    }
+    m.unmap = unmap
+    return m, nil
+}
+
+// newMappedIndex creates the scorer of a memory-mapped index file.
+func newMappedIndex(data []byte, logger *log.Logger, opts ...Option) (*MappedIndex, error) {
+    if len(data) < len(mappedMagic) || !bytes.Equal(data[:len(mappedMagic)], mappedMagic[:]) {
+        return nil, ErrNotIndexFile
+    }
+    if len(data) < mappedHeaderSize {
+        return nil, ErrCorruptIndex
+    }
+    d := &decoder{data: data[len(mappedMagic):mappedHeaderSize]}
+    if version := d.uint64(); version != MappedFormatVersion {
+        return nil, &VersionError{Version: uint32(Min(int(version), math.MaxUint32))}
+    }
+    codecID := d.uint64()
+    codec := PostingCodec(codecID)
+    if codecID > math.MaxUint8 || codec.blockCodec() == nil {
+        return nil, ErrCorruptIndex
+    }
+
+    params := variantParams{kind: variantKind(d.uint64())}
+    params.k1 = d.float()
+    params.b = d.float()
+    params.delta = d.float()
+    base := &bm25Base{
+        mergePolicy:     NoMergePolicy{},
+        maxBufferedDocs: DefaultMaxBufferedDocs,
+        logger:          logger,
+    }
+    base.epsilon = d.float()
+    base.floorIDF = d.uint64() != 0
+    base.evaluation = Evaluation(d.uint64())
+    numDocs, numTerms := d.uint64(), d.uint64()
+    totalDocLen, averageIDF := d.uint64(), d.float()
+    d.uint64() // CRC-32, checked by Verify
+    if params.kind < variantOkapi || params.kind > variantT || params.k1 < 0 || params.b < 0 || params.b > 1 || params.delta < 0 ||
+        base.evaluation < EvalWAND || base.evaluation > EvalExhaustive || numDocs == 0 || numDocs > math.MaxInt32 || numTerms > math.MaxInt32 ||
+        totalDocLen == 0 || totalDocLen > math.MaxInt64 || math.IsNaN(averageIDF) || math.IsInf(averageIDF, 0) {
+        return nil, ErrCorruptIndex
+    }
+
+    f := &mappedFile{data: data, numDocs: int(numDocs), numTerms: int(numTerms), codec: codec}
+    start := d.uint64()
+    if start != mappedHeaderSize {
+        return nil, ErrCorruptIndex
+    }
+    for i := range f.sections {
+        end := d.uint64()
+        if end < start || end > uint64(len(data)) {
+            return nil, ErrCorruptIndex
+        }
+        f.sections[i] = data[start:end]
+        start = end
+    }
+    f.hasIDs = len(f.sections[sectionIDOffsets]) > 0
+    if start != uint64(len(data)) ||
+        len(f.sections[sectionDocLengths]) != 4*f.numDocs ||
+        len(f.sections[sectionDocOffsets]) != 8*(f.numDocs+1) ||
+        (f.hasIDs && len(f.sections[sectionIDOffsets]) != 8*(f.numDocs+1)) ||
+        len(f.sections[sectionTermOffsets]) != 8*(f.numTerms+1) ||
+        len(f.sections[sectionTermStats]) != termStatsSize*f.numTerms {
+        return nil, ErrCorruptIndex
+    }
+
+    savedIDF := string(f.sections[sectionIDFName])
+    if savedIDF != "" {
+        for _, fn := range idfFuncs {
+            if fn.name == savedIDF {
+                base.idf = fn.fn
+            }
+        }
+        if base.idf == nil {
+            return nil, ErrCorruptIndex
+        }
+    }
+
+    // The IDF floor is saved only if the index floored IDF values. It is
+    // computed again if the options change the IDF function or turn the floor
+    // on.
+    savedFloor := base.floorIDF
+    base.file = f
+    base.corpusSize = f.numDocs
+    base.totalDocLen = int(totalDocLen)
+    base.docLengths = docLengthTable{mapped: f.sections[sectionDocLengths]}
+    seg := newSegment(0)
+    seg.numDocs = f.numDocs
+    seg.file = f
+    base.segments = []*segment{seg}
+
+    for _, opt := range opts {
+        if err := opt(base); err != nil {
+            return nil, err
+        }
+    }
+    if base.idf == nil {
+        return nil, ErrCustomIDF
+    }
+    base.codec = codec
+
+    base.avgDocLen = float64(base.totalDocLen) / float64(base.corpusSize)
+    if base.floorIDF && savedFloor && savedIDF != "" && idfName(base.idf) == savedIDF {
+        base.averageIDF = averageIDF
+    } else if base.floorIDF {
+        base.computeIDFFloor()
+    }
+
+    if base.logger != nil {
+        base.logger.Printf("Opened memory-mapped index, Corpus size: %d, Average document length: %.2f, Vocabulary size: %d", base.corpusSize, base.avgDocLen, f.numTerms)
+    }
+
+    return &MappedIndex{BM25: base.variant(params), file: f}, nil
+}
+
+// Close unmaps the index file.
+func (m *MappedIndex) Close() error {
+    if m.unmap == nil {
+        return nil
+    }
+    err := m.unmap()
+    m.unmap = nil
+    return err
+}
+
+// Verify reads the whole index file and checks its checksum. It returns
+// ErrChecksumMismatch if the file is damaged.
+func (m *MappedIndex) Verify() error {
+    const crcOffset = len(mappedMagic) + 8*(mappedHeaderFields-1)
+    expected := binary.LittleEndian.Uint64(m.file.data[crcOffset:])
+    if uint64(crc32.ChecksumIEEE(m.file.data[mappedHeaderSize:])) != expected {
+        return ErrChecksumMismatch
+    }
+    return nil
+}
+
+// stringAt returns the i-th string of a string table, or nil if the offsets
+// are out of range.
+func stringAt(offsets, text []byte, i int) []byte {
+    start := binary.LittleEndian.Uint64(offsets[8*i:])
+    end := binary.LittleEndian.Uint64(offsets[8*i+8:])
+    if start > end || end > uint64(len(text)) {
+        return nil
+    }
+    return text[start:end]
+}
+
+// document returns the text of the document.
+func (f *mappedFile) document(docID int) string {
+    return string(stringAt(f.sections[sectionDocOffsets], f.sections[sectionDocText], docID))
+}
+
+// externalID returns the external identifier of the document.
+func (f *mappedFile) externalID(docID int) string {
+    if !f.hasIDs {
+        return ""
+    }
+    return string(stringAt(f.sections[sectionIDOffsets], f.sections[sectionIDText], docID))
+}
+
+// term returns the i-th term of the dictionary.
+func (f *mappedFile) term(i int) []byte {
+    return stringAt(f.sections[sectionTermOffsets], f.sections[sectionTermText], i)
+}
+
+// findTerm returns the ordinal of the term in the dictionary, found by binary
+// search over the sorted terms.
+func (f *mappedFile) findTerm(term string) (int, bool) {
+    i := sort.Search(f.numTerms, func(i int) bool {
+        return string(f.term(i)) >= term
+    })
+    return i, i < f.numTerms && string(f.term(i)) == term
+}
+
+// termStat returns the j-th statistic of the i-th term.
+func (f *mappedFile) termStat(i, j int) uint64 {
+    return binary.LittleEndian.Uint64(f.sections[sectionTermStats][termStatsSize*i+8*j:])
+}
+
+// termStats returns the document and collection frequencies of the term.
+func (f *mappedFile) termStats(term string) (int, int, bool) {
+    i, ok := f.findTerm(term)
+    if !ok {
+        return 0, 0, false
+    }
+    return int(f.termStat(i, 0)), int(f.termStat(i, 1)), true
+}
+
+// termRange calls fn, in order, for every term of the dictionary from lo up
+// to but excluding hi, until fn returns false. An empty hi has no upper bound.
+func (f *mappedFile) termRange(lo, hi string, fn func(term string, docFreq int) bool) {
+    i, _ := f.findTerm(lo)
+    for ; i < f.numTerms; i++ {
+        term := string(f.term(i))
+        if (hi != "" && term >= hi) || !fn(term, int(f.termStat(i, 0))) {
+            return
+        }
+    }
+}
+
+// postingList returns the postings of the term. The posting list points into
+// the mapping: its blocks are only decoded as they are read. A posting list
+// whose skip entries are out of range is treated as empty.
+func (f *mappedFile) postingList(term string) (*postingList, bool) {
+    i, ok := f.findTerm(term)
+    if !ok {
+        return nil, false
+    }
+
+    postings := f.sections[sectionPostings]
+    start, end := f.termStat(i, 2), uint64(len(postings))
+    if i+1 < f.numTerms {
+        end = f.termStat(i+1, 2)
+    }
+    n := f.termStat(i, 0)
+    skipsLen := (n + postingBlockSize - 1) / postingBlockSize * postingSkipSize
+    if start > end || end > uint64(len(postings)) || skipsLen > end-start {
+        return &postingList{}, true
+    }
+
+    p := &postingList{
+        n:     int(n),
+        skips: postings[start : start+skipsLen],
+        data:  postings[start+skipsLen : end],
+        codec: f.codec.blockCodec(),
+    }
+    for blk := 0; blk < p.numBlocks(); blk++ {
+        if p.blockLast(blk) >= f.numDocs || (blk > 0 && p.blockLast(blk) <= p.blockLast(blk-1)) {
+            return &postingList{}, true
+        }
+    }
+    return p, true
+}
+
+// variant creates the scorer of the given variant over the base. The
+// per-term parameters of BM25Adpt and BM25T are estimated up front for an
+// in-memory index and on demand for a memory-mapped one, whose dictionary is
+// only read as queries need it. The BM25Adpt parameters saved with the index
+// are used as they are.
+func (b *bm25Base) variant(params variantParams) BM25 {
+    switch params.kind {
+    case variantOkapi:
+        o := &BM25Okapi{bm25Base: b, k1: params.k1, b: params.b}
+        o.computeMaxScores(o)
+        return o
+    case variantL:
+        l := &BM25L{bm25Base: b, k1: params.k1, b: params.b, delta: params.delta}
+        l.computeMaxScores(l)
+        return l
+    case variantPlus:
+        p := &BM25Plus{bm25Base: b, k1: params.k1, b: params.b, delta: params.delta}
+        p.computeMaxScores(p)
+        return p
+    case variantAdpt:
+        a := &BM25Adpt{bm25Base: b, k1: params.k1, b: params.b, delta: params.delta}
+        if params.adpt != nil {
+            a.params = params.adpt
+        } else if b.file != nil {
+            a.resetTermParams()
+        } else {
+            a.estimateParams()
+        }
+        a.computeMaxScores(a)
+        return a
+    default:
+        t := &BM25T{bm25Base: b, k1: params.k1, b: params.b}
+        if b.file != nil {
+            t.resetTermParams()
+        } else {
+            t.solveTermK1()
+        }
+        t.computeMaxScores(t)
+        return t
+    }
+}
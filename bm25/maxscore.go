@@ -0,0 +1,86 @@
+package bm25
+
+import (
+    "math"
+    "sort"
+)
+
+// maxScore collects the best documents of a segment into the heap using the
+// MaxScore algorithm (Turtle and Flood, "Query Evaluation: Strategies and
+// Optimizations"). Query terms are ordered by upper bound, and the terms whose
+// bounds together cannot beat the current n-th best score are non-essential:
+// only documents containing an essential term are candidates, and the
+// non-essential terms are looked up for a candidate only while its score can
+// still beat the threshold.
+func (b *bm25Base) maxScore(cursors []*wandCursor, h *topKHeap, cc *canceller) error {
+    // byBound holds the cursors by increasing upper bound, and bounds[i] the
+    // sum of the upper bounds of byBound[:i+1].
+    byBound := append([]*wandCursor(nil), cursors...)
+    sort.SliceStable(byBound, func(i, j int) bool {
+        return byBound[i].maxScore < byBound[j].maxScore
+    })
+    bounds := make([]float64, len(byBound))
+    var sum float64
+    for i, c := range byBound {
+        sum += c.maxScore
+        bounds[i] = sum
+    }
+
+    essential := 0
+    for {
+        if err := cc.step(); err != nil {
+            return err
+        }
+        // byBound[:essential] are the non-essential terms.
+        for essential < len(byBound) && !competitive(h, bounds[essential]) {
+            essential++
+        }
+        if essential == len(byBound) {
+            break
+        }
+
+        docID := math.MaxInt
+        for _, c := range byBound[essential:] {
+            if d := c.doc(); d >= 0 && d < docID {
+                docID = d
+            }
+        }
+        if docID == math.MaxInt {
+            break
+        }
+
+        var partial float64
+        for _, c := range byBound[essential:] {
+            if c.doc() == docID {
+                partial += c.score(b.docLengths)
+            }
+        }
+
+        // Look up the non-essential terms, the highest bounds first, until the
+        // remaining bounds can no longer lift the document into the heap.
+        candidate := true
+        for i := essential - 1; i >= 0; i-- {
+            if !competitive(h, partial+bounds[i]) {
+                candidate = false
+                break
+            }
+            c := byBound[i]
+            c.skipTo(docID)
+            if c.doc() == docID {
+                partial += c.score(b.docLengths)
+            }
+        }
+
+        if candidate {
+            h.offer(docID, b.scoreDoc(cursors, docID))
+            continue
+        }
+
+        for _, c := range byBound[essential:] {
+            if c.doc() == docID {
+                c.next()
+            }
+        }
+    }
+    return nil
+}
@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package bm25
+
+import "os"
+
+// mapFile reads the whole file into memory on platforms without mmap support.
+// The index still only decodes what queries need, but the file is not shared
+// between processes.
+func mapFile(path string) ([]byte, func() error, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, nil, err
+    }
+    return data, func() error { return nil }, nil
+}
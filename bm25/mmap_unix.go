@@ -0,0 +1,37 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package bm25
+
+import (
+    "errors"
+    "os"
+    "syscall"
+)
+
+// mapFile maps the file read-only into memory and returns its contents and a
+// function unmapping it.
+func mapFile(path string) ([]byte, func() error, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, nil, err
+    }
+    defer f.Close()
+
+    info, err := f.Stat()
+    if err != nil {
+        return nil, nil, err
+    }
+    size := info.Size()
+    if size == 0 {
+        return nil, nil, ErrNotIndexFile
+    }
+    if size != int64(int(size)) {
+        return nil, nil, errors.New("index file is too large to be mapped")
+    }
+
+    data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+    if err != nil {
+        return nil, nil, err
+    }
+    return data, func() error { return syscall.Munmap(data) }, nil
+}
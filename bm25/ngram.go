@@ -0,0 +1,107 @@
+package bm25
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+)
+
+// NGramTokenizer returns a tokenizer that splits text around runs of white
+// space and returns the character n-grams of every word, from min to max
+// characters long, such as "ip", "ph", "iph" and "pho" for "iphone" with min
+// 2 and max 3. Words shorter than min are returned whole. Because queries are
+// split into grams the same way, a query matches the words that contain its
+// grams, so partial words and parts of product codes can be found.
+//
+// Grams are returned as they appear in the text; to lowercase them, use
+// NGramFilter after LowercaseFilter in an Analyzer instead.
+func NGramTokenizer(min, max int) (Tokenizer, error) {
+    filter, err := NGramFilter(min, max)
+    if err != nil {
+        return nil, err
+    }
+    return func(text string) []string {
+        return filter(strings.Fields(text))
+    }, nil
+}
+
+// EdgeNGramTokenizer returns a tokenizer like NGramTokenizer that only returns
+// the n-grams at the start of every word, such as "ip", "iph" and "ipho" for
+// "iphone" with min 2 and max 4. It suits prefix and search-as-you-type
+// matching, with fewer terms than NGramTokenizer.
+func EdgeNGramTokenizer(min, max int) (Tokenizer, error) {
+    filter, err := EdgeNGramFilter(min, max)
+    if err != nil {
+        return nil, err
+    }
+    return func(text string) []string {
+        return filter(strings.Fields(text))
+    }, nil
+}
+
+// NGramFilter returns a token filter that replaces every token with its
+// character n-grams, from min to max characters long. Tokens shorter than min
+// are kept whole.
+func NGramFilter(min, max int) (TokenFilter, error) {
+    if err := validateGramSizes(min, max); err != nil {
+        return nil, err
+    }
+    return func(tokens []string) []string {
+        return ngrams(tokens, min, max, false)
+    }, nil
+}
+
+// EdgeNGramFilter returns a token filter that replaces every token with its
+// character n-grams that start at its beginning, from min to max characters
+// long. Tokens shorter than min are kept whole.
+func EdgeNGramFilter(min, max int) (TokenFilter, error) {
+    if err := validateGramSizes(min, max); err != nil {
+        return nil, err
+    }
+    return func(tokens []string) []string {
+        return ngrams(tokens, min, max, true)
+    }, nil
+}
+
+// validateGramSizes checks the minimum and maximum n-gram sizes.
+func validateGramSizes(min, max int) error {
+    if min < 1 {
+        return errors.New("minimum gram size must be at least 1, got " + strconv.Itoa(min))
+    }
+    if max < min {
+        return errors.New("maximum gram size " + strconv.Itoa(max) + " is less than minimum gram size " + strconv.Itoa(min))
+    }
+    return nil
+}
+
+// ngrams returns the n-grams of the tokens, from min to max characters long,
+// only those at the start of every token if edge is true. Grams of a token are
+// ordered by start and then by length.
+func ngrams(tokens []string, min, max int, edge bool) []string {
+    var grams []string
+    for _, token := range tokens {
+        // offsets holds the byte offset of every rune, and the token length.
+        offsets := make([]int, 0, len(token)+1)
+        for i := range token {
+            offsets = append(offsets, i)
+        }
+        n := len(offsets)
+        offsets = append(offsets, len(token))
+        if n < min {
+            if n > 0 {
+                grams = append(grams, token)
+            }
+            continue
+        }
+        starts := n - min + 1
+        if edge {
+            starts = 1
+        }
+        for start := 0; start < starts; start++ {
+            for size := min; size <= max && start+size <= n; size++ {
+                grams = append(grams, token[offsets[start]:offsets[start+size]])
+            }
+        }
+    }
+    return grams
+}
@@ -0,0 +1,121 @@
+package bm25
+
+import "errors"
+
+// Option configures optional behaviour of a BM25 scorer at construction time.
+type Option func(*bm25Base) error
+
+// WithIDF selects the formula used to compute the IDF of each term.
+func WithIDF(idf IDFFunc) Option {
+    return func(b *bm25Base) error {
+        if idf == nil {
+            return errors.New("IDF function cannot be nil")
+        }
+        b.idf = idf
+        return nil
+    }
+}
+
+// WithEpsilon enables the rank_bm25 IDF floor: negative IDF values are
+// replaced with epsilon times the average IDF over the vocabulary.
+func WithEpsilon(epsilon float64) Option {
+    return func(b *bm25Base) error {
+        if epsilon < 0 {
+            return errors.New("epsilon must be non-negative")
+        }
+        b.floorIDF = true
+        b.epsilon = epsilon
+        return nil
+    }
+}
+
+// WithExternalIDs attaches an external identifier to each document of the
+// corpus, in corpus order. The identifiers are reported in search hits.
+func WithExternalIDs(ids []string) Option {
+    return func(b *bm25Base) error {
+        b.externalIDs = append([]string(nil), ids...)
+        return nil
+    }
+}
+
+// WithAnalyzer analyzes documents and query strings with the given analyzer
+// instead of the tokenizer function passed to the constructor, which may then
+// be nil. The analyzer is not saved with the index: give it again to Load and
+// OpenMapped.
+func WithAnalyzer(a *Analyzer) Option {
+    return func(b *bm25Base) error {
+        if err := a.validate(); err != nil {
+            return err
+        }
+        b.analyzer = a
+        b.tokenizer = a.Analyze
+        return nil
+    }
+}
+
+// WithUnscoredStopwords keeps the stopwords in the index but leaves them out
+// of scoring: query terms in the set contribute nothing to any score, whichever
+// IDF formula and evaluation strategy is used. Unlike StopFilter, this keeps
+// stopwords in the documents, where they still count toward document lengths.
+// The set is not saved with the index: give it again to Load and OpenMapped.
+func WithUnscoredStopwords(stopwords Stopwords) Option {
+    return func(b *bm25Base) error {
+        b.unscored = stopwords
+        return nil
+    }
+}
+
+// WithEvaluation selects the algorithm GetTopN and Search use to find the best
+// documents. The default is EvalWAND.
+func WithEvaluation(e Evaluation) Option {
+    return func(b *bm25Base) error {
+        if e < EvalWAND || e > EvalExhaustive {
+            return errors.New("unknown evaluation strategy: " + e.String())
+        }
+        b.evaluation = e
+        return nil
+    }
+}
+
+// WithPostingCodec selects the codec compressing the posting lists. The
+// default is DefaultPostingCodec. Load applies it to the loaded postings;
+// OpenMapped ignores it, as a memory-mapped index is read with the codec it
+// was written with.
+func WithPostingCodec(codec PostingCodec) Option {
+    return func(b *bm25Base) error {
+        if codec.blockCodec() == nil {
+            return errors.New("unknown posting codec: " + codec.String())
+        }
+        b.codec = codec
+        return nil
+    }
+}
+
+// WithMaxBufferedDocs sets the maximum number of documents flushed into a
+// single segment. The default is DefaultMaxBufferedDocs.
+func WithMaxBufferedDocs(n int) Option {
+    return func(b *bm25Base) error {
+        if n <= 0 {
+            return errors.New("max buffered documents must be a positive integer")
+        }
+        b.maxBufferedDocs = n
+        return nil
+    }
+}
+
+// WithMergePolicy selects the policy deciding which segments are merged in the
+// background. The default is DefaultTieredMergePolicy.
+func WithMergePolicy(p MergePolicy) Option {
+    return func(b *bm25Base) error {
+        if p == nil {
+            return errors.New("merge policy cannot be nil")
+        }
+        if tiered, ok := p.(TieredMergePolicy); ok {
+            if err := tiered.validate(); err != nil {
+                return err
+            }
+        }
+        b.mergePolicy = p
+        return nil
+    }
+}
@@ -1,165 +1,170 @@
 package bm25
 
 import (
+    "context"
     "errors"
+    "runtime"
     "sync"
 )
 
 // GetScoresParallel returns the BM25 scores for the given query using parallel computation.
+// The documents are split into one range per CPU, and every goroutine adds up
+// the query terms of its range in query order, so the scores are the same as
+// those of GetScores.
 func (b *bm25Base) GetScoresParallel(query []string, bm25 BM25) ([]float64, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
+    return b.GetScoresParallelContext(context.Background(), query, bm25)
+}
+
+// GetScoresParallelContext is like GetScoresParallel but stops every goroutine
+// and returns the error of ctx once it is done.
+func (b *bm25Base) GetScoresParallelContext(ctx context.Context, query []string, bm25 BM25) ([]float64, error) {
+    scorer, err := asTermScorer(bm25)
+    if err != nil {
+        return nil, err
     }
 
-    var wg sync.WaitGroup
-    scores := make([]float64, b.corpusSize)
-    wg.Add(len(query))
+    scores, err := b.parallelScores(ctx, query, scorer)
+    if err != nil {
+        return nil, err
+    }
+    b.addFloor(scores, nil, b.queryFloor(query, scorer))
 
-    for _, q := range query {
-        go func(q string) {
-            defer wg.Done()
-            qFreq := make([]float64, b.corpusSize)
-            for i, doc := range b.corpus {
-                docStr := JoinTokens(doc, " ")
-                freq, _ := CountTermFreq(q, docStr, b.tokenizer) // Ignore the error for now
-                qFreq[i] = float64(freq)
-            }
+    return scores, nil
+}
 
-            idf, err := b.IDF(q)
-            if err != nil {
-                if b.logger != nil {
-                    b.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-                }
-                return
-            }
+// parallelScores scores every document against the query, without the query
+// floor. The documents are split into one range per CPU, each scored by its
+// own goroutine as in batchedScores, so that the memory used does not grow
+// with the number of query terms.
+func (b *bm25Base) parallelScores(ctx context.Context, query []string, scorer termScorer) ([]float64, error) {
+    workers := runtime.GOMAXPROCS(0)
+    return b.batchedScores(ctx, query, scorer, (b.maxDoc()+workers-1)/workers)
+}
 
-            for i, docLen := range b.docLengths {
-                k := computeK(bm25, docLen)
-                scores[i] += idf * computeScore(bm25, qFreq[i], k)
-            }
-        }(q)
+// sumPartials adds up the per-goroutine partial scores, in order. A nil
+// partial contributes nothing.
+func sumPartials(partials [][]float64, n int) []float64 {
+    scores := make([]float64, n)
+    for _, partial := range partials {
+        for i, score := range partial {
+            scores[i] += score
+        }
     }
-
-    wg.Wait()
-    return scores, nil
+    return scores
 }
 
-// computeK computes the k value based on the BM25 variant and document length.
-func computeK(bm25 BM25, docLen int) float64 {
-    switch bm25 := bm25.(type) {
-    case *BM25Okapi:
-        return bm25.k1 * (1 - bm25.b + bm25.b*float64(docLen)/bm25.avgDocLen)
-    case *BM25L:
-        return bm25.k1 * (1 - bm25.b + bm25.b*float64(docLen)/bm25.avgDocLen)
-    case *BM25Plus:
-        return bm25.k1 * (1 - bm25.b + bm25.b*float64(docLen)/bm25.avgDocLen)
-    case *BM25Adpt:
-        return bm25.k1 * (1 - bm25.b + bm25.b*float64(docLen)/bm25.avgDocLen)
-    case *BM25T:
-        return bm25.k1 * (1 - bm25.b + bm25.b*float64(docLen)/bm25.avgDocLen)
-    default:
-        return 0
+// firstError returns the first non-nil error reported by the goroutines of a
+// parallel computation.
+func firstError(errs []error) error {
+    for _, err := range errs {
+        if err != nil {
+            return err
+        }
     }
+    return nil
 }
 
-// computeScore computes the BM25 score based on the BM25 variant and query term frequency.
-func computeScore(bm25 BM25, qFreq, k float64) float64 {
-    switch bm25 := bm25.(type) {
-    case *BM25Okapi:
-        return qFreq / (qFreq + k)
-    case *BM25L:
-        return qFreq / (qFreq + k)
-    case *BM25Plus:
-        return bm25.delta + (qFreq / (qFreq + k))
-    case *BM25Adpt:
-        return bm25.delta + (qFreq * (1 + k)) / (qFreq + k)
-    case *BM25T:
-        return bm25.delta + (qFreq * (1 + k)) / (qFreq + k)
-    default:
-        return 0
+// asTermScorer returns the term weighting of the given BM25 variant.
+func asTermScorer(bm25 BM25) (termScorer, error) {
+    if m, ok := bm25.(*MappedIndex); ok {
+        bm25 = m.BM25
+    }
+    scorer, ok := bm25.(termScorer)
+    if !ok {
+        return nil, errors.New("unsupported BM25 implementation")
     }
+    return scorer, nil
 }
 
 // GetBatchScoresParallel returns the BM25 scores for the given query and a subset of documents using parallel computation.
+// As with GetScoresParallel, each goroutine scores one query term into its own
+// slice and the slices are summed at the end.
 func (b *bm25Base) GetBatchScoresParallel(query []string, docIDs []int, bm25 BM25) ([]float64, error) {
+    return b.GetBatchScoresParallelContext(context.Background(), query, docIDs, bm25)
+}
+
+// GetBatchScoresParallelContext is like GetBatchScoresParallel but stops every
+// goroutine and returns the error of ctx once it is done.
+func (b *bm25Base) GetBatchScoresParallelContext(ctx context.Context, query []string, docIDs []int, bm25 BM25) ([]float64, error) {
     if len(query) == 0 {
         return nil, errors.New("query cannot be empty")
     }
 
-    if len(docIDs) == 0 {
-        return nil, errors.New("document IDs cannot be empty")
+    if err := b.validateDocIDs(docIDs); err != nil {
+        return nil, err
+    }
+
+    scorer, err := asTermScorer(bm25)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := ctx.Err(); err != nil {
+        return nil, err
     }
 
+    segs := b.segmentsSnapshot()
+
     var wg sync.WaitGroup
-    scores := make([]float64, len(docIDs))
+    partials := make([][]float64, len(query))
+    errs := make([]error, len(query))
     wg.Add(len(query))
 
-    for _, q := range query {
-        go func(q string) {
+    for qi, q := range query {
+        go func(qi int, q string) {
             defer wg.Done()
-            qFreq := make([]float64, len(docIDs))
-            for i, docID := range docIDs {
-                if docID < 0 || docID >= b.corpusSize {
-                    if b.logger != nil {
-                        b.logger.Printf("Invalid document ID: %d", docID)
-                    }
-                    continue
-                }
-                docStr := JoinTokens(b.corpus[docID], " ")
-                freq, _ := CountTermFreq(q, docStr, b.tokenizer) // Ignore the error for now
-                qFreq[i] = float64(freq)
+            if _, _, ok := b.termStats(q); !ok {
+                return
             }
 
-            idf, err := b.IDF(q)
-            if err != nil {
-                if b.logger != nil {
-                    b.logger.Printf("Error calculating IDF for term '%s': %v", q, err)
-                }
+            weight := b.lookupWeight(scorer, q)
+            if weight == nil {
                 return
             }
 
+            cc := newCanceller(ctx)
+            scores := make([]float64, len(docIDs))
             for i, docID := range docIDs {
-                if docID < 0 || docID >= b.corpusSize {
-                    continue
+                if errs[qi] = cc.step(); errs[qi] != nil {
+                    return
+                }
+                if freq := termFreq(segs, q, docID); freq > 0 && !b.isDeleted(docID) {
+                    scores[i] = weight(float64(freq), b.docLengths.at(docID))
                 }
-                docLen := b.docLengths[docID]
-                k := computeK(bm25, docLen)
-                scores[i] += idf * computeScore(bm25, qFreq[i], k)
             }
-        }(q)
+            partials[qi] = scores
+        }(qi, q)
     }
 
     wg.Wait()
+    if err := firstError(errs); err != nil {
+        return nil, err
+    }
+    scores := sumPartials(partials, len(docIDs))
+    b.addFloor(scores, docIDs, b.queryFloor(query, scorer))
+
     return scores, nil
 }
 
 // GetTopNParallel returns the top N documents for the given query using parallel computation.
 func (b *bm25Base) GetTopNParallel(query []string, n int, bm25 BM25) ([]string, error) {
-    if len(query) == 0 {
-        return nil, errors.New("query cannot be empty")
-    }
-
-    if n <= 0 {
-        if b.logger != nil {
-            b.logger.Printf("Invalid value for n: %d. Returning empty slice.", n)
-        }
-        return []string{}, nil
-    }
+    return b.GetTopNParallelContext(context.Background(), query, n, bm25)
+}
 
-    scores, err := b.GetScoresParallel(query, bm25)
+// GetTopNParallelContext is like GetTopNParallel but stops every goroutine and
+// returns the error of ctx once it is done.
+func (b *bm25Base) GetTopNParallelContext(ctx context.Context, query []string, n int, bm25 BM25) ([]string, error) {
+    scorer, err := asTermScorer(bm25)
     if err != nil {
         return nil, err
     }
 
-    topNIndices, err := TopNIndices(scores, n)
+    // The query floor is the same for every document, so the documents are
+    // ranked without it.
+    scores, err := b.parallelScores(ctx, query, scorer)
     if err != nil {
         return nil, err
     }
 
-    topDocs := make([]string, len(topNIndices))
-    for i, idx := range topNIndices {
-        topDocs[i] = JoinTokens(b.corpus[idx], " ")
-    }
-
-    return topDocs, nil
+    return b.topN(scores, n)
 }
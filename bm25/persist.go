@@ -0,0 +1,603 @@
+package bm25
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "hash/crc32"
+    "io"
+    "log"
+    "math"
+    "reflect"
+    "sort"
+    "strconv"
+)
+
+// FormatVersion is the version of the index file format written by Save.
+// Version 2 added the posting codec and version 3 the fitted parameters of
+// BM25Adpt. Load still reads older files: it compresses the postings of a
+// version 1 file with DefaultPostingCodec and fits the BM25Adpt parameters of
+// a version 1 or 2 file again.
+const FormatVersion = 3
+
+// indexMagic starts every index file written by Save.
+var indexMagic = [8]byte{'B', 'M', '2', '5', 'I', 'D', 'X', 0}
+
+var (
+    // ErrNotIndexFile is returned by Load when the data does not start with
+    // the index file magic.
+    ErrNotIndexFile = errors.New("bm25: not an index file")
+    // ErrChecksumMismatch is returned by Load when the checksum of the index
+    // file does not match its contents.
+    ErrChecksumMismatch = errors.New("bm25: index file checksum mismatch")
+    // ErrCorruptIndex is returned by Load when the index file is truncated or
+    // its contents are inconsistent.
+    ErrCorruptIndex = errors.New("bm25: corrupt index file")
+    // ErrCustomIDF is returned by Load when the index was built with an IDF
+    // function other than the ones of this package and no WithIDF option is
+    // given to replace it.
+    ErrCustomIDF = errors.New("bm25: index uses a custom IDF function, which must be given with WithIDF")
+)
+
+// VersionError is returned by Load when the index file was written in a
+// format version this package cannot read.
+type VersionError struct {
+    Version uint32
+}
+
+func (e *VersionError) Error() string {
+    return "bm25: unsupported index format version " + strconv.FormatUint(uint64(e.Version), 10) + ", expected " + strconv.Itoa(FormatVersion)
+}
+
+// variantKind identifies the BM25 variant stored in an index file.
+type variantKind uint8
+
+const (
+    variantOkapi variantKind = iota + 1
+    variantL
+    variantPlus
+    variantAdpt
+    variantT
+)
+
+// variantParams holds the parameters of a variant. Variants without a delta
+// store zero. adpt holds the fitted parameters of every term of a BM25Adpt,
+// and is nil for the other variants.
+type variantParams struct {
+    kind  variantKind
+    k1    float64
+    b     float64
+    delta float64
+    adpt  map[string]adptTermParams
+}
+
+// idfFuncs names the IDF functions of this package in index files. The empty
+// name stands for a custom IDF function.
+var idfFuncs = []struct {
+    name string
+    fn   IDFFunc
+}{
+    {"lucene", LuceneIDF},
+    {"robertson-sparck-jones", RobertsonSparckJonesIDF},
+    {"smoothed", SmoothedIDF},
+    {"bm25plus", BM25PlusIDF},
+    {"probabilistic", ProbabilisticIDF},
+}
+
+// idfName returns the name of the IDF function, or "" if it is not one of the
+// functions of this package.
+func idfName(idf IDFFunc) string {
+    ptr := reflect.ValueOf(idf).Pointer()
+    for _, f := range idfFuncs {
+        if reflect.ValueOf(f.fn).Pointer() == ptr {
+            return f.name
+        }
+    }
+    return ""
+}
+
+// Save writes the index of the BM25Okapi to w. It can be read back with Load.
+func (o *BM25Okapi) Save(w io.Writer) error {
+    return o.save(w, variantParams{kind: variantOkapi, k1: o.k1, b: o.b})
+}
+
+// Save writes the index of the BM25L to w. It can be read back with Load.
+func (l *BM25L) Save(w io.Writer) error {
+    return l.save(w, variantParams{kind: variantL, k1: l.k1, b: l.b, delta: l.delta})
+}
+
+// Save writes the index of the BM25Plus to w. It can be read back with Load.
+func (p *BM25Plus) Save(w io.Writer) error {
+    return p.save(w, variantParams{kind: variantPlus, k1: p.k1, b: p.b, delta: p.delta})
+}
+
+// Save writes the index of the BM25Adpt to w. It can be read back with Load.
+// The fitted G¹ IDF and k1 of every term are saved with the index, so that
+// Load does not fit them again.
+func (a *BM25Adpt) Save(w io.Writer) error {
+    if a.file != nil {
+        return ErrReadOnly
+    }
+
+    params := make(map[string]adptTermParams, a.vocabularySize())
+    a.forEachTerm(func(term string, docFreq int) {
+        params[term] = a.termParams(term)
+    })
+    return a.save(w, variantParams{kind: variantAdpt, k1: a.k1, b: a.b, delta: a.delta, adpt: params})
+}
+
+// Save writes the index of the BM25T to w. It can be read back with Load.
+// The per-term k1 values are not saved; they are solved again on demand.
+func (t *BM25T) Save(w io.Writer) error {
+    return t.save(w, variantParams{kind: variantT, k1: t.k1, b: t.b})
+}
+
+// save writes the index file: the magic, the format version, the length of
+// the payload, the payload and the CRC-32 of the payload. The payload holds
+// the variant parameters, the documents, the vocabulary and the segments,
+// including the postings of deleted documents that were not compacted yet.
+func (b *bm25Base) save(w io.Writer, params variantParams) error {
+    if b.file != nil {
+        return ErrReadOnly
+    }
+
+    e := &encoder{}
+    e.byte(byte(params.kind))
+    e.float(params.k1)
+    e.float(params.b)
+    e.float(params.delta)
+    e.bool(b.floorIDF)
+    e.float(b.epsilon)
+    e.string(idfName(b.idf))
+    e.byte(byte(b.evaluation))
+    e.uvarint(uint64(b.maxBufferedDocs))
+    e.byte(byte(b.codec))
+
+    e.uvarint(uint64(b.maxDoc()))
+    for docID, doc := range b.docs {
+        e.string(doc)
+        e.uvarint(uint64(b.docLengths.at(docID)))
+    }
+    e.bool(b.externalIDs != nil)
+    for _, id := range b.externalIDs {
+        e.string(id)
+    }
+    e.uvarint(uint64(len(b.deleted)))
+    for _, word := range b.deleted {
+        e.uint64(word)
+    }
+
+    // Terms are written in order, so that saving an index twice gives the
+    // same file.
+    e.uvarint(uint64(b.vocab.size))
+    b.vocab.forRange("", "", func(term string, f termFreqs) bool {
+        e.string(term)
+        e.uvarint(uint64(f.docFreq))
+        e.uvarint(uint64(f.collFreq))
+        return true
+    })
+
+    segs := b.segmentsSnapshot()
+    e.uvarint(uint64(len(segs)))
+    for _, seg := range segs {
+        e.uvarint(uint64(seg.numDocs))
+        e.uvarint(uint64(len(seg.postings)))
+        seg.forEachPostingList(func(term string, p *postingList) {
+            e.string(term)
+            e.uvarint(uint64(p.n))
+            prev := seg.base
+            for it := p.iterator(); it.valid(); it.next() {
+                e.uvarint(uint64(it.doc() - prev))
+                e.uvarint(uint64(it.freq()))
+                prev = it.doc()
+            }
+        })
+    }
+
+    // The parameters of a BM25Adpt follow the vocabulary order, with a flag
+    // for the terms whose gain curve could not be fitted.
+    if params.kind == variantAdpt {
+        b.vocab.forRange("", "", func(term string, f termFreqs) bool {
+            p := params.adpt[term]
+            e.bool(p.fitted)
+            if p.fitted {
+                e.float(p.k1)
+                e.float(p.idf)
+            }
+            return true
+        })
+    }
+
+    var header [20]byte
+    copy(header[:8], indexMagic[:])
+    binary.LittleEndian.PutUint32(header[8:12], FormatVersion)
+    binary.LittleEndian.PutUint64(header[12:20], uint64(e.buf.Len()))
+    var trailer [4]byte
+    binary.LittleEndian.PutUint32(trailer[:], crc32.ChecksumIEEE(e.buf.Bytes()))
+
+    for _, chunk := range [][]byte{header[:], e.buf.Bytes(), trailer[:]} {
+        if _, err := w.Write(chunk); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// sortedTerms returns the keys of a map keyed by term, in order.
+func sortedTerms[V any](m map[string]V) []string {
+    terms := make([]string, 0, len(m))
+    for term := range m {
+        terms = append(terms, term)
+    }
+    sort.Strings(terms)
+    return terms
+}
+
+// Load reads an index written by Save and returns a scorer of the saved
+// variant, with the saved parameters, ready to be queried without tokenizing
+// the corpus again. The tokenizer, or the analyzer given with WithAnalyzer,
+// must be the one the index was built with; it is used by AddDocuments,
+// UpdateDocument and SearchText. The options are applied after the saved
+// settings, so that, for instance, the evaluation strategy, the merge policy
+// or the posting codec can be changed. An index built with a custom IDF
+// function can only be loaded with a WithIDF option.
+//
+// Load returns ErrNotIndexFile, a *VersionError, ErrChecksumMismatch or
+// ErrCorruptIndex if the data is not a valid index file.
+func Load(r io.Reader, tokenizer func(string) []string, logger *log.Logger, opts ...Option) (BM25, error) {
+    var header [20]byte
+    if _, err := io.ReadFull(r, header[:]); err != nil {
+        if err == io.EOF || err == io.ErrUnexpectedEOF {
+            return nil, ErrNotIndexFile
+        }
+        return nil, err
+    }
+    if !bytes.Equal(header[:8], indexMagic[:]) {
+        return nil, ErrNotIndexFile
+    }
+    version := binary.LittleEndian.Uint32(header[8:12])
+    if version < 1 || version > FormatVersion {
+        return nil, &VersionError{Version: version}
+    }
+
+    size := binary.LittleEndian.Uint64(header[12:20])
+    if size > math.MaxInt64-4 {
+        return nil, ErrCorruptIndex
+    }
+    payload, err := io.ReadAll(io.LimitReader(r, int64(size)+4))
+    if err != nil {
+        return nil, err
+    }
+    if uint64(len(payload)) != size+4 {
+        return nil, ErrCorruptIndex
+    }
+    if crc32.ChecksumIEEE(payload[:size]) != binary.LittleEndian.Uint32(payload[size:]) {
+        return nil, ErrChecksumMismatch
+    }
+
+    base := &bm25Base{
+        vocab:       newVocabulary(),
+        mergePolicy: DefaultTieredMergePolicy(),
+        codec:       DefaultPostingCodec,
+        tokenizer:   tokenizer,
+        logger:      logger,
+    }
+    params, builders, err := base.decode(&decoder{data: payload[:size]}, version)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, opt := range opts {
+        if err := opt(base); err != nil {
+            return nil, err
+        }
+    }
+    if base.tokenizer == nil {
+        return nil, errors.New("tokenizer function cannot be nil")
+    }
+    if base.idf == nil {
+        return nil, ErrCustomIDF
+    }
+
+    // The postings are compressed once the options are applied, with the
+    // saved codec or the one given with WithPostingCodec.
+    segs := make([]*segment, len(builders))
+    for i, sb := range builders {
+        segs[i] = sb.build(base.codec)
+    }
+    base.addSegments(segs...)
+    base.avgDocLen = float64(base.totalDocLen) / float64(base.corpusSize)
+    if base.floorIDF {
+        base.computeIDFFloor()
+    }
+
+    if base.logger != nil {
+        base.logger.Printf("Loaded index, Corpus size: %d, Average document length: %.2f, Vocabulary size: %d", base.corpusSize, base.avgDocLen, base.vocabularySize())
+    }
+
+    return base.variant(params), nil
+}
+
+// decode reads the payload of an index file in the given format version into
+// the base and returns the variant parameters and the builders of the
+// segments. Every count and document ID is checked, so that a damaged file is
+// reported rather than causing a panic.
+func (b *bm25Base) decode(d *decoder, version uint32) (variantParams, []*segmentBuilder, error) {
+    var params variantParams
+    params.kind = variantKind(d.byte())
+    params.k1 = d.float()
+    params.b = d.float()
+    params.delta = d.float()
+    if params.kind < variantOkapi || params.kind > variantT || params.k1 < 0 || params.b < 0 || params.b > 1 || params.delta < 0 {
+        return params, nil, ErrCorruptIndex
+    }
+
+    b.floorIDF = d.bool()
+    b.epsilon = d.float()
+    if name := d.string(); name != "" {
+        for _, f := range idfFuncs {
+            if f.name == name {
+                b.idf = f.fn
+            }
+        }
+        if b.idf == nil {
+            return params, nil, ErrCorruptIndex
+        }
+    }
+    b.evaluation = Evaluation(d.byte())
+    b.maxBufferedDocs = d.count()
+    if version >= 2 {
+        b.codec = PostingCodec(d.byte())
+    }
+    if b.evaluation < EvalWAND || b.evaluation > EvalExhaustive || b.maxBufferedDocs <= 0 || b.codec.blockCodec() == nil {
+        return params, nil, ErrCorruptIndex
+    }
+
+    // Every document takes at least two bytes: its text length and its
+    // length in terms.
+    numDocs := d.items(2)
+    b.docs = make([]string, 0, numDocs)
+    b.docLengths.lengths = make([]int, 0, numDocs)
+    for i := 0; i < numDocs && d.err == nil; i++ {
+        b.docs = append(b.docs, d.string())
+        b.docLengths.lengths = append(b.docLengths.lengths, d.count())
+    }
+    if d.bool() {
+        b.externalIDs = make([]string, 0, numDocs)
+        for i := 0; i < numDocs && d.err == nil; i++ {
+            b.externalIDs = append(b.externalIDs, d.string())
+        }
+    }
+    words := d.items(8)
+    if words > (numDocs+63)/64 {
+        d.fail()
+    }
+    for i := 0; i < words && d.err == nil; i++ {
+        b.deleted = append(b.deleted, d.uint64())
+    }
+    if d.err != nil || b.docLengths.len() != numDocs || (b.externalIDs != nil && len(b.externalIDs) != numDocs) {
+        return params, nil, ErrCorruptIndex
+    }
+    for docID := 0; docID < numDocs; docID++ {
+        if b.isDeleted(docID) {
+            b.numDeleted++
+            continue
+        }
+        b.corpusSize++
+        b.totalDocLen += b.docLengths.at(docID)
+    }
+
+    // Every term takes at least three bytes: its length and its frequencies.
+    terms := d.items(3)
+    for i := 0; i < terms && d.err == nil; i++ {
+        term := d.string()
+        docFreq := d.count()
+        b.vocab.add(term, docFreq, d.count())
+        b.vocab.maybeRebuild()
+    }
+
+    // Every segment takes at least two bytes: its size and its number of
+    // terms, and every posting list and posting at least two more. The terms
+    // of a segment are in strictly increasing order, and the postings of the
+    // live documents add up to the statistics of the vocabulary.
+    numSegs := d.items(2)
+    builders := make([]*segmentBuilder, 0, numSegs)
+    live := make(map[string]termFreqs, b.vocab.size)
+    next := 0
+    for i := 0; i < numSegs && d.err == nil; i++ {
+        sb := newSegmentBuilder(next)
+        sb.seg.numDocs = d.count()
+        next += sb.seg.numDocs
+        numTerms := d.items(2)
+        var prev string
+        for j := 0; j < numTerms && d.err == nil; j++ {
+            term := d.string()
+            if j > 0 && term <= prev {
+                return params, nil, ErrCorruptIndex
+            }
+            prev = term
+            n := d.items(2)
+            docID := sb.seg.base
+            var f termFreqs
+            for k := 0; k < n && d.err == nil; k++ {
+                gap := d.count()
+                docID += gap
+                if (k > 0 && gap == 0) || docID >= next {
+                    return params, nil, ErrCorruptIndex
+                }
+                freq := d.count()
+                if freq == 0 {
+                    return params, nil, ErrCorruptIndex
+                }
+                sb.addPosting(term, docID, freq)
+                if !b.isDeleted(docID) {
+                    f.docFreq++
+                    f.collFreq += freq
+                }
+            }
+            if f.docFreq > 0 {
+                total := live[term]
+                total.docFreq += f.docFreq
+                total.collFreq += f.collFreq
+                live[term] = total
+            }
+        }
+        builders = append(builders, sb)
+    }
+    if d.err == nil && len(live) != b.vocab.size {
+        return params, nil, ErrCorruptIndex
+    }
+    for term, f := range live {
+        if stats, ok := b.vocab.stats(term); !ok || stats != f {
+            return params, nil, ErrCorruptIndex
+        }
+    }
+
+    if version >= 3 && params.kind == variantAdpt {
+        params.adpt = make(map[string]adptTermParams, b.vocab.size)
+        b.vocab.forRange("", "", func(term string, f termFreqs) bool {
+            var p adptTermParams
+            if p.fitted = d.bool(); p.fitted {
+                p.k1 = d.float()
+                p.idf = d.float()
+                if !(p.k1 > 0 && p.idf > 0) || math.IsInf(p.k1, 0) || math.IsInf(p.idf, 0) {
+                    d.fail()
+                }
+            }
+            params.adpt[term] = p
+            return d.err == nil
+        })
+    }
+
+    if d.err != nil || len(d.data) != 0 || next != numDocs || b.corpusSize == 0 {
+        return params, nil, ErrCorruptIndex
+    }
+    return params, builders, nil
+}
+
+// encoder appends the values of an index file to a buffer.
+type encoder struct {
+    buf bytes.Buffer
+}
+
+func (e *encoder) byte(v byte) {
+    e.buf.WriteByte(v)
+}
+
+func (e *encoder) bool(v bool) {
+    if v {
+        e.byte(1)
+    } else {
+        e.byte(0)
+    }
+}
+
+func (e *encoder) uvarint(v uint64) {
+    var tmp [binary.MaxVarintLen64]byte
+    e.buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+func (e *encoder) uint32(v uint32) {
+    var tmp [4]byte
+    binary.LittleEndian.PutUint32(tmp[:], v)
+    e.buf.Write(tmp[:])
+}
+
+func (e *encoder) uint64(v uint64) {
+    var tmp [8]byte
+    binary.LittleEndian.PutUint64(tmp[:], v)
+    e.buf.Write(tmp[:])
+}
+
+func (e *encoder) float(v float64) {
+    e.uint64(math.Float64bits(v))
+}
+
+func (e *encoder) string(v string) {
+    e.uvarint(uint64(len(v)))
+    e.buf.WriteString(v)
+}
+
+// decoder reads the values of an index file. The first error is kept and
+// every later read returns a zero value.
+type decoder struct {
+    data []byte
+    err  error
+}
+
+func (d *decoder) fail() {
+    d.err = ErrCorruptIndex
+    d.data = nil
+}
+
+func (d *decoder) byte() byte {
+    if len(d.data) < 1 {
+        d.fail()
+        return 0
+    }
+    v := d.data[0]
+    d.data = d.data[1:]
+    return v
+}
+
+func (d *decoder) bool() bool {
+    return d.byte() != 0
+}
+
+func (d *decoder) uvarint() uint64 {
+    v, n := binary.Uvarint(d.data)
+    if n <= 0 {
+        d.fail()
+        return 0
+    }
+    d.data = d.data[n:]
+    return v
+}
+
+// count reads a non-negative integer that fits in an int32.
+func (d *decoder) count() int {
+    v := d.uvarint()
+    if v > math.MaxInt32 {
+        d.fail()
+        return 0
+    }
+    return int(v)
+}
+
+// items reads the number of the items that follow, each of which takes at
+// least size bytes. It fails if the remaining data is too short to hold them,
+// so that a damaged count is caught before anything is allocated or indexed
+// with it.
+func (d *decoder) items(size int) int {
+    n := d.count()
+    if uint64(n)*uint64(size) > uint64(len(d.data)) {
+        d.fail()
+        return 0
+    }
+    return n
+}
+
+func (d *decoder) uint64() uint64 {
+    if len(d.data) < 8 {
+        d.fail()
+        return 0
+    }
+    v := binary.LittleEndian.Uint64(d.data)
+    d.data = d.data[8:]
+    return v
+}
+
+func (d *decoder) float() float64 {
+    return math.Float64frombits(d.uint64())
+}
+
+func (d *decoder) string() string {
+    n := d.uvarint()
+    if n > uint64(len(d.data)) {
+        d.fail()
+        return ""
+    }
+    v := string(d.data[:n])
+    d.data = d.data[n:]
+    return v
+}
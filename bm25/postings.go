@@ -0,0 +1,221 @@
+package bm25
+
+import (
+    "encoding/binary"
+    "sort"
+)
+
+// postingBlockSize is the number of postings in each block of a posting list.
+const postingBlockSize = 64
+
+// postingSkipSize is the size of the skip entry of a block: the last document
+// ID of the block and the offset of the block in the encoded data, both as
+// little-endian uint32.
+const postingSkipSize = 8
+
+// postingList holds the documents containing a term along with the frequency
+// of the term in each of them, in ascending document order. The postings are
+// compressed in blocks of postingBlockSize: each block holds the gaps between
+// consecutive document IDs followed by the frequencies, both encoded with the
+// posting codec of the index. A skip entry per block lets readers
+// jump to the block holding a document without decoding the blocks before it.
+//
+// The skip entries and the blocks are plain byte slices, so the posting lists
+// of a memory-mapped index point straight into the mapping.
+type postingList struct {
+    n     int
+    skips []byte
+    data  []byte
+    codec blockCodec
+}
+
+// numBlocks returns the number of blocks the posting list is divided into.
+// Block i holds the postings from i*postingBlockSize up to
+// (i+1)*postingBlockSize.
+func (p *postingList) numBlocks() int {
+    return len(p.skips) / postingSkipSize
+}
+
+// blockLast returns the last document ID of the given block.
+func (p *postingList) blockLast(blk int) int {
+    return int(binary.LittleEndian.Uint32(p.skips[blk*postingSkipSize:]))
+}
+
+// blockOffset returns the offset of the given block in the encoded data.
+func (p *postingList) blockOffset(blk int) int {
+    return int(binary.LittleEndian.Uint32(p.skips[blk*postingSkipSize+4:]))
+}
+
+// blockLen returns the number of postings in the given block.
+func (p *postingList) blockLen(blk int) int {
+    return Min(postingBlockSize, p.n-blk*postingBlockSize)
+}
+
+// decodeBlock decodes the document IDs and frequencies of the given block and
+// returns the number of postings in it, or 0 if the block does not decode.
+func (p *postingList) decodeBlock(blk int, docIDs, freqs *[postingBlockSize]int) int {
+    n := p.blockLen(blk)
+    start := p.blockOffset(blk)
+    if n <= 0 || start > len(p.data) {
+        return 0
+    }
+
+    var vals [postingBlockSize]uint32
+    src := p.data[start:]
+    read := p.codec.readBlock(src, vals[:n])
+    if read < 0 {
+        return 0
+    }
+    docID := -1
+    if blk > 0 {
+        docID = p.blockLast(blk - 1)
+    }
+    for i, gap := range vals[:n] {
+        docID += int(gap) + 1
+        docIDs[i] = docID
+    }
+    if docID != p.blockLast(blk) {
+        return 0
+    }
+
+    if p.codec.readBlock(src[read:], vals[:n]) < 0 {
+        return 0
+    }
+    for i, freq := range vals[:n] {
+        freqs[i] = int(freq) + 1
+    }
+    return n
+}
+
+// freq returns the frequency of the term in the given document, or 0 if the
+// document does not contain the term.
+func (p *postingList) freq(docID int) int {
+    it := p.iterator()
+    it.advance(docID)
+    if it.valid() && it.doc() == docID {
+        return it.freq()
+    }
+    return 0
+}
+
+// iterator returns an iterator positioned on the first posting.
+func (p *postingList) iterator() postingIterator {
+    it := postingIterator{p: p, blk: -1}
+    it.load(0)
+    return it
+}
+
+// postingIterator walks a posting list in document order, decoding one block
+// at a time.
+type postingIterator struct {
+    p      *postingList
+    blk    int
+    i      int
+    n      int
+    docIDs [postingBlockSize]int
+    freqs  [postingBlockSize]int
+}
+
+// load decodes the given block and positions the iterator on its first
+// posting. Past the last block, or on a block that does not decode, the
+// iterator is exhausted.
+func (it *postingIterator) load(blk int) {
+    it.blk, it.i, it.n = blk, 0, 0
+    if blk < it.p.numBlocks() {
+        it.n = it.p.decodeBlock(blk, &it.docIDs, &it.freqs)
+    }
+    if it.n == 0 {
+        it.blk = it.p.numBlocks()
+    }
+}
+
+// valid reports whether the iterator is positioned on a posting.
+func (it *postingIterator) valid() bool {
+    return it.i < it.n
+}
+
+// doc returns the document ID of the current posting.
+func (it *postingIterator) doc() int {
+    return it.docIDs[it.i]
+}
+
+// freq returns the frequency of the current posting.
+func (it *postingIterator) freq() int {
+    return it.freqs[it.i]
+}
+
+// block returns the block of the current posting.
+func (it *postingIterator) block() int {
+    return it.blk
+}
+
+// next moves the iterator to the next posting.
+func (it *postingIterator) next() {
+    it.i++
+    if it.i == it.n && it.blk+1 < it.p.numBlocks() {
+        it.load(it.blk + 1)
+    }
+}
+
+// advance moves the iterator to the first posting with a document ID of at
+// least target. The skip entries locate the block holding it, so the blocks in
+// between are never decoded.
+func (it *postingIterator) advance(target int) {
+    if !it.valid() || it.doc() >= target {
+        return
+    }
+    p := it.p
+    if p.blockLast(it.blk) < target {
+        blk := it.blk + 1
+        blk += sort.Search(p.numBlocks()-blk, func(i int) bool {
+            return p.blockLast(blk+i) >= target
+        })
+        if blk == p.numBlocks() {
+            it.i = it.n
+            return
+        }
+        it.load(blk)
+    }
+    for it.valid() && it.doc() < target {
+        it.i++
+    }
+}
+
+// postingsBuilder collects the postings of a term in document order and
+// encodes them into a posting list.
+type postingsBuilder struct {
+    docIDs []int
+    freqs  []int
+}
+
+// add appends a posting. Document IDs must be added in ascending order.
+func (pb *postingsBuilder) add(docID, freq int) {
+    pb.docIDs = append(pb.docIDs, docID)
+    pb.freqs = append(pb.freqs, freq)
+}
+
+// build encodes the collected postings with the given codec.
+func (pb *postingsBuilder) build(codec PostingCodec) *postingList {
+    n := len(pb.docIDs)
+    numBlocks := (n + postingBlockSize - 1) / postingBlockSize
+    p := &postingList{n: n, skips: make([]byte, numBlocks*postingSkipSize), codec: codec.blockCodec()}
+
+    var vals [postingBlockSize]uint32
+    prev := -1
+    for blk := 0; blk < numBlocks; blk++ {
+        start, end := blk*postingBlockSize, Min((blk+1)*postingBlockSize, n)
+        binary.LittleEndian.PutUint32(p.skips[blk*postingSkipSize:], uint32(pb.docIDs[end-1]))
+        binary.LittleEndian.PutUint32(p.skips[blk*postingSkipSize+4:], uint32(len(p.data)))
+
+        for i, docID := range pb.docIDs[start:end] {
+            vals[i] = uint32(docID - prev - 1)
+            prev = docID
+        }
+        p.data = p.codec.appendBlock(p.data, vals[:end-start])
+        for i, freq := range pb.freqs[start:end] {
+            vals[i] = uint32(freq - 1)
+        }
+        p.data = p.codec.appendBlock(p.data, vals[:end-start])
+    }
+    return p
+}
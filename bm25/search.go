@@ -0,0 +1,62 @@
+package bm25
+
+import (
+    "context"
+    "errors"
+)
+
+// Hit is a single search result.
+type Hit struct {
+    // DocID is the position of the document in the corpus.
+    DocID int
+    // Score is the relevance score of the document for the query.
+    Score float64
+    // ExternalID is the identifier given to the document with
+    // WithExternalIDs, or the empty string if none was given.
+    ExternalID string
+}
+
+// Document returns the original text of the document with the given ID.
+func (b *bm25Base) Document(docID int) (string, error) {
+    if err := b.checkLive(docID); err != nil {
+        return "", err
+    }
+    return b.document(docID), nil
+}
+
+// ExternalID returns the external identifier of the document with the given
+// ID, or the empty string if no external identifiers were given.
+func (b *bm25Base) ExternalID(docID int) (string, error) {
+    if err := b.checkLive(docID); err != nil {
+        return "", err
+    }
+    return b.externalID(docID), nil
+}
+
+// Search returns the top N hits for the given query.
+func (b *bm25Base) Search(query []string, n int) ([]Hit, error) {
+    return nil, errors.New("not implemented")
+}
+
+// Analyze returns the terms of the given text, analyzed as the documents of
+// the index are: with the analyzer given with WithAnalyzer, or else with the
+// tokenizer function. It returns nil for an index opened without either.
+func (b *bm25Base) Analyze(text string) []string {
+    if b.tokenizer == nil {
+        return nil
+    }
+    return b.tokenizer(text)
+}
+
+// searchText analyzes the query text and returns the top N hits for the
+// resulting terms.
+func (b *bm25Base) searchText(ctx context.Context, text string, n int, s termScorer) ([]Hit, error) {
+    if b.tokenizer == nil {
+        return nil, errors.New("index has no analyzer, open it with WithAnalyzer to search text")
+    }
+    query := b.tokenizer(text)
+    if len(query) == 0 {
+        return nil, errors.New("query text has no terms")
+    }
+    return b.search(ctx, query, n, s)
+}
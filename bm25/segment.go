@@ -0,0 +1,372 @@
+package bm25
+
+import (
+    "errors"
+    "math"
+    "sort"
+    "sync"
+)
+
+// DefaultMaxBufferedDocs is the default maximum number of documents flushed
+// into a single segment.
+const DefaultMaxBufferedDocs = 10000
+
+// segment is an immutable part of the inverted index, holding the postings of
+// a contiguous range of document IDs. Documents keep their IDs for the life of
+// the index: segments are only ever replaced by a merge of adjacent segments,
+// which covers the same range, or renumbered all together by Compact.
+//
+// The collection statistics are kept globally by bm25Base, so a segment only
+// needs its postings. It also caches the score bounds of its terms under the
+// current statistics, which are dropped whenever the statistics change. The
+// bounds are computed lazily by concurrent queries, so boundsMu guards them.
+//
+// The terms of a segment are held in a front-coded dictionary, and its
+// posting lists are indexed by term ordinal. The segment of a memory-mapped
+// index reads its postings from the file instead, and has no terms of its own.
+type segment struct {
+    base     int
+    numDocs  int
+    terms    *termDict
+    postings []*postingList
+    file     *mappedFile
+
+    boundsMu       sync.RWMutex
+    maxScores      map[string]float64
+    blockMaxScores map[string][]float64
+}
+
+// newSegment creates an empty segment whose first document has the given ID.
+func newSegment(base int) *segment {
+    return &segment{
+        base:           base,
+        terms:          newTermDict(nil),
+        maxScores:      make(map[string]float64),
+        blockMaxScores: make(map[string][]float64),
+    }
+}
+
+// segmentBuilder collects the postings of a new segment, which are
+// compressed once the segment is complete.
+type segmentBuilder struct {
+    seg      *segment
+    postings map[string]*postingsBuilder
+}
+
+// newSegmentBuilder creates a builder for a segment whose first document has
+// the given ID.
+func newSegmentBuilder(base int) *segmentBuilder {
+    return &segmentBuilder{seg: newSegment(base), postings: make(map[string]*postingsBuilder)}
+}
+
+// add appends the term frequencies of the next document of the segment.
+func (sb *segmentBuilder) add(docID int, termCounts map[string]int) {
+    for token, freq := range termCounts {
+        sb.addPosting(token, docID, freq)
+    }
+    sb.seg.numDocs++
+}
+
+// addPosting appends a posting of the term. Postings must be added in
+// document order.
+func (sb *segmentBuilder) addPosting(term string, docID, freq int) {
+    pb, ok := sb.postings[term]
+    if !ok {
+        pb = &postingsBuilder{}
+        sb.postings[term] = pb
+    }
+    pb.add(docID, freq)
+}
+
+// build compresses the collected postings with the given codec, builds the
+// term dictionary and returns the segment.
+func (sb *segmentBuilder) build(codec PostingCodec) *segment {
+    terms := sortedTerms(sb.postings)
+    sb.seg.terms = newTermDict(terms)
+    sb.seg.postings = make([]*postingList, len(terms))
+    for ord, term := range terms {
+        sb.seg.postings[ord] = sb.postings[term].build(codec)
+    }
+    sb.postings = nil
+    return sb.seg
+}
+
+// postingList returns the postings of the term in the segment.
+func (s *segment) postingList(term string) (*postingList, bool) {
+    if s.file != nil {
+        return s.file.postingList(term)
+    }
+    ord, ok := s.terms.lookup(term)
+    if !ok {
+        return nil, false
+    }
+    return s.postings[ord], true
+}
+
+// forEachPostingList calls fn for every term of the segment, in order, along
+// with its postings.
+func (s *segment) forEachPostingList(fn func(term string, p *postingList)) {
+    for it := s.terms.iterator(0); it.valid(); it.next() {
+        fn(string(it.term), s.postings[it.ord])
+    }
+}
+
+// forEachDocTerm calls fn for every term of the given document of the segment,
+// in order, along with its frequency in the document. It walks every posting
+// list of the segment, skipping to the document block by block.
+func (s *segment) forEachDocTerm(docID int, fn func(term string, freq int)) {
+    s.forEachPostingList(func(term string, p *postingList) {
+        if freq := p.freq(docID); freq > 0 {
+            fn(term, freq)
+        }
+    })
+}
+
+// resetBounds drops the cached score bounds of the segment.
+func (s *segment) resetBounds() {
+    s.boundsMu.Lock()
+    defer s.boundsMu.Unlock()
+    s.maxScores = make(map[string]float64)
+    s.blockMaxScores = make(map[string][]float64)
+}
+
+// mergeSegments merges adjacent segments, in order, into a new segment whose
+// postings are compressed with the given codec. The postings of deleted
+// documents are kept, as the merge runs concurrently with deletions; Compact
+// reclaims them.
+func mergeSegments(segs []*segment, codec PostingCodec) *segment {
+    merged := newSegmentBuilder(segs[0].base)
+    for _, seg := range segs {
+        seg.forEachPostingList(func(term string, p *postingList) {
+            for it := p.iterator(); it.valid(); it.next() {
+                merged.addPosting(term, it.doc(), it.freq())
+            }
+        })
+        merged.seg.numDocs += seg.numDocs
+    }
+    return merged.build(codec)
+}
+
+// segmentsSnapshot returns the current segments. The returned slice is never
+// modified, so it can be searched while merges replace segments.
+func (b *bm25Base) segmentsSnapshot() []*segment {
+    b.segMu.RLock()
+    defer b.segMu.RUnlock()
+    return b.segments
+}
+
+// NumSegments returns the number of segments the index is made of.
+func (b *bm25Base) NumSegments() int {
+    return len(b.segmentsSnapshot())
+}
+
+// segmentOf returns the segment holding the given document.
+func segmentOf(segs []*segment, docID int) *segment {
+    i := sort.Search(len(segs), func(i int) bool {
+        return segs[i].base+segs[i].numDocs > docID
+    })
+    return segs[i]
+}
+
+// termFreq returns the frequency of the term in the given document.
+func termFreq(segs []*segment, term string, docID int) int {
+    p, ok := segmentOf(segs, docID).postingList(term)
+    if !ok {
+        return 0
+    }
+    return p.freq(docID)
+}
+
+// forEachPosting calls fn for every posting of the term in a live document,
+// in document order.
+func (b *bm25Base) forEachPosting(term string, fn func(docID, freq int)) {
+    for _, seg := range b.segmentsSnapshot() {
+        p, ok := seg.postingList(term)
+        if !ok {
+            continue
+        }
+        for it := p.iterator(); it.valid(); it.next() {
+            if !b.isDeleted(it.doc()) {
+                fn(it.doc(), it.freq())
+            }
+        }
+    }
+}
+
+// addSegments appends newly flushed segments and starts merging in the
+// background if the merge policy finds segments to merge. Compact calls it
+// without segments to merge the segments it rewrote.
+func (b *bm25Base) addSegments(segs ...*segment) {
+    b.segMu.Lock()
+    defer b.segMu.Unlock()
+
+    // Appending never writes to the part of the array that snapshots see.
+    b.segments = append(b.segments, segs...)
+    if b.merging {
+        // The running merge loop picks up the new segments.
+        return
+    }
+    b.merging = true
+    b.merges.Add(1)
+    go b.mergeLoop()
+}
+
+// mergeLoop merges segments as long as the merge policy finds segments to
+// merge. Only one merge loop runs at a time.
+func (b *bm25Base) mergeLoop() {
+    defer b.merges.Done()
+    for {
+        b.segMu.Lock()
+        segs := b.segments
+        start, end, ok := b.mergePolicy.FindMerge(segmentSizes(segs))
+        if !ok || start < 0 || end > len(segs) || end-start < 2 {
+            b.merging = false
+            b.segMu.Unlock()
+            return
+        }
+        b.segMu.Unlock()
+
+        merged := mergeSegments(segs[start:end], b.codec)
+
+        // Only flushes, which append, can change the segments while the merge
+        // runs, so the merged range is still in place. Compact, the only other
+        // writer, replaces every segment but waits for the merges to finish
+        // before it does.
+        b.segMu.Lock()
+        current := b.segments
+        replaced := make([]*segment, 0, len(current)-(end-start)+1)
+        replaced = append(replaced, current[:start]...)
+        replaced = append(replaced, merged)
+        replaced = append(replaced, current[end:]...)
+        b.segments = replaced
+        b.segMu.Unlock()
+
+        if b.logger != nil {
+            b.logger.Printf("Merged %d segments into a segment of %d documents", end-start, merged.numDocs)
+        }
+    }
+}
+
+// WaitForMerges blocks until all background merges have finished.
+func (b *bm25Base) WaitForMerges() {
+    b.merges.Wait()
+}
+
+// segmentSizes returns the number of documents of each segment.
+func segmentSizes(segs []*segment) []int {
+    sizes := make([]int, len(segs))
+    for i, seg := range segs {
+        sizes[i] = seg.numDocs
+    }
+    return sizes
+}
+
+// MergePolicy decides which segments to merge. FindMerge is given the number
+// of documents of each segment, in document order, and returns the range
+// [start, end) of at least two adjacent segments to merge next, or false if no
+// merge is needed. Only adjacent segments can be merged, so that document IDs
+// stay unchanged.
+type MergePolicy interface {
+    FindMerge(segmentSizes []int) (start, end int, ok bool)
+}
+
+// NoMergePolicy never merges segments.
+type NoMergePolicy struct{}
+
+// FindMerge never finds segments to merge.
+func (NoMergePolicy) FindMerge(segmentSizes []int) (int, int, bool) {
+    return 0, 0, false
+}
+
+// TieredMergePolicy is a simplified version of Lucene's tiered merge policy.
+// Segments are grouped in tiers of similar size, each tier MaxMergeAtOnce
+// times larger than the one below it. When a tier holds more than
+// SegmentsPerTier segments, up to MaxMergeAtOnce adjacent segments of that tier
+// are merged into a segment of a higher tier, preferring the smallest merge.
+type TieredMergePolicy struct {
+    // SegmentsPerTier is the number of segments a tier may hold before
+    // segments are merged.
+    SegmentsPerTier int
+    // MaxMergeAtOnce is the maximum number of segments merged at once.
+    MaxMergeAtOnce int
+    // FloorSegmentDocs is the size below which all segments fall in the
+    // lowest tier.
+    FloorSegmentDocs int
+    // MaxSegmentDocs is the size merged segments may not exceed.
+    MaxSegmentDocs int
+}
+
+// DefaultTieredMergePolicy returns the merge policy used unless another is
+// selected with WithMergePolicy.
+func DefaultTieredMergePolicy() TieredMergePolicy {
+    return TieredMergePolicy{
+        SegmentsPerTier:  10,
+        MaxMergeAtOnce:   10,
+        FloorSegmentDocs: 1000,
+        MaxSegmentDocs:   5000000,
+    }
+}
+
+// validate checks that the policy settings are usable.
+func (p TieredMergePolicy) validate() error {
+    if p.SegmentsPerTier < 2 {
+        return errors.New("segments per tier must be at least 2")
+    }
+    if p.MaxMergeAtOnce < 2 {
+        return errors.New("max merge at once must be at least 2")
+    }
+    if p.FloorSegmentDocs <= 0 || p.MaxSegmentDocs <= 0 {
+        return errors.New("segment sizes must be positive integers")
+    }
+    return nil
+}
+
+// tier returns the tier of a segment with the given number of documents.
+func (p TieredMergePolicy) tier(size int) int {
+    if size <= p.FloorSegmentDocs {
+        return 0
+    }
+    return int(math.Log(float64(size)/float64(p.FloorSegmentDocs)) / math.Log(float64(p.MaxMergeAtOnce)))
+}
+
+// FindMerge returns the smallest run of adjacent segments of the lowest
+// overfull tier to merge.
+func (p TieredMergePolicy) FindMerge(segmentSizes []int) (int, int, bool) {
+    tiers := make([]int, len(segmentSizes))
+    counts := make(map[int]int)
+    maxTier := 0
+    for i, size := range segmentSizes {
+        tiers[i] = p.tier(size)
+        counts[tiers[i]]++
+        if tiers[i] > maxTier {
+            maxTier = tiers[i]
+        }
+    }
+
+    for t := 0; t <= maxTier; t++ {
+        if counts[t] <= p.SegmentsPerTier {
+            continue
+        }
+
+        bestStart, bestEnd, bestSize := 0, 0, 0
+        for start := 0; start < len(segmentSizes); start++ {
+            if tiers[start] != t {
+                continue
+            }
+            end, size := start, 0
+            for end < len(segmentSizes) && end-start < p.MaxMergeAtOnce && tiers[end] == t && size+segmentSizes[end] <= p.MaxSegmentDocs {
+                size += segmentSizes[end]
+                end++
+            }
+            // Prefer merging the most segments, then the fewest documents.
+            if end-start >= 2 && (end-start > bestEnd-bestStart || (end-start == bestEnd-bestStart && size < bestSize)) {
+                bestStart, bestEnd, bestSize = start, end, size
+            }
+        }
+        if bestEnd > 0 {
+            return bestStart, bestEnd, true
+        }
+    }
+
+    return 0, 0, false
+}
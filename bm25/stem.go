@@ -0,0 +1,145 @@
+package bm25
+
+import (
+    "errors"
+    "sort"
+    "strconv"
+    "unicode/utf8"
+)
+
+// Stemmer reduces a word to its stem, so that inflected forms of a word, such
+// as "runs" and "running", are indexed and searched as the same term. The
+// stemmers of this package expect lowercase words.
+type Stemmer func(word string) string
+
+// StemFilter returns a token filter that replaces every token with its stem.
+// It should follow LowercaseFilter. Because the analyzer of an index is
+// applied to both documents and queries, the same stems are produced at index
+// and query time.
+func StemFilter(stemmer Stemmer) TokenFilter {
+    return func(tokens []string) []string {
+        for i, token := range tokens {
+            tokens[i] = stemmer(token)
+        }
+        return tokens
+    }
+}
+
+// SnowballStemmer returns the Snowball stemmer of the given language, by
+// English name or ISO 639-1 code: "english" ("en"), "german" ("de"),
+// "french" ("fr") or "spanish" ("es").
+func SnowballStemmer(language string) (Stemmer, error) {
+    switch languageCode(language) {
+    case "en":
+        return EnglishStemmer, nil
+    case "de":
+        return GermanStemmer, nil
+    case "fr":
+        return FrenchStemmer, nil
+    case "es":
+        return SpanishStemmer, nil
+    }
+    return nil, errors.New("no stemmer for language " + strconv.Quote(language))
+}
+
+// MinimalEnglishStemmer is a light stemmer that only reduces English plurals
+// to the singular, such as "runs" to "run" and "queries" to "query". It
+// conflates fewer words than EnglishStemmer, so it rarely merges unrelated
+// words, and its stems are real words.
+func MinimalEnglishStemmer(word string) string {
+    n := len(word)
+    if n < 3 || word[n-1] != 's' {
+        return word
+    }
+    switch word[n-2] {
+    case 'u', 's':
+        return word
+    case 'e':
+        if n > 3 && word[n-3] == 'i' && word[n-4] != 'a' && word[n-4] != 'e' {
+            return word[:n-3] + "y"
+        }
+        if c := word[n-3]; c == 'i' || c == 'a' || c == 'o' || c == 'e' {
+            return word
+        }
+    }
+    return word[:n-1]
+}
+
+// suffixSet is a set of suffixes that is searched longest first, like the
+// among command of the Snowball language.
+type suffixSet struct {
+    suffixes []string
+    lengths  []int
+}
+
+// newSuffixSet creates a set of the given suffixes.
+func newSuffixSet(suffixes ...string) *suffixSet {
+    sort.SliceStable(suffixes, func(i, j int) bool {
+        return utf8.RuneCountInString(suffixes[i]) > utf8.RuneCountInString(suffixes[j])
+    })
+    s := &suffixSet{suffixes: suffixes, lengths: make([]int, len(suffixes))}
+    for i, suffix := range suffixes {
+        s.lengths[i] = utf8.RuneCountInString(suffix)
+    }
+    return s
+}
+
+// find returns the longest suffix of the set that ends the word and starts at
+// or after limit, and the index at which it starts. It returns an empty
+// suffix and -1 if there is none.
+func (s *suffixSet) find(w []rune, limit int) (string, int) {
+    for i, suffix := range s.suffixes {
+        if start := len(w) - s.lengths[i]; start >= limit && hasSuffixRunes(w, suffix) {
+            return suffix, start
+        }
+    }
+    return "", -1
+}
+
+// hasSuffixRunes reports whether the word ends with the suffix.
+func hasSuffixRunes(w []rune, suffix string) bool {
+    i := len(w)
+    for j := len(suffix); j > 0; {
+        r, size := utf8.DecodeLastRuneInString(suffix[:j])
+        j -= size
+        i--
+        if i < 0 || w[i] != r {
+            return false
+        }
+    }
+    return true
+}
+
+// replaceSuffix replaces the end of the word from start on with the
+// replacement.
+func replaceSuffix(w []rune, start int, replacement string) []rune {
+    return append(w[:start], []rune(replacement)...)
+}
+
+// regionAfter returns the start of the region that follows the first
+// non-vowel after the first vowel at or after from, the definition of the R1
+// and R2 regions of the Snowball stemmers. It returns the length of the word
+// if there is no such region.
+func regionAfter(w []rune, from int, isVowel func(rune) bool) int {
+    for i := from; i < len(w); i++ {
+        if isVowel(w[i]) {
+            for j := i + 1; j < len(w); j++ {
+                if !isVowel(w[j]) {
+                    return j + 1
+                }
+            }
+            break
+        }
+    }
+    return len(w)
+}
+
+// containsVowel reports whether any rune of the word is a vowel.
+func containsVowel(w []rune, isVowel func(rune) bool) bool {
+    for _, r := range w {
+        if isVowel(r) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,201 @@
+package bm25
+
+import "strings"
+
+// The English stemmer implements the Porter2 algorithm of the Snowball
+// project: https://snowballstem.org/algorithms/english/stemmer.html
+
+var englishExceptions = map[string]string{
+    "skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+    "idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+    "sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+// englishInvariants are left alone once step 1a has removed their plural.
+var englishInvariants = map[string]bool{
+    "inning": true, "outing": true, "canning": true, "herring": true, "earring": true,
+    "proceed": true, "exceed": true, "succeed": true,
+}
+
+var (
+    englishStep0  = newSuffixSet("'", "'s", "'s'")
+    englishStep1a = newSuffixSet("sses", "ied", "ies", "s", "us", "ss")
+    englishStep1b = newSuffixSet("eed", "eedly", "ed", "edly", "ing", "ingly")
+    englishStep2  = newSuffixSet("tional", "enci", "anci", "abli", "entli", "izer", "ization", "ational", "ation", "ator",
+        "alism", "aliti", "alli", "fulness", "ousli", "ousness", "iveness", "iviti", "biliti", "bli", "ogi", "fulli",
+        "lessli", "li")
+    englishStep3 = newSuffixSet("tional", "ational", "alize", "icate", "iciti", "ical", "ful", "ness", "ative")
+    englishStep4 = newSuffixSet("al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent", "ism",
+        "ate", "iti", "ous", "ive", "ize", "ion")
+    englishStep5 = newSuffixSet("e", "l")
+)
+
+// englishStep2Replacements are the replacements of the step 2 suffixes that
+// need no further condition.
+var englishStep2Replacements = map[string]string{
+    "tional": "tion", "enci": "ence", "anci": "ance", "abli": "able", "entli": "ent", "izer": "ize", "ization": "ize",
+    "ational": "ate", "ation": "ate", "ator": "ate", "alism": "al", "aliti": "al", "alli": "al", "fulness": "ful",
+    "ousli": "ous", "ousness": "ous", "iveness": "ive", "iviti": "ive", "biliti": "ble", "bli": "ble", "fulli": "ful",
+    "lessli": "less",
+}
+
+// englishStep3Replacements are the replacements of the step 3 suffixes but
+// "ative".
+var englishStep3Replacements = map[string]string{
+    "tional": "tion", "ational": "ate", "alize": "al", "icate": "ic", "iciti": "ic", "ical": "ic", "ful": "", "ness": "",
+}
+
+func isEnglishVowel(r rune) bool {
+    switch r {
+    case 'a', 'e', 'i', 'o', 'u', 'y':
+        return true
+    }
+    return false
+}
+
+// isEnglishShortSyllable reports whether the word ends with a short syllable:
+// a vowel followed by a non-vowel other than w, x or Y and preceded by a
+// non-vowel, or a vowel at the start of the word followed by a non-vowel.
+func isEnglishShortSyllable(w []rune) bool {
+    n := len(w)
+    if n == 2 {
+        return isEnglishVowel(w[0]) && !isEnglishVowel(w[1])
+    }
+    return n >= 3 && !isEnglishVowel(w[n-1]) && w[n-1] != 'w' && w[n-1] != 'x' && w[n-1] != 'Y' &&
+        isEnglishVowel(w[n-2]) && !isEnglishVowel(w[n-3])
+}
+
+// EnglishStemmer is the Snowball English (Porter2) stemmer.
+func EnglishStemmer(word string) string {
+    if stem, ok := englishExceptions[word]; ok {
+        return stem
+    }
+    w := []rune(word)
+    if len(w) < 3 {
+        return word
+    }
+
+    // Remove an initial apostrophe and mark the consonant y as Y.
+    if w[0] == '\'' {
+        w = w[1:]
+    }
+    for i, r := range w {
+        if r == 'y' && (i == 0 || isEnglishVowel(w[i-1])) {
+            w[i] = 'Y'
+        }
+    }
+
+    p1 := -1
+    for _, prefix := range []string{"gener", "commun", "arsen"} {
+        if len(w) >= len(prefix) && string(w[:len(prefix)]) == prefix {
+            p1 = len(prefix)
+        }
+    }
+    if p1 < 0 {
+        p1 = regionAfter(w, 0, isEnglishVowel)
+    }
+    p2 := regionAfter(w, p1, isEnglishVowel)
+
+    // Step 0: possessives.
+    if _, start := englishStep0.find(w, 0); start >= 0 {
+        w = w[:start]
+    }
+
+    // Step 1a: plurals.
+    switch suffix, start := englishStep1a.find(w, 0); suffix {
+    case "sses":
+        w = w[:start+2]
+    case "ied", "ies":
+        if start > 1 {
+            w = replaceSuffix(w, start, "i")
+        } else {
+            w = replaceSuffix(w, start, "ie")
+        }
+    case "s":
+        if start > 0 && containsVowel(w[:start-1], isEnglishVowel) {
+            w = w[:start]
+        }
+    }
+    if englishInvariants[string(w)] {
+        return string(w)
+    }
+
+    // Step 1b: past tenses and gerunds.
+    switch suffix, start := englishStep1b.find(w, 0); suffix {
+    case "eed", "eedly":
+        if start >= p1 {
+            w = replaceSuffix(w, start, "ee")
+        }
+    case "ed", "edly", "ing", "ingly":
+        if containsVowel(w[:start], isEnglishVowel) {
+            w = w[:start]
+            n := len(w)
+            switch {
+            case hasSuffixRunes(w, "at"), hasSuffixRunes(w, "bl"), hasSuffixRunes(w, "iz"):
+                w = append(w, 'e')
+            case n >= 2 && w[n-1] == w[n-2] && strings.ContainsRune("bdfgmnprt", w[n-1]):
+                w = w[:n-1]
+            case p1 == n && isEnglishShortSyllable(w):
+                w = append(w, 'e')
+            }
+        }
+    }
+
+    // Step 1c: a final y after a non-vowel that is not the first letter.
+    if n := len(w); n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !isEnglishVowel(w[n-2]) {
+        w[n-1] = 'i'
+    }
+
+    // Step 2: derivational suffixes in R1.
+    if suffix, start := englishStep2.find(w, 0); start >= p1 {
+        switch suffix {
+        case "ogi":
+            if start > 0 && w[start-1] == 'l' {
+                w = replaceSuffix(w, start, "og")
+            }
+        case "li":
+            if start > 0 && strings.ContainsRune("cdeghkmnrt", w[start-1]) {
+                w = w[:start]
+            }
+        default:
+            w = replaceSuffix(w, start, englishStep2Replacements[suffix])
+        }
+    }
+
+    // Step 3: more derivational suffixes in R1.
+    if suffix, start := englishStep3.find(w, 0); start >= p1 {
+        if suffix == "ative" {
+            if start >= p2 {
+                w = w[:start]
+            }
+        } else {
+            w = replaceSuffix(w, start, englishStep3Replacements[suffix])
+        }
+    }
+
+    // Step 4: suffixes in R2.
+    if suffix, start := englishStep4.find(w, 0); start >= p2 {
+        if suffix != "ion" || (start > 0 && (w[start-1] == 's' || w[start-1] == 't')) {
+            w = w[:start]
+        }
+    }
+
+    // Step 5: a final e or l.
+    switch suffix, start := englishStep5.find(w, 0); suffix {
+    case "e":
+        if start >= p2 || (start >= p1 && !isEnglishShortSyllable(w[:start])) {
+            w = w[:start]
+        }
+    case "l":
+        if start >= p2 && start > 0 && w[start-1] == 'l' {
+            w = w[:start]
+        }
+    }
+
+    for i, r := range w {
+        if r == 'Y' {
+            w[i] = 'y'
+        }
+    }
+    return string(w)
+}
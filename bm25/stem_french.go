@@ -0,0 +1,319 @@
+package bm25
+
+// The French stemmer implements the Snowball French algorithm:
+// https://snowballstem.org/algorithms/french/stemmer.html
+
+var (
+    frenchStandardSuffixes = newSuffixSet("ance", "iqUe", "isme", "able", "iste", "eux", "ances", "iqUes", "ismes",
+        "ables", "istes", "atrice", "ateur", "ation", "atrices", "ateurs", "ations", "logie", "logies", "usion", "ution",
+        "usions", "utions", "ence", "ences", "ement", "ements", "ité", "ités", "if", "ive", "ifs", "ives", "eaux", "aux",
+        "euse", "euses", "issement", "issements", "amment", "emment", "ment", "ments")
+    frenchEmentSuffixes = newSuffixSet("iv", "eus", "abl", "iqU", "ièr", "Ièr")
+    frenchIteSuffixes   = newSuffixSet("abil", "ic", "iv")
+    frenchIVerbSuffixes = newSuffixSet("îmes", "ît", "îtes", "i", "ie", "ies", "ir", "ira", "irai", "iraIent", "irais",
+        "irait", "iras", "irent", "irez", "iriez", "irions", "irons", "iront", "is", "issaIent", "issais", "issait",
+        "issant", "issante", "issantes", "issants", "isse", "issent", "isses", "issez", "issiez", "issions", "issons",
+        "it")
+    frenchVerbSuffixes = newSuffixSet("ions", "é", "ée", "ées", "és", "èrent", "er", "era", "erai", "eraIent", "erais",
+        "erait", "eras", "erez", "eriez", "erions", "erons", "eront", "ez", "iez", "âmes", "ât", "âtes", "a", "ai",
+        "aIent", "ais", "ait", "ant", "ante", "antes", "ants", "as", "asse", "assent", "asses", "assiez", "assions")
+    frenchResidualSuffixes = newSuffixSet("ion", "ier", "ière", "Ier", "Ière", "e", "ë")
+    frenchDoubles          = newSuffixSet("enn", "onn", "ett", "ell", "eill")
+)
+
+func isFrenchVowel(r rune) bool {
+    switch r {
+    case 'a', 'e', 'i', 'o', 'u', 'y', 'â', 'à', 'ë', 'é', 'ê', 'è', 'ï', 'î', 'ô', 'û', 'ù':
+        return true
+    }
+    return false
+}
+
+// frenchStemmer holds a word being stemmed and its regions.
+type frenchStemmer struct {
+    w          []rune
+    pV, p1, p2 int
+}
+
+// FrenchStemmer is the Snowball French stemmer.
+func FrenchStemmer(word string) string {
+    w := []rune(word)
+
+    // Mark the u, i and y that act as consonants, and the u after q, as
+    // such.
+    for i, r := range w {
+        prevVowel := i > 0 && isFrenchVowel(w[i-1])
+        nextVowel := i+1 < len(w) && isFrenchVowel(w[i+1])
+        switch {
+        case (r == 'u' || r == 'i') && prevVowel && nextVowel:
+            w[i] = r - 'a' + 'A'
+        case r == 'y' && (prevVowel || nextVowel):
+            w[i] = 'Y'
+        case r == 'u' && i > 0 && w[i-1] == 'q':
+            w[i] = 'U'
+        }
+    }
+
+    s := &frenchStemmer{w: w, pV: len(w)}
+    switch {
+    case len(w) >= 2 && isFrenchVowel(w[0]) && isFrenchVowel(w[1]):
+        s.pV = Min(3, len(w))
+    case len(w) >= 3 && (string(w[:3]) == "par" || string(w[:3]) == "col" || string(w[:3]) == "tap"):
+        s.pV = 3
+    default:
+        for i := 1; i < len(w); i++ {
+            if isFrenchVowel(w[i]) {
+                s.pV = i + 1
+                break
+            }
+        }
+    }
+    s.p1 = regionAfter(w, 0, isFrenchVowel)
+    s.p2 = regionAfter(w, s.p1, isFrenchVowel)
+
+    // Steps 1 to 3, or the residual suffixes of step 4 if no suffix was
+    // removed.
+    if s.standardSuffix() || s.iVerbSuffix() || s.verbSuffix() {
+        switch n := len(s.w); {
+        case n > 0 && s.w[n-1] == 'Y':
+            s.w[n-1] = 'i'
+        case n > 0 && s.w[n-1] == 'ç':
+            s.w[n-1] = 'c'
+        }
+    } else {
+        s.residualSuffix()
+    }
+
+    // Step 5: undouble.
+    if _, start := frenchDoubles.find(s.w, 0); start >= 0 {
+        s.w = s.w[:len(s.w)-1]
+    }
+
+    // Step 6: unaccent an é or è followed by non-vowels only.
+    i := len(s.w) - 1
+    for i >= 0 && !isFrenchVowel(s.w[i]) {
+        i--
+    }
+    if i >= 0 && i < len(s.w)-1 && (s.w[i] == 'é' || s.w[i] == 'è') {
+        s.w[i] = 'e'
+    }
+
+    for i, r := range s.w {
+        switch r {
+        case 'I':
+            s.w[i] = 'i'
+        case 'U':
+            s.w[i] = 'u'
+        case 'Y':
+            s.w[i] = 'y'
+        }
+    }
+    return string(s.w)
+}
+
+// precededBy reports whether the text before start ends with the suffix and,
+// if so, the index at which the suffix starts.
+func (s *frenchStemmer) precededBy(start int, suffix string) (int, bool) {
+    if !hasSuffixRunes(s.w[:start], suffix) {
+        return 0, false
+    }
+    return start - len([]rune(suffix)), true
+}
+
+// standardSuffix is step 1. It reports whether a suffix was removed; the
+// adverb suffixes are removed or replaced but reported as not removed, so
+// that the verb suffixes are looked for too.
+func (s *frenchStemmer) standardSuffix() bool {
+    suffix, start := frenchStandardSuffixes.find(s.w, 0)
+    switch suffix {
+    case "":
+        return false
+    case "ance", "iqUe", "isme", "able", "iste", "eux", "ances", "iqUes", "ismes", "ables", "istes":
+        if start < s.p2 {
+            return false
+        }
+        s.w = s.w[:start]
+    case "atrice", "ateur", "ation", "atrices", "ateurs", "ations":
+        if start < s.p2 {
+            return false
+        }
+        s.w = s.w[:start]
+        if ic, ok := s.precededBy(start, "ic"); ok {
+            s.deleteOrReplace(ic, "iqU")
+        }
+    case "logie", "logies":
+        if start < s.p2 {
+            return false
+        }
+        s.w = replaceSuffix(s.w, start, "log")
+    case "usion", "ution", "usions", "utions":
+        if start < s.p2 {
+            return false
+        }
+        s.w = replaceSuffix(s.w, start, "u")
+    case "ence", "ences":
+        if start < s.p2 {
+            return false
+        }
+        s.w = replaceSuffix(s.w, start, "ent")
+    case "ement", "ements":
+        if start < s.pV {
+            return false
+        }
+        s.w = s.w[:start]
+        switch before, at := frenchEmentSuffixes.find(s.w, 0); before {
+        case "iv":
+            if at >= s.p2 {
+                s.w = s.w[:at]
+                if a, ok := s.precededBy(at, "at"); ok && a >= s.p2 {
+                    s.w = s.w[:a]
+                }
+            }
+        case "eus":
+            if at >= s.p2 {
+                s.w = s.w[:at]
+            } else if at >= s.p1 {
+                s.w = replaceSuffix(s.w, at, "eux")
+            }
+        case "abl", "iqU":
+            if at >= s.p2 {
+                s.w = s.w[:at]
+            }
+        case "ièr", "Ièr":
+            if at >= s.pV {
+                s.w = replaceSuffix(s.w, at, "i")
+            }
+        }
+    case "ité", "ités":
+        if start < s.p2 {
+            return false
+        }
+        s.w = s.w[:start]
+        switch before, at := frenchIteSuffixes.find(s.w, 0); before {
+        case "abil":
+            s.deleteOrReplace(at, "abl")
+        case "ic":
+            s.deleteOrReplace(at, "iqU")
+        case "iv":
+            if at >= s.p2 {
+                s.w = s.w[:at]
+            }
+        }
+    case "if", "ive", "ifs", "ives":
+        if start < s.p2 {
+            return false
+        }
+        s.w = s.w[:start]
+        if at, ok := s.precededBy(start, "at"); ok && at >= s.p2 {
+            s.w = s.w[:at]
+            if ic, ok := s.precededBy(at, "ic"); ok {
+                s.deleteOrReplace(ic, "iqU")
+            }
+        }
+    case "eaux":
+        s.w = replaceSuffix(s.w, start, "eau")
+    case "aux":
+        if start < s.p1 {
+            return false
+        }
+        s.w = replaceSuffix(s.w, start, "al")
+    case "euse", "euses":
+        switch {
+        case start >= s.p2:
+            s.w = s.w[:start]
+        case start >= s.p1:
+            s.w = replaceSuffix(s.w, start, "eux")
+        default:
+            return false
+        }
+    case "issement", "issements":
+        if start < s.p1 || start == 0 || isFrenchVowel(s.w[start-1]) {
+            return false
+        }
+        s.w = s.w[:start]
+    case "amment":
+        if start >= s.pV {
+            s.w = replaceSuffix(s.w, start, "ant")
+        }
+        return false
+    case "emment":
+        if start >= s.pV {
+            s.w = replaceSuffix(s.w, start, "ent")
+        }
+        return false
+    case "ment", "ments":
+        if start > s.pV && isFrenchVowel(s.w[start-1]) {
+            s.w = s.w[:start]
+        }
+        return false
+    }
+    return true
+}
+
+// deleteOrReplace removes the end of the word from start on if it is in R2,
+// and replaces it otherwise.
+func (s *frenchStemmer) deleteOrReplace(start int, replacement string) {
+    if start >= s.p2 {
+        s.w = s.w[:start]
+    } else {
+        s.w = replaceSuffix(s.w, start, replacement)
+    }
+}
+
+// iVerbSuffix is step 2a, verb suffixes beginning with i.
+func (s *frenchStemmer) iVerbSuffix() bool {
+    _, start := frenchIVerbSuffixes.find(s.w, s.pV)
+    if start <= s.pV || isFrenchVowel(s.w[start-1]) {
+        return false
+    }
+    s.w = s.w[:start]
+    return true
+}
+
+// verbSuffix is step 2b, the other verb suffixes.
+func (s *frenchStemmer) verbSuffix() bool {
+    suffix, start := frenchVerbSuffixes.find(s.w, s.pV)
+    switch suffix {
+    case "":
+        return false
+    case "ions":
+        if start < s.p2 {
+            return false
+        }
+        s.w = s.w[:start]
+    case "âmes", "ât", "âtes", "a", "ai", "aIent", "ais", "ait", "ant", "ante", "antes", "ants", "as", "asse",
+        "assent", "asses", "assiez", "assions":
+        s.w = s.w[:start]
+        if start > s.pV && s.w[start-1] == 'e' {
+            s.w = s.w[:start-1]
+        }
+    default:
+        s.w = s.w[:start]
+    }
+    return true
+}
+
+// residualSuffix is step 4.
+func (s *frenchStemmer) residualSuffix() {
+    if n := len(s.w); n > 1 && s.w[n-1] == 's' {
+        switch s.w[n-2] {
+        case 'a', 'i', 'o', 'u', 'è', 's':
+        default:
+            s.w = s.w[:n-1]
+        }
+    }
+    switch suffix, start := frenchResidualSuffixes.find(s.w, s.pV); suffix {
+    case "ion":
+        if start >= s.p2 && start > s.pV && (s.w[start-1] == 's' || s.w[start-1] == 't') {
+            s.w = s.w[:start]
+        }
+    case "ier", "ière", "Ier", "Ière":
+        s.w = replaceSuffix(s.w, start, "i")
+    case "e":
+        s.w = s.w[:start]
+    case "ë":
+        if at, ok := s.precededBy(start, "gu"); ok && at >= s.pV {
+            s.w = s.w[:start]
+        }
+    }
+}
@@ -0,0 +1,130 @@
+package bm25
+
+// The German stemmer implements the Snowball German algorithm:
+// https://snowballstem.org/algorithms/german/stemmer.html
+
+var (
+    germanStep1 = newSuffixSet("em", "ern", "er", "e", "en", "es", "s")
+    germanStep2 = newSuffixSet("en", "er", "est", "st")
+    germanStep3 = newSuffixSet("end", "ung", "ig", "ik", "isch", "lich", "heit", "keit")
+)
+
+func isGermanVowel(r rune) bool {
+    switch r {
+    case 'a', 'e', 'i', 'o', 'u', 'y', 'ä', 'ö', 'ü':
+        return true
+    }
+    return false
+}
+
+// isGermanSEnding reports whether an s can be removed after the rune.
+func isGermanSEnding(r rune) bool {
+    switch r {
+    case 'b', 'd', 'f', 'g', 'h', 'k', 'l', 'm', 'n', 'r', 't':
+        return true
+    }
+    return false
+}
+
+// GermanStemmer is the Snowball German stemmer.
+func GermanStemmer(word string) string {
+    w := make([]rune, 0, len(word))
+    for _, r := range word {
+        if r == 'ß' {
+            w = append(w, 's', 's')
+        } else {
+            w = append(w, r)
+        }
+    }
+
+    // Mark u and y between vowels as consonants.
+    for i := 1; i+1 < len(w); i++ {
+        if isGermanVowel(w[i-1]) && isGermanVowel(w[i+1]) {
+            switch w[i] {
+            case 'u':
+                w[i] = 'U'
+            case 'y':
+                w[i] = 'Y'
+            }
+        }
+    }
+
+    // R1 is adjusted so that at least three letters precede it, but R2 is
+    // found after the unadjusted R1.
+    p1, p2 := len(w), len(w)
+    if len(w) >= 3 {
+        r1 := regionAfter(w, 0, isGermanVowel)
+        p1 = r1
+        if p1 < 3 {
+            p1 = 3
+        }
+        p2 = regionAfter(w, r1, isGermanVowel)
+    }
+
+    // Step 1: inflectional suffixes in R1.
+    if suffix, start := germanStep1.find(w, 0); start >= p1 {
+        switch suffix {
+        case "em", "ern", "er":
+            w = w[:start]
+        case "e", "en", "es":
+            w = w[:start]
+            if hasSuffixRunes(w, "niss") {
+                w = w[:len(w)-1]
+            }
+        case "s":
+            if start > 0 && isGermanSEnding(w[start-1]) {
+                w = w[:start]
+            }
+        }
+    }
+
+    // Step 2: more inflectional suffixes in R1.
+    if suffix, start := germanStep2.find(w, 0); start >= p1 {
+        if suffix != "st" {
+            w = w[:start]
+        } else if start > 3 && isGermanSEnding(w[start-1]) && w[start-1] != 'r' {
+            w = w[:start]
+        }
+    }
+
+    // Step 3: derivational suffixes in R2.
+    if suffix, start := germanStep3.find(w, 0); start >= p2 {
+        switch suffix {
+        case "end", "ung":
+            w = w[:start]
+            if n := len(w) - 2; n >= p2 && hasSuffixRunes(w, "ig") && (n == 0 || w[n-1] != 'e') {
+                w = w[:n]
+            }
+        case "ig", "ik", "isch":
+            if start == 0 || w[start-1] != 'e' {
+                w = w[:start]
+            }
+        case "lich", "heit":
+            w = w[:start]
+            if n := len(w) - 2; n >= p1 && (hasSuffixRunes(w, "er") || hasSuffixRunes(w, "en")) {
+                w = w[:n]
+            }
+        case "keit":
+            w = w[:start]
+            if n := len(w) - 4; n >= p2 && hasSuffixRunes(w, "lich") {
+                w = w[:n]
+            } else if n := len(w) - 2; n >= p2 && hasSuffixRunes(w, "ig") {
+                w = w[:n]
+            }
+        }
+    }
+
+    for i, r := range w {
+        switch r {
+        case 'U', 'ü':
+            w[i] = 'u'
+        case 'Y':
+            w[i] = 'y'
+        case 'ä':
+            w[i] = 'a'
+        case 'ö':
+            w[i] = 'o'
+        }
+    }
+    return string(w)
+}
@@ -0,0 +1,195 @@
+package bm25
+
+// The Spanish stemmer implements the Snowball Spanish algorithm:
+// https://snowballstem.org/algorithms/spanish/stemmer.html
+
+var (
+    spanishPronouns     = newSuffixSet("me", "se", "sela", "selo", "selas", "selos", "la", "le", "lo", "las", "les", "los", "nos")
+    spanishPronounVerbs = newSuffixSet("iéndo", "ándo", "ár", "ér", "ír", "ando", "iendo", "ar", "er", "ir", "yendo")
+    spanishStandard     = newSuffixSet("anza", "anzas", "ico", "ica", "icos", "icas", "ismo", "ismos", "able", "ables",
+        "ible", "ibles", "ista", "istas", "oso", "osa", "osos", "osas", "amiento", "amientos", "imiento", "imientos",
+        "adora", "ador", "ación", "adoras", "adores", "aciones", "ante", "antes", "ancia", "ancias", "logía", "logías",
+        "ución", "uciones", "encia", "encias", "amente", "mente", "idad", "idades", "iva", "ivo", "ivas", "ivos")
+    spanishAmenteSuffixes = newSuffixSet("iv", "os", "ic", "ad")
+    spanishMenteSuffixes  = newSuffixSet("ante", "able", "ible")
+    spanishIdadSuffixes   = newSuffixSet("abil", "ic", "iv")
+    spanishYVerbSuffixes  = newSuffixSet("ya", "ye", "yan", "yen", "yeron", "yendo", "yo", "yó", "yas", "yes", "yais", "yamos")
+    spanishVerbSuffixes   = newSuffixSet("en", "es", "éis", "emos",
+        "arían", "arías", "arán", "arás", "aríais", "aría", "aréis", "aríamos", "aremos", "ará", "aré",
+        "erían", "erías", "erán", "erás", "eríais", "ería", "eréis", "eríamos", "eremos", "erá", "eré",
+        "irían", "irías", "irán", "irás", "iríais", "iría", "iréis", "iríamos", "iremos", "irá", "iré",
+        "aba", "ada", "ida", "ía", "ara", "iera", "ad", "ed", "id", "ase", "iese", "aste", "iste", "an", "aban", "ían",
+        "aran", "ieran", "asen", "iesen", "aron", "ieron", "ado", "ido", "ando", "iendo", "ió", "ar", "er", "ir", "as",
+        "abas", "adas", "idas", "ías", "aras", "ieras", "ases", "ieses", "ís", "áis", "abais", "íais", "arais",
+        "ierais", "aseis", "ieseis", "asteis", "isteis", "ados", "idos", "amos", "ábamos", "íamos", "imos", "áramos",
+        "iéramos", "iésemos", "ásemos")
+    spanishResidualSuffixes = newSuffixSet("os", "a", "o", "á", "í", "ó", "e", "é")
+)
+
+// spanishUnaccented maps the accented verb endings before attached pronouns
+// to their unaccented forms.
+var spanishUnaccented = map[string]string{"iéndo": "iendo", "ándo": "ando", "ár": "ar", "ér": "er", "ír": "ir"}
+
+func isSpanishVowel(r rune) bool {
+    switch r {
+    case 'a', 'e', 'i', 'o', 'u', 'á', 'é', 'í', 'ó', 'ú', 'ü':
+        return true
+    }
+    return false
+}
+
+// spanishRV returns the start of the RV region of the word.
+func spanishRV(w []rune) int {
+    if len(w) < 2 {
+        return len(w)
+    }
+    // After the next vowel if the second letter is a consonant, after the
+    // next consonant if the first two letters are vowels, and after the
+    // third letter otherwise.
+    next := func(from int, vowel bool) int {
+        for i := from; i < len(w); i++ {
+            if isSpanishVowel(w[i]) == vowel {
+                return i + 1
+            }
+        }
+        return len(w)
+    }
+    switch first, second := isSpanishVowel(w[0]), isSpanishVowel(w[1]); {
+    case !second:
+        return next(2, true)
+    case first:
+        return next(2, false)
+    }
+    return Min(3, len(w))
+}
+
+// SpanishStemmer is the Snowball Spanish stemmer.
+func SpanishStemmer(word string) string {
+    w := []rune(word)
+    rv := spanishRV(w)
+    p1 := regionAfter(w, 0, isSpanishVowel)
+    p2 := regionAfter(w, p1, isSpanishVowel)
+
+    // Step 0: attached pronouns after a verb ending in RV.
+    if _, start := spanishPronouns.find(w, 0); start >= 0 {
+        switch verb, at := spanishPronounVerbs.find(w[:start], rv); verb {
+        case "":
+        case "iéndo", "ándo", "ár", "ér", "ír":
+            w = replaceSuffix(w, at, spanishUnaccented[verb])
+        case "yendo":
+            if at > 0 && w[at-1] == 'u' {
+                w = w[:start]
+            }
+        default:
+            w = w[:start]
+        }
+    }
+
+    // Step 1, or the verb suffixes of step 2 if no suffix was removed.
+    if !spanishStandardSuffix(&w, rv, p1, p2) {
+        if suffix, start := spanishYVerbSuffixes.find(w, rv); suffix != "" && start > 0 && w[start-1] == 'u' {
+            w = w[:start]
+        } else {
+            switch suffix, start := spanishVerbSuffixes.find(w, rv); suffix {
+            case "":
+            case "en", "es", "éis", "emos":
+                w = w[:start]
+                if start > 1 && w[start-1] == 'u' && w[start-2] == 'g' {
+                    w = w[:start-1]
+                }
+            default:
+                w = w[:start]
+            }
+        }
+    }
+
+    // Step 3: residual suffixes in RV.
+    switch suffix, start := spanishResidualSuffixes.find(w, rv); suffix {
+    case "e", "é":
+        w = w[:start]
+        if start > rv && w[start-1] == 'u' && start > 1 && w[start-2] == 'g' {
+            w = w[:start-1]
+        }
+    case "":
+    default:
+        w = w[:start]
+    }
+
+    for i, r := range w {
+        switch r {
+        case 'á':
+            w[i] = 'a'
+        case 'é':
+            w[i] = 'e'
+        case 'í':
+            w[i] = 'i'
+        case 'ó':
+            w[i] = 'o'
+        case 'ú':
+            w[i] = 'u'
+        }
+    }
+    return string(w)
+}
+
+// spanishStandardSuffix is step 1. It reports whether a suffix was removed.
+func spanishStandardSuffix(word *[]rune, rv, p1, p2 int) bool {
+    w := *word
+    defer func() { *word = w }()
+
+    suffix, start := spanishStandard.find(w, 0)
+    switch suffix {
+    case "":
+        return false
+    case "amente":
+        if start < p1 {
+            return false
+        }
+        w = w[:start]
+        switch before, at := spanishAmenteSuffixes.find(w, p2); before {
+        case "iv":
+            w = w[:at]
+            if at >= p2+2 && hasSuffixRunes(w, "at") {
+                w = w[:at-2]
+            }
+        case "os", "ic", "ad":
+            w = w[:at]
+        }
+        return true
+    }
+
+    if start < p2 {
+        return false
+    }
+    switch suffix {
+    case "adora", "ador", "ación", "adoras", "adores", "aciones", "ante", "antes", "ancia", "ancias":
+        w = w[:start]
+        if start >= p2+2 && hasSuffixRunes(w, "ic") {
+            w = w[:start-2]
+        }
+    case "logía", "logías":
+        w = replaceSuffix(w, start, "log")
+    case "ución", "uciones":
+        w = replaceSuffix(w, start, "u")
+    case "encia", "encias":
+        w = replaceSuffix(w, start, "ente")
+    case "mente":
+        w = w[:start]
+        if _, at := spanishMenteSuffixes.find(w, p2); at >= 0 {
+            w = w[:at]
+        }
+    case "idad", "idades":
+        w = w[:start]
+        if _, at := spanishIdadSuffixes.find(w, p2); at >= 0 {
+            w = w[:at]
+        }
+    case "iva", "ivo", "ivas", "ivos":
+        w = w[:start]
+        if start >= p2+2 && hasSuffixRunes(w, "at") {
+            w = w[:start-2]
+        }
+    default:
+        w = w[:start]
+    }
+    return true
+}
@@ -0,0 +1,152 @@
+package bm25
+
+import (
+    "bufio"
+    "errors"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// Stopwords is a set of words, such as "the" and "of", that are so common
+// that they say little about the documents containing them.
+type Stopwords map[string]bool
+
+// NewStopwords creates a set of the given words.
+func NewStopwords(words ...string) Stopwords {
+    s := make(Stopwords, len(words))
+    for _, word := range words {
+        s[word] = true
+    }
+    return s
+}
+
+// builtinStopwords are the stopword lists of StopwordsFor, by ISO 639-1 code.
+var builtinStopwords = map[string]string{
+    "en": `i me my myself we our ours ourselves you your yours yourself yourselves he him his himself she her hers
+        herself it its itself they them their theirs themselves what which who whom this that these those am is are
+        was were be been being have has had having do does did doing would should could ought a an the and but if or
+        because as until while of at by for with about against between into through during before after above below
+        to from up down in out on off over under again further then once here there when where why how all any both
+        each few more most other some such no nor not only own same so than too very can will just`,
+    "de": `aber alle allem allen aller alles als also am an ander andere anderem anderen anderer anderes anderm andern
+        anders auch auf aus bei bin bis bist da damit dann der den des dem die das dass du dich dir dein deine deinem
+        deinen deiner deines denn derer dessen doch dort durch ein eine einem einen einer eines einig einige einigem
+        einigen einiger einiges einmal er ihn ihm es etwas euer eure eurem euren eurer eures für gegen gewesen hab
+        habe haben hat hatte hatten hier hin hinter ich mich mir ihr ihre ihrem ihren ihrer ihres euch im in indem
+        ins ist jede jedem jeden jeder jedes jene jenem jenen jener jenes jetzt kann kein keine keinem keinen keiner
+        keines können könnte machen man manche manchem manchen mancher manches mein meine meinem meinen meiner meines
+        mit muss musste nach nicht nichts noch nun nur ob oder ohne sehr sein seine seinem seinen seiner seines selbst
+        sich sie ihnen sind so solche solchem solchen solcher solches soll sollte sondern sonst über um und uns unsere
+        unserem unseren unser unseres unter viel vom von vor während war waren warst was weg weil weiter welche
+        welchem welchen welcher welches wenn werde werden wie wieder will wir wird wirst wo wollen wollte würde würden
+        zu zum zur zwar zwischen`,
+    "fr": `au aux avec ce ces dans de des du elle en et eux il ils je la le les leur lui ma mais me même mes moi mon ne
+        nos notre nous on ou par pas pour qu que qui sa se ses son sur ta te tes toi ton tu un une vos votre vous c d j
+        l à m n s t y été étée étées étés étant suis es est sommes êtes sont serai seras sera serons serez seront
+        serais serait serions seriez seraient étais était étions étiez étaient fus fut fûmes fûtes furent sois soit
+        soyons soyez soient fusse fusses fût fussions fussiez fussent ayant eu eue eues eus ai as avons avez ont aurai
+        auras aura aurons aurez auront aurais aurait aurions auriez auraient avais avait avions aviez avaient eut
+        eûmes eûtes eurent aie aies ait ayons ayez aient eusse eusses eût eussions eussiez eussent`,
+    "es": `de la que el en y a los del se las por un para con no una su al lo como más pero sus le ya o este sí porque
+        esta entre cuando muy sin sobre también me hasta hay donde quien desde todo nos durante todos uno les ni
+        contra otros ese eso ante ellos e esto mí antes algunos qué unos yo otro otras otra él tanto esa estos mucho
+        quienes nada muchos cual poco ella estar estas algunas algo nosotros mi mis tú te ti tu tus ellas nosotras
+        vosotros vosotras os mío mía míos mías tuyo tuya tuyos tuyas suyo suya suyos suyas nuestro nuestra nuestros
+        nuestras vuestro vuestra vuestros vuestras esos esas estoy estás está estamos estáis están es son fue era ser
+        ha han he has hemos`,
+    "it": `ad al allo ai agli all agl alla alle con col coi da dal dallo dai dagli dall dagl dalla dalle di del dello dei
+        degli dell degl della delle in nel nello nei negli nell negl nella nelle su sul sullo sui sugli sull sugl sulla
+        sulle per tra contro io tu lui lei noi voi loro mio mia miei mie tuo tua tuoi tue suo sua suoi sue nostro
+        nostra nostri nostre vostro vostra vostri vostre mi ti ci vi lo la li le gli ne il un uno una ma ed se perché
+        anche come dov dove che chi cui non più quale quanto quanti quanta quante quello quelli quella quelle questo
+        questi questa queste si tutto tutti a c e i l o è sono era fu`,
+    "pt": `de a o que e do da em um para com não uma os no se na por mais as dos como mas ao ele das à seu sua ou quando
+        muito nos já eu também só pelo pela até isso ela entre depois sem mesmo aos seus quem nas me esse eles você
+        essa num nem suas meu às minha numa pelos elas qual nós lhe deles essas esses pelas este dele tu te vocês vos
+        lhes meus minhas teu tua teus tuas nosso nossa nossos nossas dela delas esta estes estas aquele aquela
+        aqueles aquelas isto aquilo é são foi era ser`,
+    "nl": `de en van ik te dat die in een hij het niet zijn is was op aan met als voor had er maar om hem dan zou of wat
+        mijn men dit zo door over ze zich bij ook tot je mij uit der daar haar naar heb hoe heeft hebben deze u want
+        nog zal me zij nu ge geen omdat iets worden toch al waren veel meer doen toen moet ben zonder kan hun dus
+        alles onder ja eens hier wie werd altijd doch wordt wezen kunnen ons zelf tegen na reeds wil kon niets uw
+        iemand geweest andere`,
+}
+
+// languageCodes maps the English names of the languages with built-in
+// stemmers or stopwords to their ISO 639-1 codes.
+var languageCodes = map[string]string{
+    "english": "en", "german": "de", "french": "fr", "spanish": "es", "italian": "it", "portuguese": "pt", "dutch": "nl",
+}
+
+// languageCode returns the ISO 639-1 code of a language given by English name
+// or code.
+func languageCode(language string) string {
+    language = strings.ToLower(language)
+    if code, ok := languageCodes[language]; ok {
+        return code
+    }
+    return language
+}
+
+// StopwordsFor returns the built-in stopwords of the given language, by
+// English name or ISO 639-1 code: English ("en"), German ("de"), French
+// ("fr"), Spanish ("es"), Italian ("it"), Portuguese ("pt") or Dutch ("nl").
+// The words are lowercase. Each call returns a new set, which the caller may
+// change.
+func StopwordsFor(language string) (Stopwords, error) {
+    list, ok := builtinStopwords[languageCode(language)]
+    if !ok {
+        return nil, errors.New("no stopwords for language " + strconv.Quote(language))
+    }
+    return NewStopwords(strings.Fields(list)...), nil
+}
+
+// ReadStopwords reads a stopword list with one or more words per line. Text
+// from a '#' or '|' to the end of a line is a comment, so both the Lucene and
+// the Snowball list formats can be read.
+func ReadStopwords(r io.Reader) (Stopwords, error) {
+    s := make(Stopwords)
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := scanner.Text()
+        if i := strings.IndexAny(line, "#|"); i >= 0 {
+            line = line[:i]
+        }
+        for _, word := range strings.Fields(line) {
+            s[word] = true
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    return s, nil
+}
+
+// LoadStopwords reads a stopword list from the named file, in the format of
+// ReadStopwords.
+func LoadStopwords(path string) (Stopwords, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return ReadStopwords(f)
+}
+
+// StopFilter returns a token filter that drops the stopwords. It should
+// follow LowercaseFilter, as the built-in lists are lowercase. Stopwords
+// dropped by the analyzer are neither indexed nor searched; to keep them in
+// the index but not score them, use WithUnscoredStopwords instead.
+func StopFilter(stopwords Stopwords) TokenFilter {
+    return func(tokens []string) []string {
+        kept := tokens[:0]
+        for _, token := range tokens {
+            if !stopwords[token] {
+                kept = append(kept, token)
+            }
+        }
+        return kept
+    }
+}
@@ -0,0 +1,137 @@
+package bm25_test
+
+import (
+    "bytes"
+    "reflect"
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+func TestAnalyzer(t *testing.T) {
+    analyzer := &bm25.Analyzer{
+        CharFilters:  []bm25.CharFilter{bm25.MappingCharFilter(map[string]string{"!": " ", "&amp;": "and", "&": "and"})},
+        Tokenizer:    bm25.WhitespaceTokenizer,
+        TokenFilters: []bm25.TokenFilter{bm25.LowercaseFilter, bm25.LengthFilter(2, 10)},
+    }
+
+    // Test case: Char filters, tokenizer and token filters are applied in order
+    tokens := analyzer.Analyze("Salt &amp; Pepper! A Supercalifragilistic treat")
+    expected := []string{"salt", "and", "pepper", "treat"}
+    if !reflect.DeepEqual(tokens, expected) {
+        t.Errorf("Expected tokens %v, but got %v", expected, tokens)
+    }
+
+    // Test case: NewAnalyzer has no char filters
+    tokens = bm25.NewAnalyzer(bm25.WhitespaceTokenizer, bm25.LowercaseFilter).Analyze("Hello WORLD!")
+    expected = []string{"hello", "world!"}
+    if !reflect.DeepEqual(tokens, expected) {
+        t.Errorf("Expected tokens %v, but got %v", expected, tokens)
+    }
+
+    // Test case: Empty text gives no tokens
+    if tokens := analyzer.Analyze("   "); len(tokens) != 0 {
+        t.Errorf("Expected no tokens, but got %v", tokens)
+    }
+}
+
+func TestWithAnalyzer(t *testing.T) {
+    analyzer := &bm25.Analyzer{
+        CharFilters:  []bm25.CharFilter{bm25.MappingCharFilter(map[string]string{"!": "", ",": "", ".": ""})},
+        Tokenizer:    bm25.WhitespaceTokenizer,
+        TokenFilters: []bm25.TokenFilter{bm25.LowercaseFilter},
+    }
+    corpus := []string{
+        "Hello there, good man!",
+        "It is quite windy in London.",
+        "How is the weather today?",
+    }
+
+    // Test case: The analyzer replaces a nil tokenizer
+    okapi, err := bm25.NewBM25Okapi(corpus, nil, 1.2, 0.75, nil, bm25.WithAnalyzer(analyzer))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if okapi.DocFreq("man") != 1 || okapi.DocFreq("london") != 1 || okapi.DocFreq("London.") != 0 {
+        t.Errorf("Expected the corpus to be analyzed with the analyzer")
+    }
+
+    // Test case: Query text is analyzed like the documents
+    if tokens := okapi.Analyze("MAN, London!"); !reflect.DeepEqual(tokens, []string{"man", "london"}) {
+        t.Errorf("Expected query terms [man london], but got %v", tokens)
+    }
+    hits, err := okapi.SearchText("Windy LONDON!", 2)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    want, _ := okapi.Search([]string{"windy", "london"}, 2)
+    if !reflect.DeepEqual(hits, want) || hits[0].DocID != 1 {
+        t.Errorf("Expected hits %v, but got %v", want, hits)
+    }
+
+    // Test case: Query text without terms
+    if _, err := okapi.SearchText(" ,. ", 2); err == nil {
+        t.Errorf("Expected an error for query text without terms, but got nil")
+    }
+
+    // Test case: Added documents are analyzed too
+    if err := okapi.AddDocuments("Good MAN!"); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if okapi.DocFreq("man") != 2 {
+        t.Errorf("Expected document frequency 2 for man, but got %d", okapi.DocFreq("man"))
+    }
+
+    // Test case: Invalid analyzers
+    if _, err := bm25.NewBM25Okapi(corpus, nil, 1.2, 0.75, nil); err == nil {
+        t.Errorf("Expected an error without a tokenizer or an analyzer, but got nil")
+    }
+    if _, err := bm25.NewBM25Okapi(corpus, strings.Fields, 1.2, 0.75, nil, bm25.WithAnalyzer(nil)); err == nil {
+        t.Errorf("Expected an error for a nil analyzer, but got nil")
+    }
+    if _, err := bm25.NewBM25Okapi(corpus, strings.Fields, 1.2, 0.75, nil, bm25.WithAnalyzer(&bm25.Analyzer{})); err == nil {
+        t.Errorf("Expected an error for an analyzer without a tokenizer, but got nil")
+    }
+    if _, err := bm25.NewBM25Okapi(corpus, strings.Fields, 1.2, 0.75, nil, bm25.WithAnalyzer(bm25.NewAnalyzer(strings.Fields, nil))); err == nil {
+        t.Errorf("Expected an error for a nil token filter, but got nil")
+    }
+
+    // Test case: Loaded and memory-mapped indexes search text with the
+    // analyzer given again
+    var buf bytes.Buffer
+    if err := okapi.Save(&buf); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    loaded, err := bm25.Load(&buf, nil, nil, bm25.WithAnalyzer(analyzer))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    path := saveMapped(t, okapi)
+    mapped, err := bm25.OpenMapped(path, nil, bm25.WithAnalyzer(analyzer))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    defer mapped.Close()
+    want, _ = okapi.SearchText("good man", 3)
+    for name, scorer := range map[string]bm25.BM25{"loaded": loaded, "mapped": mapped} {
+        hits, err := scorer.SearchText("Good man!", 3)
+        if err != nil {
+            t.Fatalf("%s: unexpected error: %v", name, err)
+        }
+        if !reflect.DeepEqual(hits, want) {
+            t.Errorf("%s: expected hits %v, but got %v", name, want, hits)
+        }
+    }
+
+    // Test case: A memory-mapped index opened without an analyzer cannot
+    // search text
+    plain, err := bm25.OpenMapped(path, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    defer plain.Close()
+    if _, err := plain.SearchText("good man", 3); err == nil {
+        t.Errorf("Expected an error without an analyzer, but got nil")
+    }
+}
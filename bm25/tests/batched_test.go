@@ -1,6 +1,7 @@
 package bm25_test
 
 import (
+    "strings"
     "testing"
 
     "lenaxia/bm25_golang/bm25"
@@ -9,7 +10,7 @@ import (
 func TestGetScoresBatched(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting scores in batches for an empty query
     _, err := bm25.GetScoresBatched([]string{}, bm25, 1)
@@ -28,7 +29,7 @@ func TestGetScoresBatched(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{0.6931471805599453, 0.0}
+    expected := []float64{0.3648143055578659, 0.0}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -43,7 +44,7 @@ func TestGetScoresBatched(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{0.0, 1.3862943611198906}
+    expected = []float64{0.0, 0.5545177444479562}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -57,7 +58,7 @@ func TestGetScoresBatched(t *testing.T) {
 func TestGetBatchScoresBatched(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting batch scores in batches for an empty query
     _, err := bm25.GetBatchScoresBatched([]string{}, []int{0, 1}, bm25, 1)
@@ -88,7 +89,7 @@ func TestGetBatchScoresBatched(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{0.6931471805599453}
+    expected := []float64{0.3648143055578659}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -103,7 +104,7 @@ func TestGetBatchScoresBatched(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{1.3862943611198906}
+    expected = []float64{0.5545177444479562}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -117,7 +118,7 @@ func TestGetBatchScoresBatched(t *testing.T) {
 func TestGetTopNBatched(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting top N documents in batches for an empty query
     _, err := bm25.GetTopNBatched([]string{}, 2, bm25, 1)
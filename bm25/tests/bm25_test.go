@@ -1,6 +1,8 @@
 package bm25_test
 
 import (
+    "math/rand"
+    "strings"
     "testing"
 
     "lenaxia/bm25_golang/bm25"
@@ -71,7 +73,7 @@ func TestDocLengths(t *testing.T) {
 func TestIDF(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    base, _ := bm25.NewBM25Base(corpus, tokenizer, nil)
+    base, _ := bm25.NewBM25Base(corpus, tokenizer, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Calculating IDF for an empty term
     _, err := base.IDF("")
@@ -88,13 +90,13 @@ func TestIDF(t *testing.T) {
         t.Errorf("Expected IDF 0.0 for a term not present in the corpus, but got %.2f", idf)
     }
 
-    // Test case: Calculating IDF for a term present in all documents
+    // Test case: Calculating IDF for a term present in one of the two documents
     idf, err = base.IDF("is")
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    if idf != 0.0 {
-        t.Errorf("Expected IDF 0.0 for a term present in all documents, but got %.2f", idf)
+    if idf != 0.6931471805599453 {
+        t.Errorf("Expected IDF 0.6931471805599453 for the term 'is', but got %.2f", idf)
     }
 
     // Test case: Calculating IDF for a term present in some documents
@@ -106,3 +108,101 @@ func TestIDF(t *testing.T) {
         t.Errorf("Expected IDF 0.69314718055994529 for the term 'hello', but got %.2f", idf)
     }
 }
+
+func TestDocFreqAndCollectionFreq(t *testing.T) {
+    corpus := []string{"spam spam spam spam spam", "eggs ham", "eggs toast", "bacon beans", "tea"}
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+    base, _ := bm25.NewBM25Base(corpus, tokenizer, nil)
+
+    // Test case: A term repeated within one document has a document frequency of 1
+    if df := base.DocFreq("spam"); df != 1 {
+        t.Errorf("Expected document frequency 1 for 'spam', but got %d", df)
+    }
+    if cf := base.CollectionFreq("spam"); cf != 5 {
+        t.Errorf("Expected collection frequency 5 for 'spam', but got %d", cf)
+    }
+
+    // Test case: A term found once in each of two documents
+    if df := base.DocFreq("eggs"); df != 2 {
+        t.Errorf("Expected document frequency 2 for 'eggs', but got %d", df)
+    }
+    if cf := base.CollectionFreq("eggs"); cf != 2 {
+        t.Errorf("Expected collection frequency 2 for 'eggs', but got %d", cf)
+    }
+
+    // Test case: A term not present in the corpus
+    if df, cf := base.DocFreq("nonexistent"), base.CollectionFreq("nonexistent"); df != 0 || cf != 0 {
+        t.Errorf("Expected zero frequencies for a missing term, but got df=%d cf=%d", df, cf)
+    }
+
+    // Test case: IDF depends on the document frequency, not on repetitions
+    spamIDF, _ := base.IDF("spam")
+    teaIDF, _ := base.IDF("tea")
+    if spamIDF != teaIDF {
+        t.Errorf("Expected equal IDF for 'spam' and 'tea', but got %.4f and %.4f", spamIDF, teaIDF)
+    }
+}
+
+func TestAddDocuments(t *testing.T) {
+    rng := rand.New(rand.NewSource(11))
+    corpus := randomCorpus(rng, 600)
+    full := newVariants(t, corpus)
+    incremental := newVariants(t, corpus[:200])
+
+    // Test case: A document that tokenizes to an empty slice is rejected and
+    // nothing is added
+    for name, scorer := range incremental {
+        if err := scorer.AddDocuments(corpus[200], ""); err == nil {
+            t.Errorf("%s: expected an error for an empty document, but got nil", name)
+        }
+        if scorer.CorpusSize() != 200 {
+            t.Errorf("%s: expected corpus size 200, but got %d", name, scorer.CorpusSize())
+        }
+    }
+
+    // Test case: Adding documents in batches gives the same index as building
+    // it from the whole corpus
+    for name, scorer := range incremental {
+        // Query before adding so that the caches hold stale values.
+        if _, err := scorer.Search([]string{"t1", "t3", "t20"}, 10); err != nil {
+            t.Fatalf("%s: unexpected error: %v", name, err)
+        }
+        if err := scorer.AddDocuments(corpus[200:450]...); err != nil {
+            t.Fatalf("%s: unexpected error: %v", name, err)
+        }
+        if err := scorer.AddDocuments(corpus[450:]...); err != nil {
+            t.Fatalf("%s: unexpected error: %v", name, err)
+        }
+
+        expected := full[name]
+        if scorer.CorpusSize() != expected.CorpusSize() || scorer.AvgDocLen() != expected.AvgDocLen() {
+            t.Errorf("%s: expected corpus size %d and average length %.4f, but got %d and %.4f", name, expected.CorpusSize(), expected.AvgDocLen(), scorer.CorpusSize(), scorer.AvgDocLen())
+        }
+        if scorer.DocFreq("t3") != expected.DocFreq("t3") {
+            t.Errorf("%s: expected document frequency %d, but got %d", name, expected.DocFreq("t3"), scorer.DocFreq("t3"))
+        }
+
+        for _, query := range [][]string{{"t1", "t3", "t20"}, {"t7"}, {"t2", "t2", "t400"}} {
+            expectedScores, _ := expected.GetScores(query)
+            scores, _ := scorer.GetScores(query)
+            for i := range expectedScores {
+                if scores[i] != expectedScores[i] {
+                    t.Errorf("%s %v: expected score %v at index %d, but got %v", name, query, expectedScores[i], i, scores[i])
+                }
+            }
+            checkTopN(t, name, scorer, corpus, query, 10)
+        }
+    }
+
+    // Test case: Added documents get an empty external ID
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+    okapi, _ := bm25.NewBM25Okapi([]string{"hello world"}, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF), bm25.WithExternalIDs([]string{"a"}))
+    okapi.AddDocuments("hello there")
+    if id, err := okapi.ExternalID(1); err != nil || id != "" {
+        t.Errorf("Expected an empty external ID, but got '%s' (%v)", id, err)
+    }
+    hits, _ := okapi.Search([]string{"there"}, 1)
+    if len(hits) != 1 || hits[0].DocID != 1 {
+        t.Errorf("Expected a hit for the added document, but got %+v", hits)
+    }
+}
@@ -1,6 +1,12 @@
 package bm25_test
 
 import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "math"
+    "strings"
     "testing"
 
     "lenaxia/bm25_golang/bm25"
@@ -38,7 +44,7 @@ func TestNewBM25Adpt(t *testing.T) {
 func TestBM25AdptGetScores(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Adpt(corpus, tokenizer, 1.2, 0.75, 1.0, nil)
+    bm25, _ := bm25.NewBM25Adpt(corpus, tokenizer, 1.2, 0.75, 0.0, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting scores for an empty query
     _, err := bm25.GetScores([]string{})
@@ -51,7 +57,7 @@ func TestBM25AdptGetScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{0.9672957210280396, 0.0}
+    expected := []float64{0.8025914722273051, 0.0}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -66,7 +72,7 @@ func TestBM25AdptGetScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{0.0, 1.6931471805599454}
+    expected = []float64{0.0, 1.2199390377855037}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -80,7 +86,7 @@ func TestBM25AdptGetScores(t *testing.T) {
 func TestBM25AdptGetBatchScores(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Adpt(corpus, tokenizer, 1.2, 0.75, 1.0, nil)
+    bm25, _ := bm25.NewBM25Adpt(corpus, tokenizer, 1.2, 0.75, 0.0, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting batch scores for an empty query
     _, err := bm25.GetBatchScores([]string{}, []int{0, 1})
@@ -105,7 +111,7 @@ func TestBM25AdptGetBatchScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{0.9672957210280396}
+    expected := []float64{0.8025914722273051}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -120,7 +126,7 @@ func TestBM25AdptGetBatchScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{1.6931471805599454}
+    expected = []float64{1.2199390377855037}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -134,7 +140,7 @@ func TestBM25AdptGetBatchScores(t *testing.T) {
 func TestBM25AdptGetTopN(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Adpt(corpus, tokenizer, 1.2, 0.75, 1.0, nil)
+    bm25, _ := bm25.NewBM25Adpt(corpus, tokenizer, 1.2, 0.75, 0.0, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting top N documents for an empty query
     _, err := bm25.GetTopN([]string{}, 2)
@@ -178,3 +184,157 @@ func TestBM25AdptGetTopN(t *testing.T) {
         }
     }
 }
+
+// adptCorpus builds 200 documents of 10 tokens each, so that the normalised
+// term frequency equals the raw frequency. The term "x" occurs once in 20
+// documents, twice in 4, three times in 3 and four times in 3.
+func adptCorpus() []string {
+    var freqs []int
+    for tf, count := range []int{1: 20, 2: 4, 3: 3, 4: 3} {
+        for i := 0; i < count; i++ {
+            freqs = append(freqs, tf)
+        }
+    }
+    corpus := make([]string, 200)
+    for i := range corpus {
+        var tokens []string
+        if i < len(freqs) {
+            for j := 0; j < freqs[i]; j++ {
+                tokens = append(tokens, "x")
+            }
+        }
+        for len(tokens) < 10 {
+            tokens = append(tokens, fmt.Sprintf("f%d_%d", i, len(tokens)))
+        }
+        corpus[i] = strings.Join(tokens, " ")
+    }
+    return corpus
+}
+
+func TestBM25AdptTermParameters(t *testing.T) {
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+    adpt, _ := bm25.NewBM25Adpt(adptCorpus(), tokenizer, 1.2, 0.75, 0.0, nil)
+
+    // Test case: The IDF of a term is its first information gain G¹
+    background := math.Log2(30.5 / 201)
+    g1 := math.Log2(10.5/31) - background
+    idf, err := adpt.IDF("x")
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if math.Abs(idf-g1) > 1e-12 {
+        t.Errorf("Expected IDF %.4f, but got %.4f", g1, idf)
+    }
+
+    // Test case: The k1 of the term is fitted to its gain curve
+    k1, fitted := adpt.TermK1("x")
+    if !fitted || k1 < 0.1 || k1 > 10 {
+        t.Errorf("Expected a fitted k1 between 0.1 and 10 for 'x', but got %.4f (fitted: %v)", k1, fitted)
+    }
+    gains := []float64{g1, math.Log2(6.5/11) - background, math.Log2(3.5/7) - background}
+    loss := func(k float64) float64 {
+        var sum float64
+        for i, g := range gains {
+            r := float64(i + 1)
+            d := g/g1 - (k+1)*r/(k+r)
+            sum += d * d
+        }
+        return sum
+    }
+    if loss(k1) > loss(k1*1.01) || loss(k1) > loss(k1*0.99) {
+        t.Errorf("Expected k1 %.4f to minimise the fitting loss", k1)
+    }
+
+    // Test case: Terms without enough repetitions fall back to the configured parameters
+    k1, fitted = adpt.TermK1("f0_1")
+    if fitted || k1 != 1.2 {
+        t.Errorf("Expected the configured k1 1.2 for 'f0_1', but got %.4f (fitted: %v)", k1, fitted)
+    }
+
+    // Test case: Scores use the term-specific parameters
+    k1, _ = adpt.TermK1("x")
+    scores, _ := adpt.GetScores([]string{"x"})
+    for i, tf := range []float64{1, 2, 3, 4} {
+        docID := []int{0, 20, 24, 27}[i]
+        expected := g1 * (k1 + 1) * tf / (k1 + tf)
+        if math.Abs(scores[docID]-expected) > 1e-12 {
+            t.Errorf("Expected score %.4f at index %d, but got %.4f", expected, docID, scores[docID])
+        }
+    }
+}
+
+func TestBM25AdptDelta(t *testing.T) {
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+    adpt, _ := bm25.NewBM25Adpt(adptCorpus(), tokenizer, 1.2, 0.75, 0.0, nil)
+    withDelta, _ := bm25.NewBM25Adpt(adptCorpus(), tokenizer, 1.2, 0.75, 0.5, nil)
+
+    // Test case: The delta adds idf * delta to every document for each query term
+    query := []string{"x", "f0_1"}
+    var floor float64
+    for _, term := range query {
+        idf, _ := adpt.IDF(term)
+        floor += idf * 0.5
+    }
+    scores, _ := adpt.GetScores(query)
+    scoresWithDelta, _ := withDelta.GetScores(query)
+    for i := range scores {
+        if math.Abs(scoresWithDelta[i]-scores[i]-floor) > 1e-12 {
+            t.Errorf("Expected score %.4f at index %d, but got %.4f", scores[i]+floor, i, scoresWithDelta[i])
+        }
+    }
+}
+
+func TestBM25AdptSaveLoad(t *testing.T) {
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+    adpt, _ := bm25.NewBM25Adpt(adptCorpus(), tokenizer, 1.2, 0.75, 0.5, nil)
+    var buf bytes.Buffer
+    if err := adpt.Save(&buf); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    saved := buf.Bytes()
+
+    // Test case: The fitted parameters and the delta are restored
+    loaded, err := bm25.Load(bytes.NewReader(saved), tokenizer, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    loadedAdpt, ok := loaded.(*bm25.BM25Adpt)
+    if !ok {
+        t.Fatalf("Expected a *BM25Adpt, but got %T", loaded)
+    }
+    for _, term := range []string{"x", "f0_1"} {
+        k1, fitted := adpt.TermK1(term)
+        loadedK1, loadedFitted := loadedAdpt.TermK1(term)
+        idf, _ := adpt.IDF(term)
+        loadedIDF, _ := loadedAdpt.IDF(term)
+        if loadedK1 != k1 || loadedFitted != fitted || loadedIDF != idf {
+            t.Errorf("Expected k1 %v (fitted: %v) and IDF %v for '%s', but got %v (fitted: %v) and %v", k1, fitted, idf, term, loadedK1, loadedFitted, loadedIDF)
+        }
+    }
+    expected, _ := adpt.GetScores([]string{"x", "f0_1"})
+    scores, _ := loadedAdpt.GetScores([]string{"x", "f0_1"})
+    for i := range expected {
+        if scores[i] != expected[i] {
+            t.Errorf("Expected score %v at index %d, but got %v", expected[i], i, scores[i])
+        }
+    }
+
+    // Test case: The saved parameters are used as they are rather than
+    // fitted again. "x" is the last term of the vocabulary, so its k1 and IDF
+    // end the payload.
+    payload := append([]byte(nil), saved[20:len(saved)-4]...)
+    binary.LittleEndian.PutUint64(payload[len(payload)-16:], math.Float64bits(2.5))
+    loaded, err = bm25.Load(bytes.NewReader(resealed(saved, payload)), tokenizer, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if k1, fitted := loaded.(*bm25.BM25Adpt).TermK1("x"); k1 != 2.5 || !fitted {
+        t.Errorf("Expected the saved k1 2.5 for 'x', but got %v (fitted: %v)", k1, fitted)
+    }
+
+    // Test case: A fitted k1 that is not positive is reported as corrupt
+    binary.LittleEndian.PutUint64(payload[len(payload)-16:], math.Float64bits(-1))
+    if _, err := bm25.Load(bytes.NewReader(resealed(saved, payload)), tokenizer, nil); !errors.Is(err, bm25.ErrCorruptIndex) {
+        t.Errorf("Expected ErrCorruptIndex, but got %v", err)
+    }
+}
@@ -1,6 +1,8 @@
 package bm25_test
 
 import (
+    "math"
+    "strings"
     "testing"
 
     "lenaxia/bm25_golang/bm25"
@@ -11,19 +13,25 @@ func TestNewBM25L(t *testing.T) {
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
 
     // Test case: Creating a new BM25L instance with negative k1
-    _, err := bm25.NewBM25L(corpus, tokenizer, -1.0, 0.75, nil)
+    _, err := bm25.NewBM25L(corpus, tokenizer, -1.0, 0.75, 0.5, nil)
     if err == nil {
         t.Errorf("Expected an error for negative k1, but got nil")
     }
 
     // Test case: Creating a new BM25L instance with b outside the range [0, 1]
-    _, err = bm25.NewBM25L(corpus, tokenizer, 1.2, 1.5, nil)
+    _, err = bm25.NewBM25L(corpus, tokenizer, 1.2, 1.5, 0.5, nil)
     if err == nil {
         t.Errorf("Expected an error for b outside the range [0, 1], but got nil")
     }
 
+    // Test case: Creating a new BM25L instance with negative delta
+    _, err = bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, -1.0, nil)
+    if err == nil {
+        t.Errorf("Expected an error for negative delta, but got nil")
+    }
+
     // Test case: Creating a new BM25L instance with valid inputs
-    _, err = bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, nil)
+    _, err = bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, 0.5, nil)
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
@@ -32,7 +40,7 @@ func TestNewBM25L(t *testing.T) {
 func TestBM25LGetScores(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, 0.5, nil, bm25.WithIDF(bm25.SmoothedIDF))
 
     // Test case: Getting scores for an empty query
     _, err := bm25.GetScores([]string{})
@@ -45,7 +53,7 @@ func TestBM25LGetScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{0.8109631974066755, 0.0}
+    expected := []float64{0.9216572400852019, 0.0}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -60,7 +68,7 @@ func TestBM25LGetScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{0.0, 1.3862943611198906}
+    expected = []float64{0.0, 1.5859207491211549}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -74,7 +82,7 @@ func TestBM25LGetScores(t *testing.T) {
 func TestBM25LGetBatchScores(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, 0.5, nil, bm25.WithIDF(bm25.SmoothedIDF))
 
     // Test case: Getting batch scores for an empty query
     _, err := bm25.GetBatchScores([]string{}, []int{0, 1})
@@ -99,7 +107,7 @@ func TestBM25LGetBatchScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{0.8109631974066755}
+    expected := []float64{0.9216572400852019}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -114,7 +122,7 @@ func TestBM25LGetBatchScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{1.3862943611198906}
+    expected = []float64{1.5859207491211549}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -128,7 +136,7 @@ func TestBM25LGetBatchScores(t *testing.T) {
 func TestBM25LGetTopN(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, 0.5, nil, bm25.WithIDF(bm25.SmoothedIDF))
 
     // Test case: Getting top N documents for an empty query
     _, err := bm25.GetTopN([]string{}, 2)
@@ -172,3 +180,28 @@ func TestBM25LGetTopN(t *testing.T) {
         }
     }
 }
+
+func TestBM25LLengthNormalisation(t *testing.T) {
+    corpus := []string{"apple pie", "apple " + strings.Repeat("filler ", 38) + "end", "cherry tart", "lemon cake"}
+    tokenizer := func(s string) []string { return strings.Fields(s) }
+    l, _ := bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, bm25.DefaultBM25LDelta, nil, bm25.WithIDF(bm25.SmoothedIDF))
+
+    // Test case: Scores follow the published BM25L formula
+    scores, err := l.GetScores([]string{"apple"})
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    idf := bm25.SmoothedIDF(2, 4)
+    for i, docLen := range l.DocLengths()[:2] {
+        ctd := 1 / (1 - 0.75 + 0.75*float64(docLen)/l.AvgDocLen())
+        expected := idf * 2.2 * (ctd + 0.5) / (1.2 + ctd + 0.5)
+        if math.Abs(scores[i]-expected) > 1e-12 {
+            t.Errorf("Expected score %.4f at index %d, but got %.4f", expected, i, scores[i])
+        }
+    }
+
+    // Test case: The delta shift keeps a long matching document well above zero
+    if scores[1] < 0.5*scores[0] {
+        t.Errorf("Expected the long document to keep at least half the short document's score, but got %.4f vs %.4f", scores[1], scores[0])
+    }
+}
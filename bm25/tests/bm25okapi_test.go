@@ -1,6 +1,8 @@
 package bm25_test
 
 import (
+    "math"
+    "strings"
     "testing"
 
     "lenaxia/bm25_golang/bm25"
@@ -32,7 +34,7 @@ func TestNewBM25Okapi(t *testing.T) {
 func TestBM25OkapiGetScores(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting scores for an empty query
     _, err := bm25.GetScores([]string{})
@@ -45,7 +47,7 @@ func TestBM25OkapiGetScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{0.6931471805599453, 0.0}
+    expected := []float64{0.3648143055578659, 0.0}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -60,7 +62,7 @@ func TestBM25OkapiGetScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{0.0, 1.3862943611198906}
+    expected = []float64{0.0, 0.5545177444479562}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -74,7 +76,7 @@ func TestBM25OkapiGetScores(t *testing.T) {
 func TestBM25OkapiGetBatchScores(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting batch scores for an empty query
     _, err := bm25.GetBatchScores([]string{}, []int{0, 1})
@@ -99,7 +101,7 @@ func TestBM25OkapiGetBatchScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{0.6931471805599453}
+    expected := []float64{0.3648143055578659}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -114,7 +116,7 @@ func TestBM25OkapiGetBatchScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{1.3862943611198906}
+    expected = []float64{0.5545177444479562}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -128,7 +130,7 @@ func TestBM25OkapiGetBatchScores(t *testing.T) {
 func TestBM25OkapiGetTopN(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting top N documents for an empty query
     _, err := bm25.GetTopN([]string{}, 2)
@@ -172,3 +174,30 @@ func TestBM25OkapiGetTopN(t *testing.T) {
         }
     }
 }
+
+func TestBM25OkapiScoresOnlyMatchingDocuments(t *testing.T) {
+    corpus := []string{"apple banana", "cherry date", "elder fig", "grape kiwi", "lemon mango"}
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+
+    // Test case: Only the document containing the term receives a score
+    scores, err := bm25.GetScores([]string{"apple", "missing"})
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    expected := []float64{math.Log(3) * (1 / (1 + 1.2)), 0.0, 0.0, 0.0, 0.0}
+    for i, score := range scores {
+        if math.Abs(score-expected[i]) > 1e-12 {
+            t.Errorf("Expected score %.4f at index %d, but got %.4f", expected[i], i, score)
+        }
+    }
+
+    // Test case: Batch scores agree with the full scores
+    batch, err := bm25.GetBatchScores([]string{"apple", "missing"}, []int{3, 0})
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if batch[0] != scores[3] || batch[1] != scores[0] {
+        t.Errorf("Expected batch scores %v, but got %v", []float64{scores[3], scores[0]}, batch)
+    }
+}
@@ -1,6 +1,9 @@
 package bm25_test
 
 import (
+    "math"
+    "math/rand"
+    "strings"
     "testing"
 
     "lenaxia/bm25_golang/bm25"
@@ -52,12 +55,13 @@ func TestBM25PlusGetScores(t *testing.T) {
         t.Errorf("Expected an error for an empty query, but got nil")
     }
 
-    // Test case: Getting scores for a single-term query
+    // Test case: Getting scores for a single-term query, where documents
+    // without the term still get idf * delta
     scores, err := bm25.GetScores([]string{"hello"})
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{1.4672957210280396, 0.5}
+    expected := []float64{2.3706896755469735, 1.0986122886681096}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -72,7 +76,7 @@ func TestBM25PlusGetScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{0.5, 2.1931471805599454}
+    expected = []float64{2.197224577336219, 4.130782205392093}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -111,7 +115,7 @@ func TestBM25PlusGetBatchScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{1.4672957210280396}
+    expected := []float64{2.3706896755469735}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -126,7 +130,7 @@ func TestBM25PlusGetBatchScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{2.1931471805599454}
+    expected = []float64{4.130782205392093}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -184,3 +188,115 @@ func TestBM25PlusGetTopN(t *testing.T) {
         }
     }
 }
+
+// bm25PlusScores scores every document of the corpus by brute force with the
+// BM25Plus formula of rank_bm25, giving idf * delta to the documents without
+// a query term as well.
+func bm25PlusScores(scorer bm25.BM25, corpus []string, query []string, k1, b, delta float64) []float64 {
+    scores := make([]float64, len(corpus))
+    for _, q := range query {
+        idf, _ := scorer.IDF(q)
+        for i, doc := range corpus {
+            tokens := strings.Fields(doc)
+            tf := 0.0
+            for _, token := range tokens {
+                if token == q {
+                    tf++
+                }
+            }
+            k := k1 * (1 - b + b*float64(len(tokens))/scorer.AvgDocLen())
+            scores[i] += idf * (delta + tf*(k1+1)/(tf+k))
+        }
+    }
+    return scores
+}
+
+func TestBM25PlusDelta(t *testing.T) {
+    rng := rand.New(rand.NewSource(3))
+    corpus := randomCorpus(rng, 300)
+    plus, err := bm25.NewBM25Plus(corpus, strings.Fields, 1.2, 0.75, 1.0, 0.25, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    docIDs := []int{0, 5, 17, 42, 299}
+    docIndex := make(map[string]int, len(corpus))
+    for i, doc := range corpus {
+        docIndex[doc] = i
+    }
+
+    for q := 0; q < 20; q++ {
+        query := randomQuery(rng)
+        expected := bm25PlusScores(plus, corpus, query, 1.2, 0.75, 1.0)
+
+        // Test case: Every scoring path gives idf * delta to the documents
+        // that lack a query term, as the original BM25Plus scoring did
+        scores, err := plus.GetScores(query)
+        if err != nil {
+            t.Fatalf("Unexpected error: %v", err)
+        }
+        parallel, _ := plus.GetScoresParallel(query, plus)
+        batched, _ := plus.GetScoresBatched(query, plus, 64)
+        for i := range expected {
+            if math.Abs(scores[i]-expected[i]) > 1e-9 || parallel[i] != scores[i] || batched[i] != scores[i] {
+                t.Fatalf("%v: expected score %v for document %d, but got %v, %v and %v", query, expected[i], i, scores[i], parallel[i], batched[i])
+            }
+        }
+        batch, _ := plus.GetBatchScores(query, docIDs)
+        for i, docID := range docIDs {
+            if batch[i] != scores[docID] {
+                t.Errorf("%v: expected batch score %v for document %d, but got %v", query, scores[docID], docID, batch[i])
+            }
+        }
+
+        // Test case: The delta does not change the ranking. Documents are
+        // compared by score, as rounding may order near ties differently.
+        indices, _ := bm25.TopNIndices(expected, 10)
+        topDocs, _ := plus.GetTopN(query, 10)
+        for i, idx := range indices {
+            if score := expected[docIndex[topDocs[i]]]; math.Abs(score-expected[idx]) > 1e-9 {
+                t.Errorf("%v: expected score %v at rank %d, but got %v", query, expected[idx], i, score)
+            }
+        }
+    }
+}
+
+func TestBM25PlusMatchesRankBM25(t *testing.T) {
+    corpus := []string{
+        "the cat sat on the mat",
+        "the dog chased the cat around the garden",
+        "a cat and a dog",
+        "birds sing in the garden every morning",
+        "the quick brown fox jumps over the lazy dog",
+    }
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+
+    // Scores of rank_bm25's BM25Plus with its defaults k1 = 1.5, b = 0.75 and
+    // delta = 1.
+    testCases := []struct {
+        query    string
+        expected []float64
+    }{
+        {"cat", []float64{1.433915159784314, 1.3444264106162696, 1.4885619779238166, 0.6931471805599452, 0.6931471805599452}},
+        {"the garden", []float64{2.1111909276334186, 3.1888031416743594, 1.504077396776274, 3.008154793552548, 2.0345924914972366}},
+        {"dog cat fox", []float64{3.918821809572314, 4.480612290460594, 4.768883425075688, 3.1780538303479453, 5.379869849147439}},
+        {"unknown cat", []float64{1.433915159784314, 1.3444264106162696, 1.4885619779238166, 0.6931471805599452, 0.6931471805599452}},
+    }
+
+    plus, err := bm25.NewBM25Plus(corpus, tokenizer, 1.5, 0.75, 1.0, 0.25, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    // Test case: GetScores matches the Python reference implementation
+    for _, tc := range testCases {
+        scores, err := plus.GetScores(tokenizer(tc.query))
+        if err != nil {
+            t.Fatalf("Unexpected error: %v", err)
+        }
+        for i, score := range scores {
+            if math.Abs(score-tc.expected[i]) > 1e-9 {
+                t.Errorf("%q: expected score %v at index %d, but got %v", tc.query, tc.expected[i], i, score)
+            }
+        }
+    }
+}
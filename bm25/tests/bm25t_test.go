@@ -1,6 +1,8 @@
 package bm25_test
 
 import (
+    "math"
+    "strings"
     "testing"
 
     "lenaxia/bm25_golang/bm25"
@@ -11,25 +13,19 @@ func TestNewBM25T(t *testing.T) {
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
 
     // Test case: Creating a new BM25T instance with negative k1
-    _, err := bm25.NewBM25T(corpus, tokenizer, -1.0, 0.75, 1.0, nil)
+    _, err := bm25.NewBM25T(corpus, tokenizer, -1.0, 0.75, nil)
     if err == nil {
         t.Errorf("Expected an error for negative k1, but got nil")
     }
 
     // Test case: Creating a new BM25T instance with b outside the range [0, 1]
-    _, err = bm25.NewBM25T(corpus, tokenizer, 1.2, 1.5, 1.0, nil)
+    _, err = bm25.NewBM25T(corpus, tokenizer, 1.2, 1.5, nil)
     if err == nil {
         t.Errorf("Expected an error for b outside the range [0, 1], but got nil")
     }
 
-    // Test case: Creating a new BM25T instance with negative delta
-    _, err = bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, -1.0, nil)
-    if err == nil {
-        t.Errorf("Expected an error for negative delta, but got nil")
-    }
-
     // Test case: Creating a new BM25T instance with valid inputs
-    _, err = bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, 1.0, nil)
+    _, err = bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, nil)
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
@@ -38,7 +34,7 @@ func TestNewBM25T(t *testing.T) {
 func TestBM25TGetScores(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, 1.0, nil)
+    bm25, _ := bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting scores for an empty query
     _, err := bm25.GetScores([]string{})
@@ -51,7 +47,7 @@ func TestBM25TGetScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{1.9672957210280396, 1.0}
+    expected := []float64{0.7744682137606258, 0.0}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -66,7 +62,7 @@ func TestBM25TGetScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{1.0, 2.6931471805599454}
+    expected = []float64{0.0, 1.297932874868362}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -80,7 +76,7 @@ func TestBM25TGetScores(t *testing.T) {
 func TestBM25TGetBatchScores(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, 1.0, nil)
+    bm25, _ := bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting batch scores for an empty query
     _, err := bm25.GetBatchScores([]string{}, []int{0, 1})
@@ -105,7 +101,7 @@ func TestBM25TGetBatchScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{1.9672957210280396}
+    expected := []float64{0.7744682137606258}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -120,7 +116,7 @@ func TestBM25TGetBatchScores(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{2.6931471805599454}
+    expected = []float64{1.297932874868362}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -134,7 +130,7 @@ func TestBM25TGetBatchScores(t *testing.T) {
 func TestBM25TGetTopN(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, 1.0, nil)
+    bm25, _ := bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting top N documents for an empty query
     _, err := bm25.GetTopN([]string{}, 2)
@@ -178,3 +174,47 @@ func TestBM25TGetTopN(t *testing.T) {
         }
     }
 }
+
+func TestBM25TTermK1(t *testing.T) {
+    corpus := []string{"apple apple pie", "apple tart with cream on top", "cherry pie", "lemon cake slice"}
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+    bm25t, _ := bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
+
+    // Test case: The solved k1 satisfies the log-logistic elite-set condition
+    k1, solved := bm25t.TermK1("apple")
+    if !solved {
+        t.Errorf("Expected a solved k1 for 'apple'")
+    }
+    docLengths := bm25t.DocLengths()
+    norm := func(docLen int) float64 { return 1 - 0.75 + 0.75*float64(docLen)/bm25t.AvgDocLen() }
+    target := (math.Log(1+2/norm(docLengths[0])) + math.Log(1+1/norm(docLengths[1]))) / 2
+    if g := k1 * math.Log(k1) / (k1 - 1); math.Abs(g-target) > 1e-9 {
+        t.Errorf("Expected g(k1) %.6f, but got %.6f", target, g)
+    }
+
+    // Test case: Unknown terms report the configured k1
+    k1, solved = bm25t.TermK1("nonexistent")
+    if solved || k1 != 1.2 {
+        t.Errorf("Expected the configured k1 1.2 for an unknown term, but got %.4f (solved: %v)", k1, solved)
+    }
+
+    // Test case: Scores use the term-specific k1
+    k1, _ = bm25t.TermK1("apple")
+    idf, _ := bm25t.IDF("apple")
+    scores, _ := bm25t.GetScores([]string{"apple"})
+    for docID, tf := range []float64{2, 1} {
+        expected := idf * (k1 + 1) * tf / (k1*norm(docLengths[docID]) + tf)
+        if math.Abs(scores[docID]-expected) > 1e-12 {
+            t.Errorf("Expected score %.4f at index %d, but got %.4f", expected, docID, scores[docID])
+        }
+    }
+
+    // Test case: The parallel and batched paths agree with GetScores
+    parallel, _ := bm25t.GetScoresParallel([]string{"apple"}, bm25t)
+    batched, _ := bm25t.GetScoresBatched([]string{"apple"}, bm25t, 3)
+    for i := range scores {
+        if parallel[i] != scores[i] || batched[i] != scores[i] {
+            t.Errorf("Expected score %.4f at index %d, but got %.4f (parallel) and %.4f (batched)", scores[i], i, parallel[i], batched[i])
+        }
+    }
+}
@@ -0,0 +1,127 @@
+package bm25_test
+
+import (
+    "fmt"
+    "math/rand"
+    "reflect"
+    "sync"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+// parallelScorer is implemented by every variant through its base.
+type parallelScorer interface {
+    GetScoresParallel(query []string, bm25 bm25.BM25) ([]float64, error)
+    GetBatchScoresParallel(query []string, docIDs []int, bm25 bm25.BM25) ([]float64, error)
+    GetTopNParallel(query []string, n int, bm25 bm25.BM25) ([]string, error)
+    GetScoresBatched(query []string, bm25 bm25.BM25, batchSize int) ([]float64, error)
+    GetBatchScoresBatched(query []string, docIDs []int, bm25 bm25.BM25, batchSize int) ([]float64, error)
+    GetTopNBatched(query []string, n int, bm25 bm25.BM25, batchSize int) ([]string, error)
+}
+
+// TestConcurrentQueries runs every query entry point from many goroutines at
+// once. Run it with -race: the lazily computed score bounds and term
+// parameters are filled in by the concurrent queries themselves.
+func TestConcurrentQueries(t *testing.T) {
+    rng := rand.New(rand.NewSource(23))
+    corpus := randomCorpus(rng, 400)
+    queries := make([][]string, 8)
+    for i := range queries {
+        queries[i] = append(randomQuery(rng), "t1", "t2")
+    }
+    docIDs := []int{0, 3, 17, 99, 150, 151, 399, 17}
+
+    for variant, scorer := range newVariants(t, corpus, bm25.WithMaxBufferedDocs(100), bm25.WithEpsilon(0.25)) {
+        // Adding documents drops every cached bound and term parameter, so
+        // the queries below compute them concurrently.
+        if err := scorer.AddDocuments("t1 t2 t3", "t1 t1 t4"); err != nil {
+            t.Fatalf("%s: unexpected error: %v", variant, err)
+        }
+        ps := scorer.(parallelScorer)
+
+        type result struct {
+            name   string
+            query  int
+            scores []float64
+            docs   []string
+            hits   []bm25.Hit
+        }
+        results := make(chan result, 1000)
+        errs := make(chan error, 1000)
+        check := func(name string, query int, scores []float64, docs []string, hits []bm25.Hit, err error) {
+            if err != nil {
+                errs <- fmt.Errorf("%s: %v", name, err)
+                return
+            }
+            results <- result{name: name, query: query, scores: scores, docs: docs, hits: hits}
+        }
+
+        var wg sync.WaitGroup
+        for g := 0; g < 4; g++ {
+            for qi := range queries {
+                wg.Add(1)
+                go func(qi int) {
+                    defer wg.Done()
+                    q := queries[qi]
+                    scores, err := scorer.GetScores(q)
+                    check("GetScores", qi, scores, nil, nil, err)
+                    scores, err = scorer.GetBatchScores(q, docIDs)
+                    check("GetBatchScores", qi, scores, nil, nil, err)
+                    docs, err := scorer.GetTopN(q, 5)
+                    check("GetTopN", qi, nil, docs, nil, err)
+                    hits, err := scorer.Search(q, 5)
+                    check("Search", qi, nil, nil, hits, err)
+                    scores, err = ps.GetScoresParallel(q, scorer)
+                    check("GetScoresParallel", qi, scores, nil, nil, err)
+                    scores, err = ps.GetBatchScoresParallel(q, docIDs, scorer)
+                    check("GetBatchScoresParallel", qi, scores, nil, nil, err)
+                    docs, err = ps.GetTopNParallel(q, 5, scorer)
+                    check("GetTopNParallel", qi, nil, docs, nil, err)
+                    scores, err = ps.GetScoresBatched(q, scorer, 37)
+                    check("GetScoresBatched", qi, scores, nil, nil, err)
+                    scores, err = ps.GetBatchScoresBatched(q, docIDs, scorer, 3)
+                    check("GetBatchScoresBatched", qi, scores, nil, nil, err)
+                    docs, err = ps.GetTopNBatched(q, 5, scorer, 37)
+                    check("GetTopNBatched", qi, nil, docs, nil, err)
+                    for _, term := range q {
+                        if _, err := scorer.IDF(term); err != nil {
+                            errs <- fmt.Errorf("IDF: %v", err)
+                        }
+                    }
+                }(qi)
+            }
+        }
+        wg.Wait()
+        close(results)
+        close(errs)
+
+        for err := range errs {
+            t.Errorf("%s: unexpected error: %v", variant, err)
+        }
+
+        // Test case: Concurrent results match the sequential ones exactly
+        for r := range results {
+            q := queries[r.query]
+            var want interface{}
+            var got interface{}
+            switch r.name {
+            case "GetScores", "GetScoresParallel", "GetScoresBatched":
+                want, _ = scorer.GetScores(q)
+                got = r.scores
+            case "GetBatchScores", "GetBatchScoresParallel", "GetBatchScoresBatched":
+                want, _ = scorer.GetBatchScores(q, docIDs)
+                got = r.scores
+            case "GetTopN", "GetTopNParallel", "GetTopNBatched":
+                want, _ = scorer.GetTopN(q, 5)
+                got = r.docs
+            case "Search":
+                want, _ = scorer.Search(q, 5)
+                got = r.hits
+            }
+            if !reflect.DeepEqual(got, want) {
+                t.Errorf("%s: %s %v: expected %v, but got %v", variant, r.name, q, want, got)
+            }
+        }
+    }
+}
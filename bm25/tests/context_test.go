@@ -0,0 +1,139 @@
+package bm25_test
+
+import (
+    "context"
+    "errors"
+    "reflect"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+// countdownContext is a context that is canceled once its Err method has been
+// called a given number of times, so that a test can cancel a search
+// deterministically in the middle of its scoring loops.
+type countdownContext struct {
+    context.Context
+    remaining int64
+}
+
+func (c *countdownContext) Err() error {
+    if atomic.AddInt64(&c.remaining, -1) < 0 {
+        return context.Canceled
+    }
+    return nil
+}
+
+// contextScorer is implemented by every variant through its base.
+type contextScorer interface {
+    GetScoresParallelContext(ctx context.Context, query []string, bm25 bm25.BM25) ([]float64, error)
+    GetBatchScoresParallelContext(ctx context.Context, query []string, docIDs []int, bm25 bm25.BM25) ([]float64, error)
+    GetTopNParallelContext(ctx context.Context, query []string, n int, bm25 bm25.BM25) ([]string, error)
+    GetScoresBatchedContext(ctx context.Context, query []string, bm25 bm25.BM25, batchSize int) ([]float64, error)
+    GetBatchScoresBatchedContext(ctx context.Context, query []string, docIDs []int, bm25 bm25.BM25, batchSize int) ([]float64, error)
+    GetTopNBatchedContext(ctx context.Context, query []string, n int, bm25 bm25.BM25, batchSize int) ([]string, error)
+}
+
+// contextCalls returns every context-aware scoring and top-N method of the
+// scorer, each returning its result and error.
+func contextCalls(scorer bm25.BM25, query []string, docIDs []int) map[string]func(ctx context.Context) (interface{}, error) {
+    cs := scorer.(contextScorer)
+    return map[string]func(ctx context.Context) (interface{}, error){
+        "GetScoresContext": func(ctx context.Context) (interface{}, error) {
+            return scorer.GetScoresContext(ctx, query)
+        },
+        "GetBatchScoresContext": func(ctx context.Context) (interface{}, error) {
+            return scorer.GetBatchScoresContext(ctx, query, docIDs)
+        },
+        "GetTopNContext": func(ctx context.Context) (interface{}, error) {
+            return scorer.GetTopNContext(ctx, query, 10)
+        },
+        "SearchContext": func(ctx context.Context) (interface{}, error) {
+            return scorer.SearchContext(ctx, query, 10)
+        },
+        "GetScoresParallelContext": func(ctx context.Context) (interface{}, error) {
+            return cs.GetScoresParallelContext(ctx, query, scorer)
+        },
+        "GetBatchScoresParallelContext": func(ctx context.Context) (interface{}, error) {
+            return cs.GetBatchScoresParallelContext(ctx, query, docIDs, scorer)
+        },
+        "GetTopNParallelContext": func(ctx context.Context) (interface{}, error) {
+            return cs.GetTopNParallelContext(ctx, query, 10, scorer)
+        },
+        "GetScoresBatchedContext": func(ctx context.Context) (interface{}, error) {
+            return cs.GetScoresBatchedContext(ctx, query, scorer, 4000)
+        },
+        "GetBatchScoresBatchedContext": func(ctx context.Context) (interface{}, error) {
+            return cs.GetBatchScoresBatchedContext(ctx, query, docIDs, scorer, 4000)
+        },
+        "GetTopNBatchedContext": func(ctx context.Context) (interface{}, error) {
+            return cs.GetTopNBatchedContext(ctx, query, 10, scorer, 4000)
+        },
+    }
+}
+
+func TestContextCancellation(t *testing.T) {
+    // Every document contains "common", with varying lengths, so that no
+    // evaluator can stop early and every scoring loop runs for thousands of
+    // steps.
+    corpus := make([]string, 6000)
+    docIDs := make([]int, len(corpus))
+    for docID := range corpus {
+        corpus[docID] = "d" + strconv.Itoa(docID) + " common" + strings.Repeat(" filler", docID%13)
+        docIDs[docID] = docID
+    }
+    query := []string{"common", "filler"}
+
+    for _, e := range evaluations {
+        for variant, scorer := range newVariants(t, corpus, bm25.WithEvaluation(e)) {
+            name := e.String() + " " + variant
+            for method, call := range contextCalls(scorer, query, docIDs) {
+                // Test case: A background context gives the same result as
+                // the method without a context
+                got, err := call(context.Background())
+                if err != nil {
+                    t.Fatalf("%s: %s: unexpected error: %v", name, method, err)
+                }
+                var want interface{}
+                switch {
+                case strings.HasPrefix(method, "GetScores"):
+                    want, _ = scorer.GetScores(query)
+                case strings.HasPrefix(method, "GetBatchScores"):
+                    want, _ = scorer.GetBatchScores(query, docIDs)
+                case strings.HasPrefix(method, "GetTopN"):
+                    want, _ = scorer.GetTopN(query, 10)
+                default:
+                    want, _ = scorer.Search(query, 10)
+                }
+                if !reflect.DeepEqual(got, want) {
+                    t.Errorf("%s: %s: expected the same result as without a context", name, method)
+                }
+
+                // Test case: A canceled context returns before any scoring
+                ctx, cancel := context.WithCancel(context.Background())
+                cancel()
+                if _, err := call(ctx); !errors.Is(err, context.Canceled) {
+                    t.Errorf("%s: %s: expected context.Canceled, but got %v", name, method, err)
+                }
+
+                // Test case: An expired deadline is reported as such
+                ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+                if _, err := call(ctx); !errors.Is(err, context.DeadlineExceeded) {
+                    t.Errorf("%s: %s: expected context.DeadlineExceeded, but got %v", name, method, err)
+                }
+                cancel()
+
+                // Test case: A context canceled while scoring stops the
+                // scoring loops
+                ctx = &countdownContext{Context: context.Background(), remaining: 1}
+                if _, err := call(ctx); !errors.Is(err, context.Canceled) {
+                    t.Errorf("%s: %s: expected cancellation inside the scoring loop, but got %v", name, method, err)
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,88 @@
+package bm25_test
+
+import (
+    "fmt"
+    "math/rand"
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+var evaluations = []bm25.Evaluation{bm25.EvalWAND, bm25.EvalBlockMaxWAND, bm25.EvalMaxScore, bm25.EvalExhaustive}
+
+// randomQuery returns a query of one to five terms drawn from the vocabulary
+// of randomCorpus, including some unknown terms.
+func randomQuery(rng *rand.Rand) []string {
+    query := []string{}
+    for j := rng.Intn(5); j >= 0; j-- {
+        query = append(query, fmt.Sprintf("t%d", rng.Intn(600)))
+    }
+    return query
+}
+
+func TestWithEvaluation(t *testing.T) {
+    corpus := []string{"hello world", "this is a test"}
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+
+    // Test case: An unknown evaluation strategy is rejected
+    _, err := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithEvaluation(bm25.Evaluation(42)))
+    if err == nil {
+        t.Errorf("Expected an error for an unknown evaluation strategy, but got nil")
+    }
+}
+
+func TestEvaluationsMatchExhaustiveScoring(t *testing.T) {
+    rng := rand.New(rand.NewSource(3))
+    corpus := randomCorpus(rng, 2000)
+    queries := make([][]string, 30)
+    for i := range queries {
+        queries[i] = randomQuery(rng)
+    }
+
+    // Test case: Every strategy returns the same ranking as exhaustive
+    // scoring for every variant
+    for _, e := range evaluations {
+        for name, scorer := range newVariants(t, corpus, bm25.WithEvaluation(e)) {
+            for _, query := range queries {
+                for _, n := range []int{1, 10, 100} {
+                    checkTopN(t, e.String()+" "+name, scorer, corpus, query, n)
+                }
+            }
+        }
+    }
+}
+
+func BenchmarkEvaluation(b *testing.B) {
+    rng := rand.New(rand.NewSource(1))
+    corpus := randomCorpus(rng, 20000)
+    queries := make([][]string, 20)
+    for i := range queries {
+        queries[i] = randomQuery(rng)
+    }
+
+    exhaustive := newVariants(b, corpus, bm25.WithEvaluation(bm25.EvalExhaustive))
+    for _, e := range evaluations {
+        variants := newVariants(b, corpus, bm25.WithEvaluation(e))
+        for _, name := range []string{"Okapi", "L", "Plus", "Adpt", "T"} {
+            scorer := variants[name]
+            // Verify the strategy against exhaustive scoring before timing it.
+            for _, query := range queries {
+                expected, _ := exhaustive[name].Search(query, 10)
+                hits, err := scorer.Search(query, 10)
+                if err != nil {
+                    b.Fatalf("Unexpected error: %v", err)
+                }
+                if fmt.Sprint(hits) != fmt.Sprint(expected) {
+                    b.Fatalf("%s %s %v: expected hits %v, but got %v", e, name, query, expected, hits)
+                }
+            }
+
+            b.Run(e.String()+"/"+name, func(b *testing.B) {
+                for i := 0; i < b.N; i++ {
+                    scorer.Search(queries[i%len(queries)], 10)
+                }
+            })
+        }
+    }
+}
@@ -0,0 +1,125 @@
+package bm25_test
+
+import (
+    "math"
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+func TestIDFFuncs(t *testing.T) {
+    // Test case: Each built-in formula for a term in 2 of 10 documents
+    cases := []struct {
+        name     string
+        idf      bm25.IDFFunc
+        expected float64
+    }{
+        {"Lucene", bm25.LuceneIDF, math.Log(1 + 8.5/2.5)},
+        {"RobertsonSparckJones", bm25.RobertsonSparckJonesIDF, math.Log(8.5 / 2.5)},
+        {"Smoothed", bm25.SmoothedIDF, math.Log(11 / 2.5)},
+        {"BM25Plus", bm25.BM25PlusIDF, math.Log(11.0 / 2.0)},
+        {"Probabilistic", bm25.ProbabilisticIDF, math.Log(8.0 / 2.0)},
+    }
+    for _, c := range cases {
+        if idf := c.idf(2, 10); math.Abs(idf-c.expected) > 1e-12 {
+            t.Errorf("Expected %s IDF %.4f, but got %.4f", c.name, c.expected, idf)
+        }
+    }
+
+    // Test case: Lucene IDF stays positive for a term found in every document
+    if idf := bm25.LuceneIDF(10, 10); idf <= 0 {
+        t.Errorf("Expected a positive Lucene IDF for a term in every document, but got %.4f", idf)
+    }
+
+    // Test case: BM25Plus IDF is zero, not infinite, for unseen terms
+    if idf := bm25.BM25PlusIDF(0, 10); idf != 0 {
+        t.Errorf("Expected BM25Plus IDF 0 for an unseen term, but got %.4f", idf)
+    }
+
+    // Test case: Probabilistic IDF is clamped at zero for common terms
+    if idf := bm25.ProbabilisticIDF(6, 10); idf != 0 {
+        t.Errorf("Expected probabilistic IDF 0 for a common term, but got %.4f", idf)
+    }
+}
+
+func TestWithIDF(t *testing.T) {
+    corpus := []string{"the cat", "the dog", "the bird"}
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+
+    // Test case: A nil IDF function is rejected
+    _, err := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(nil))
+    if err == nil {
+        t.Errorf("Expected an error for a nil IDF function, but got nil")
+    }
+
+    // Test case: The default IDF is the Robertson-Sparck Jones formula
+    okapi, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    idf, err := okapi.IDF("the")
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if expected := bm25.RobertsonSparckJonesIDF(3, 3); idf != expected {
+        t.Errorf("Expected IDF %.4f, but got %.4f", expected, idf)
+    }
+
+    // Test case: A term found in every document still scores with LuceneIDF
+    okapi, _ = bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
+    scores, err := okapi.GetScores([]string{"the"})
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    for i, score := range scores {
+        if score <= 0 {
+            t.Errorf("Expected a positive score at index %d, but got %.4f", i, score)
+        }
+    }
+
+    // Test case: The selected IDF function is used by the scorer
+    okapi, _ = bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.SmoothedIDF))
+    idf, err = okapi.IDF("the")
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if expected := bm25.SmoothedIDF(3, 3); idf != expected {
+        t.Errorf("Expected IDF %.4f, but got %.4f", expected, idf)
+    }
+}
+
+func TestWithEpsilon(t *testing.T) {
+    corpus := []string{"the cat", "the dog", "the bird"}
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+
+    // Test case: A negative epsilon is rejected
+    _, err := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithEpsilon(-0.25))
+    if err == nil {
+        t.Errorf("Expected an error for negative epsilon, but got nil")
+    }
+
+    // Test case: Negative IDF values are floored to epsilon times the average IDF
+    okapi, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.RobertsonSparckJonesIDF), bm25.WithEpsilon(0.25))
+    average := (bm25.RobertsonSparckJonesIDF(3, 3) + 3*bm25.RobertsonSparckJonesIDF(1, 3)) / 4
+    if math.Abs(okapi.AverageIDF()-average) > 1e-12 {
+        t.Errorf("Expected average IDF %.4f, but got %.4f", average, okapi.AverageIDF())
+    }
+    idf, err := okapi.IDF("the")
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if math.Abs(idf-0.25*average) > 1e-12 {
+        t.Errorf("Expected floored IDF %.4f, but got %.4f", 0.25*average, idf)
+    }
+
+    // Test case: Positive IDF values are left untouched
+    idf, _ = okapi.IDF("cat")
+    if idf != bm25.RobertsonSparckJonesIDF(1, 3) {
+        t.Errorf("Expected IDF %.4f, but got %.4f", bm25.RobertsonSparckJonesIDF(1, 3), idf)
+    }
+
+    // Test case: BM25Plus applies its epsilon to the IDF floor
+    plus, _ := bm25.NewBM25Plus(corpus, tokenizer, 1.2, 0.75, 1.0, 0.5, nil, bm25.WithIDF(bm25.RobertsonSparckJonesIDF))
+    idf, _ = plus.IDF("the")
+    if math.Abs(idf-0.5*average) > 1e-12 {
+        t.Errorf("Expected floored IDF %.4f, but got %.4f", 0.5*average, idf)
+    }
+}
@@ -0,0 +1,239 @@
+package bm25_test
+
+import (
+    "bytes"
+    "errors"
+    "math/rand"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+// saveMapped writes the index of the scorer to a memory-mapped index file in
+// a temporary directory and returns its path.
+func saveMapped(t *testing.T, scorer bm25.BM25) string {
+    path := filepath.Join(t.TempDir(), "index.bm25m")
+    f, err := os.Create(path)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    defer f.Close()
+    if err := scorer.SaveMapped(f); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    return path
+}
+
+func TestOpenMapped(t *testing.T) {
+    rng := rand.New(rand.NewSource(19))
+    corpus := randomCorpus(rng, 300)
+    queries := [][]string{{"t1", "t3", "t20"}, {"t7"}, {"t2", "t2", "t400"}, {"d0", "d150", "d299"}, {"missing"}}
+
+    for variant, scorer := range newVariants(t, corpus, bm25.WithMaxBufferedDocs(64)) {
+        path := saveMapped(t, scorer)
+        for _, e := range evaluations {
+            name := e.String() + " " + variant
+            mapped, err := bm25.OpenMapped(path, nil, bm25.WithEvaluation(e))
+            if err != nil {
+                t.Fatalf("%s: unexpected error: %v", name, err)
+            }
+
+            // Test case: The file is intact
+            if err := mapped.Verify(); err != nil {
+                t.Errorf("%s: unexpected error: %v", name, err)
+            }
+
+            // Test case: The mapped index has the statistics of the saved one
+            if mapped.CorpusSize() != scorer.CorpusSize() || mapped.AvgDocLen() != scorer.AvgDocLen() {
+                t.Errorf("%s: expected corpus size %d and average length %v, but got %d and %v", name, scorer.CorpusSize(), scorer.AvgDocLen(), mapped.CorpusSize(), mapped.AvgDocLen())
+            }
+            if mapped.DocFreq("t1") != scorer.DocFreq("t1") || mapped.CollectionFreq("t1") != scorer.CollectionFreq("t1") {
+                t.Errorf("%s: expected the frequencies of 't1' to be preserved", name)
+            }
+            lengths, expectedLengths := mapped.DocLengths(), scorer.DocLengths()
+            for docID := range expectedLengths {
+                if lengths[docID] != expectedLengths[docID] {
+                    t.Errorf("%s: expected length %d for document %d, but got %d", name, expectedLengths[docID], docID, lengths[docID])
+                }
+            }
+            if doc, _ := mapped.Document(42); doc != corpus[42] {
+                t.Errorf("%s: expected document '%s', but got '%s'", name, corpus[42], doc)
+            }
+
+            // Test case: The mapped index scores and ranks like the saved one
+            for _, query := range queries {
+                scores, _ := mapped.GetScores(query)
+                expectedScores, _ := scorer.GetScores(query)
+                for docID := range expectedScores {
+                    if scores[docID] != expectedScores[docID] {
+                        t.Errorf("%s %v: expected score %v for document %d, but got %v", name, query, expectedScores[docID], docID, scores[docID])
+                    }
+                }
+                for _, n := range []int{1, 10, 300} {
+                    checkSameRanking(t, name, mapped, scorer, identityRemap(len(corpus)), query, n)
+                }
+            }
+
+            if err := mapped.Close(); err != nil {
+                t.Errorf("%s: unexpected error: %v", name, err)
+            }
+        }
+    }
+}
+
+func TestOpenMappedAverageIDF(t *testing.T) {
+    rng := rand.New(rand.NewSource(23))
+    corpus := randomCorpus(rng, 100)
+    type averager interface{ AverageIDF() float64 }
+
+    okapi, _ := bm25.NewBM25Okapi(corpus, strings.Fields, 1.2, 0.75, nil, bm25.WithEpsilon(0.25))
+    path := saveMapped(t, okapi)
+
+    // Test case: The average IDF of the IDF floor is read from the header
+    mapped, err := bm25.OpenMapped(path, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    defer mapped.Close()
+    if avg := mapped.BM25.(averager).AverageIDF(); avg != okapi.AverageIDF() {
+        t.Errorf("Expected average IDF %v, but got %v", okapi.AverageIDF(), avg)
+    }
+
+    // Test case: The average IDF is computed again for another IDF function
+    lucene, _ := bm25.NewBM25Okapi(corpus, strings.Fields, 1.2, 0.75, nil, bm25.WithEpsilon(0.25), bm25.WithIDF(bm25.LuceneIDF))
+    withLucene, err := bm25.OpenMapped(path, nil, bm25.WithIDF(bm25.LuceneIDF))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    defer withLucene.Close()
+    if avg := withLucene.BM25.(averager).AverageIDF(); avg != lucene.AverageIDF() {
+        t.Errorf("Expected average IDF %v, but got %v", lucene.AverageIDF(), avg)
+    }
+
+    // Test case: The average IDF is computed when the floor is turned on
+    plain, _ := bm25.NewBM25Okapi(corpus, strings.Fields, 1.2, 0.75, nil)
+    withEpsilon, err := bm25.OpenMapped(saveMapped(t, plain), nil, bm25.WithEpsilon(0.25))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    defer withEpsilon.Close()
+    if avg := withEpsilon.BM25.(averager).AverageIDF(); avg != okapi.AverageIDF() {
+        t.Errorf("Expected average IDF %v, but got %v", okapi.AverageIDF(), avg)
+    }
+}
+
+func TestMappedIndexReadOnly(t *testing.T) {
+    corpus := []string{"hello world", "this is a test", "hello there world"}
+    tokenizer := strings.Fields
+    okapi, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithExternalIDs([]string{"a", "b", "c"}))
+    path := saveMapped(t, okapi)
+    mapped, err := bm25.OpenMapped(path, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    defer mapped.Close()
+
+    // Test case: The mapped index keeps the external IDs
+    if hits, _ := mapped.Search([]string{"there"}, 1); len(hits) != 1 || hits[0].ExternalID != "c" {
+        t.Errorf("Expected a hit with external ID 'c', but got %+v", hits)
+    }
+
+    // Test case: Modifying a mapped index fails
+    if err := mapped.AddDocuments("new document"); !errors.Is(err, bm25.ErrReadOnly) {
+        t.Errorf("Expected ErrReadOnly, but got %v", err)
+    }
+    if err := mapped.DeleteDocument(0); !errors.Is(err, bm25.ErrReadOnly) {
+        t.Errorf("Expected ErrReadOnly, but got %v", err)
+    }
+    if _, err := mapped.UpdateDocument(0, "new text"); !errors.Is(err, bm25.ErrReadOnly) {
+        t.Errorf("Expected ErrReadOnly, but got %v", err)
+    }
+    if err := mapped.Save(&bytes.Buffer{}); !errors.Is(err, bm25.ErrReadOnly) {
+        t.Errorf("Expected ErrReadOnly, but got %v", err)
+    }
+
+    // Test case: Saving a mapped index copies its file
+    saved, _ := os.ReadFile(path)
+    var buf bytes.Buffer
+    if err := mapped.SaveMapped(&buf); err != nil || !bytes.Equal(buf.Bytes(), saved) {
+        t.Errorf("Expected the mapped file to be copied, but got error %v", err)
+    }
+
+    // Test case: The parallel entry points accept a mapped index
+    scores, err := okapi.GetScoresParallel([]string{"hello"}, mapped)
+    expected, _ := okapi.GetScores([]string{"hello"})
+    if err != nil || scores[0] != expected[0] {
+        t.Errorf("Expected score %v, but got %v (error %v)", expected[0], scores[0], err)
+    }
+
+    // Test case: An index with deleted documents must be compacted first
+    okapi.DeleteDocument(1)
+    if err := okapi.SaveMapped(&bytes.Buffer{}); err == nil {
+        t.Errorf("Expected an error for an index with deleted documents, but got nil")
+    }
+    okapi.Compact()
+    if err := okapi.SaveMapped(&bytes.Buffer{}); err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+}
+
+func TestOpenMappedErrors(t *testing.T) {
+    corpus := []string{"hello world", "this is a test", "hello there world"}
+    okapi, _ := bm25.NewBM25Okapi(corpus, strings.Fields, 1.2, 0.75, nil)
+    path := saveMapped(t, okapi)
+    saved, _ := os.ReadFile(path)
+
+    open := func(data []byte) error {
+        path := filepath.Join(t.TempDir(), "modified.bm25m")
+        os.WriteFile(path, data, 0o644)
+        mapped, err := bm25.OpenMapped(path, nil)
+        if err == nil {
+            err = mapped.Verify()
+            mapped.Close()
+        }
+        return err
+    }
+    modified := func(modify func(data []byte) []byte) []byte {
+        return modify(append([]byte(nil), saved...))
+    }
+
+    // Test case: A missing file
+    if _, err := bm25.OpenMapped(filepath.Join(t.TempDir(), "missing"), nil); !os.IsNotExist(err) {
+        t.Errorf("Expected a not-exist error, but got %v", err)
+    }
+
+    // Test case: A file that is not a memory-mapped index
+    if err := open([]byte("hello world")); !errors.Is(err, bm25.ErrNotIndexFile) {
+        t.Errorf("Expected ErrNotIndexFile, but got %v", err)
+    }
+    var buf bytes.Buffer
+    okapi.Save(&buf)
+    if err := open(buf.Bytes()); !errors.Is(err, bm25.ErrNotIndexFile) {
+        t.Errorf("Expected ErrNotIndexFile for a saved index, but got %v", err)
+    }
+
+    // Test case: Another format version
+    var versionErr *bm25.VersionError
+    if err := open(modified(func(data []byte) []byte {
+        data[8] = 7
+        return data
+    })); !errors.As(err, &versionErr) || versionErr.Version != 7 {
+        t.Errorf("Expected a VersionError for version 7, but got %v", err)
+    }
+
+    // Test case: A truncated file
+    if err := open(saved[:len(saved)-3]); !errors.Is(err, bm25.ErrCorruptIndex) {
+        t.Errorf("Expected ErrCorruptIndex, but got %v", err)
+    }
+
+    // Test case: Verify detects damaged contents
+    if err := open(modified(func(data []byte) []byte {
+        data[len(data)-1] ^= 0xff
+        return data
+    })); !errors.Is(err, bm25.ErrChecksumMismatch) {
+        t.Errorf("Expected ErrChecksumMismatch, but got %v", err)
+    }
+}
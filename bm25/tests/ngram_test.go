@@ -0,0 +1,151 @@
+package bm25_test
+
+import (
+    "reflect"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+func TestNGramTokenizers(t *testing.T) {
+    testCases := []struct {
+        name     string
+        edge     bool
+        min, max int
+        text     string
+        expected []string
+    }{
+        // Test case: Every gram from min to max, by start and then length
+        {"ngram", false, 2, 3, "iPhone", []string{"iP", "iPh", "Ph", "Pho", "ho", "hon", "on", "one", "ne"}},
+        // Test case: Edge grams only start at the beginning of each word
+        {"edge", true, 1, 4, "iPhone 15", []string{"i", "iP", "iPh", "iPho", "1", "15"}},
+        // Test case: Words shorter than min are kept whole
+        {"short", false, 3, 3, "a AB-12", []string{"a", "AB-", "B-1", "-12"}},
+        // Test case: Grams are counted in characters, not bytes
+        {"runes", false, 2, 2, "café", []string{"ca", "af", "fé"}},
+        {"edge runes", true, 2, 3, "über", []string{"üb", "übe"}},
+        // Test case: Empty text
+        {"empty", false, 1, 2, "  ", nil},
+    }
+
+    for _, tc := range testCases {
+        newTokenizer := bm25.NGramTokenizer
+        if tc.edge {
+            newTokenizer = bm25.EdgeNGramTokenizer
+        }
+        tokenizer, err := newTokenizer(tc.min, tc.max)
+        if err != nil {
+            t.Fatalf("%s: unexpected error: %v", tc.name, err)
+        }
+        if tokens := tokenizer(tc.text); !reflect.DeepEqual(tokens, tc.expected) {
+            t.Errorf("%s: expected tokens %q, but got %q", tc.name, tc.expected, tokens)
+        }
+    }
+
+    // Test case: Invalid gram sizes
+    for _, sizes := range [][2]int{{0, 2}, {3, 2}, {-1, -1}} {
+        if _, err := bm25.NGramTokenizer(sizes[0], sizes[1]); err == nil {
+            t.Errorf("NGramTokenizer: expected an error for gram sizes %v, but got nil", sizes)
+        }
+        if _, err := bm25.EdgeNGramTokenizer(sizes[0], sizes[1]); err == nil {
+            t.Errorf("EdgeNGramTokenizer: expected an error for gram sizes %v, but got nil", sizes)
+        }
+        if _, err := bm25.NGramFilter(sizes[0], sizes[1]); err == nil {
+            t.Errorf("NGramFilter: expected an error for gram sizes %v, but got nil", sizes)
+        }
+        if _, err := bm25.EdgeNGramFilter(sizes[0], sizes[1]); err == nil {
+            t.Errorf("EdgeNGramFilter: expected an error for gram sizes %v, but got nil", sizes)
+        }
+    }
+
+    // Test case: The filters split tokens into grams
+    filter, err := bm25.NGramFilter(3, 3)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if tokens, expected := filter([]string{"abcd", "xy"}), []string{"abc", "bcd", "xy"}; !reflect.DeepEqual(tokens, expected) {
+        t.Errorf("Expected tokens %q, but got %q", expected, tokens)
+    }
+}
+
+func TestNGramSearch(t *testing.T) {
+    corpus := []string{
+        "Apple iPhone 15 Pro",
+        "Samsung Galaxy S24",
+        "Google Pixel 8 phone case",
+        "Sony WH-1000XM5 headphones",
+    }
+
+    for _, edge := range []bool{false, true} {
+        newFilter := bm25.NGramFilter
+        if edge {
+            newFilter = bm25.EdgeNGramFilter
+        }
+        filter, err := newFilter(2, 5)
+        if err != nil {
+            t.Fatalf("Unexpected error: %v", err)
+        }
+        analyzer := bm25.NewAnalyzer(bm25.WhitespaceTokenizer, bm25.LowercaseFilter, filter)
+
+        for _, e := range evaluations {
+            for variant, scorer := range newVariants(t, corpus, bm25.WithAnalyzer(analyzer), bm25.WithEvaluation(e)) {
+                name := e.String() + " " + variant
+                if edge {
+                    name = "edge " + name
+                }
+
+                // Test case: Grams are indexed as terms
+                if scorer.DocFreq("iph") != 1 {
+                    t.Errorf("%s: expected the gram iph to be indexed once", name)
+                }
+
+                // Test case: A partial word ranks the document containing it
+                // first
+                top, err := scorer.GetTopN(scorer.Analyze("iph"), 1)
+                if err != nil {
+                    t.Fatalf("%s: unexpected error: %v", name, err)
+                }
+                if len(top) != 1 || top[0] != corpus[0] {
+                    t.Errorf("%s: expected %q first for iph, but got %v", name, corpus[0], top)
+                }
+                hits, err := scorer.SearchText("GALAX", 5)
+                if err != nil {
+                    t.Fatalf("%s: unexpected error: %v", name, err)
+                }
+                if len(hits) != 1 || hits[0].DocID != 1 {
+                    t.Errorf("%s: expected document 1 only for galax, but got %v", name, hits)
+                }
+            }
+        }
+    }
+
+    // Test case: Inner parts of product codes match with n-grams only
+    filter, err := bm25.NGramFilter(3, 4)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    okapi, err := bm25.NewBM25Okapi(corpus, nil, 1.2, 0.75, nil, bm25.WithAnalyzer(bm25.NewAnalyzer(bm25.WhitespaceTokenizer, bm25.LowercaseFilter, filter)))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    hits, err := okapi.SearchText("1000xm", 5)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if len(hits) == 0 || hits[0].DocID != 3 {
+        t.Errorf("Expected document 3 first for 1000xm, but got %v", hits)
+    }
+
+    // Test case: The tokenizer plugs into a constructor directly
+    tokenizer, err := bm25.EdgeNGramTokenizer(1, 3)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    plus, err := bm25.NewBM25Plus(corpus, tokenizer, 1.2, 0.75, 1.0, 0.25, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if top, _ := plus.GetTopN(tokenizer("Pix"), 1); len(top) != 1 || top[0] != corpus[2] {
+        t.Errorf("Expected %q first for Pix, but got %v", corpus[2], top)
+    }
+}
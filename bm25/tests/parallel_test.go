@@ -1,6 +1,7 @@
 package bm25_test
 
 import (
+    "strings"
     "testing"
 
     "lenaxia/bm25_golang/bm25"
@@ -9,7 +10,7 @@ import (
 func TestGetScoresParallel(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting scores in parallel for an empty query
     _, err := bm25.GetScoresParallel([]string{}, bm25)
@@ -22,7 +23,7 @@ func TestGetScoresParallel(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{0.6931471805599453, 0.0}
+    expected := []float64{0.3648143055578659, 0.0}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -37,7 +38,7 @@ func TestGetScoresParallel(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{0.0, 1.3862943611198906}
+    expected = []float64{0.0, 0.5545177444479562}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -51,7 +52,7 @@ func TestGetScoresParallel(t *testing.T) {
 func TestGetBatchScoresParallel(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting batch scores in parallel for an empty query
     _, err := bm25.GetBatchScoresParallel([]string{}, []int{0, 1}, bm25)
@@ -76,7 +77,7 @@ func TestGetBatchScoresParallel(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected := []float64{0.6931471805599453}
+    expected := []float64{0.3648143055578659}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -91,7 +92,7 @@ func TestGetBatchScoresParallel(t *testing.T) {
     if err != nil {
         t.Errorf("Unexpected error: %v", err)
     }
-    expected = []float64{1.3862943611198906}
+    expected = []float64{0.5545177444479562}
     if len(scores) != len(expected) {
         t.Errorf("Expected %d scores, but got %d", len(expected), len(scores))
     }
@@ -105,7 +106,7 @@ func TestGetBatchScoresParallel(t *testing.T) {
 func TestGetTopNParallel(t *testing.T) {
     corpus := []string{"hello world", "this is a test"}
     tokenizer := func(s string) []string { return strings.Split(s, " ") }
-    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
 
     // Test case: Getting top N documents in parallel for an empty query
     _, err := bm25.GetTopNParallel([]string{}, 2, bm25)
@@ -0,0 +1,260 @@
+package bm25_test
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "hash/crc32"
+    "math"
+    "math/rand"
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+func TestSaveLoad(t *testing.T) {
+    rng := rand.New(rand.NewSource(17))
+    corpus := randomCorpus(rng, 300)
+    queries := [][]string{{"t1", "t3", "t20"}, {"t7"}, {"t2", "t2", "t400"}, {"d0", "d150", "d299"}}
+
+    for variant, scorer := range newVariants(t, corpus, bm25.WithMaxBufferedDocs(64), bm25.WithEvaluation(bm25.EvalMaxScore)) {
+        for docID := 0; docID < len(corpus); docID += 9 {
+            if err := scorer.DeleteDocument(docID); err != nil {
+                t.Fatalf("%s: unexpected error: %v", variant, err)
+            }
+        }
+
+        var buf bytes.Buffer
+        if err := scorer.Save(&buf); err != nil {
+            t.Fatalf("%s: unexpected error: %v", variant, err)
+        }
+        saved := buf.Bytes()
+
+        // Test case: Saving twice gives the same file
+        var again bytes.Buffer
+        scorer.Save(&again)
+        if !bytes.Equal(saved, again.Bytes()) {
+            t.Errorf("%s: expected identical files for the same index", variant)
+        }
+
+        loaded, err := bm25.Load(bytes.NewReader(saved), strings.Fields, nil)
+        if err != nil {
+            t.Fatalf("%s: unexpected error: %v", variant, err)
+        }
+
+        // Test case: The loaded index has the statistics of the saved one
+        if loaded.CorpusSize() != scorer.CorpusSize() || loaded.AvgDocLen() != scorer.AvgDocLen() {
+            t.Errorf("%s: expected corpus size %d and average length %v, but got %d and %v", variant, scorer.CorpusSize(), scorer.AvgDocLen(), loaded.CorpusSize(), loaded.AvgDocLen())
+        }
+        if loaded.DocFreq("t1") != scorer.DocFreq("t1") || loaded.CollectionFreq("t1") != scorer.CollectionFreq("t1") {
+            t.Errorf("%s: expected the frequencies of 't1' to be preserved", variant)
+        }
+
+        // Test case: The loaded index scores and ranks like the saved one,
+        // deleted documents included
+        for _, query := range queries {
+            scores, _ := loaded.GetScores(query)
+            expectedScores, _ := scorer.GetScores(query)
+            for docID := range expectedScores {
+                if scores[docID] != expectedScores[docID] {
+                    t.Errorf("%s %v: expected score %v for document %d, but got %v", variant, query, expectedScores[docID], docID, scores[docID])
+                }
+            }
+            checkSameRanking(t, variant, loaded, scorer, identityRemap(len(corpus)), query, 20)
+        }
+        if _, err := loaded.Document(0); err == nil {
+            t.Errorf("%s: expected an error for a deleted document, but got nil", variant)
+        }
+
+        // Test case: The loaded index can still be updated
+        if err := loaded.AddDocuments("t1 t1 t7", "t3"); err != nil {
+            t.Fatalf("%s: unexpected error: %v", variant, err)
+        }
+        scorer.AddDocuments("t1 t1 t7", "t3")
+        for _, query := range queries {
+            checkSameRanking(t, variant, loaded, scorer, identityRemap(len(corpus)+2), query, 20)
+        }
+    }
+}
+
+func TestSaveLoadParameters(t *testing.T) {
+    corpus := []string{"hello world", "this is a test", "hello there world"}
+    tokenizer := strings.Fields
+    plus, _ := bm25.NewBM25Plus(corpus, tokenizer, 1.5, 0.6, 0.8, 0.3, nil, bm25.WithExternalIDs([]string{"a", "b", "c"}))
+
+    var buf bytes.Buffer
+    if err := plus.Save(&buf); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    loaded, err := bm25.Load(&buf, tokenizer, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    // Test case: The variant and its parameters are restored
+    if _, ok := loaded.(*bm25.BM25Plus); !ok {
+        t.Fatalf("Expected a *BM25Plus, but got %T", loaded)
+    }
+    expected, _ := plus.Search([]string{"hello", "test"}, 3)
+    hits, _ := loaded.Search([]string{"hello", "test"}, 3)
+    for i := range expected {
+        if hits[i] != expected[i] {
+            t.Errorf("Expected hit %+v, but got %+v", expected[i], hits[i])
+        }
+    }
+    if hits[0].ExternalID == "" {
+        t.Errorf("Expected the external IDs to be restored")
+    }
+}
+
+func TestLoadErrors(t *testing.T) {
+    corpus := []string{"hello world", "this is a test", "hello there world"}
+    tokenizer := strings.Fields
+    okapi, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+    var buf bytes.Buffer
+    okapi.Save(&buf)
+    saved := buf.Bytes()
+
+    modified := func(modify func(data []byte) []byte) *bytes.Reader {
+        return bytes.NewReader(modify(append([]byte(nil), saved...)))
+    }
+
+    // Test case: Data that is not an index file
+    if _, err := bm25.Load(strings.NewReader("hello world"), tokenizer, nil); !errors.Is(err, bm25.ErrNotIndexFile) {
+        t.Errorf("Expected ErrNotIndexFile, but got %v", err)
+    }
+
+    // Test case: A file written in another format version
+    _, err := bm25.Load(modified(func(data []byte) []byte {
+        binary.LittleEndian.PutUint32(data[8:12], 99)
+        return data
+    }), tokenizer, nil)
+    var versionErr *bm25.VersionError
+    if !errors.As(err, &versionErr) || versionErr.Version != 99 {
+        t.Errorf("Expected a VersionError for version 99, but got %v", err)
+    }
+
+    // Test case: A flipped byte fails the checksum
+    if _, err := bm25.Load(modified(func(data []byte) []byte {
+        data[len(data)/2] ^= 0xff
+        return data
+    }), tokenizer, nil); !errors.Is(err, bm25.ErrChecksumMismatch) {
+        t.Errorf("Expected ErrChecksumMismatch, but got %v", err)
+    }
+
+    // Test case: A truncated file
+    if _, err := bm25.Load(modified(func(data []byte) []byte {
+        return data[:len(data)-10]
+    }), tokenizer, nil); !errors.Is(err, bm25.ErrCorruptIndex) {
+        t.Errorf("Expected ErrCorruptIndex, but got %v", err)
+    }
+
+    // Test case: Loading without a tokenizer
+    if _, err := bm25.Load(bytes.NewReader(saved), nil, nil); err == nil {
+        t.Errorf("Expected an error for a nil tokenizer, but got nil")
+    }
+
+    // Test case: A custom IDF function must be given again
+    custom := func(docFreq, corpusSize int) float64 { return math.Log(float64(corpusSize) / float64(docFreq)) }
+    withCustom, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(custom))
+    buf.Reset()
+    withCustom.Save(&buf)
+    if _, err := bm25.Load(bytes.NewReader(buf.Bytes()), tokenizer, nil); !errors.Is(err, bm25.ErrCustomIDF) {
+        t.Errorf("Expected ErrCustomIDF, but got %v", err)
+    }
+    if _, err := bm25.Load(bytes.NewReader(buf.Bytes()), tokenizer, nil, bm25.WithIDF(custom)); err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+}
+
+// resealed returns the index file with its payload replaced, and the size in
+// the header and the checksum updated to match, so that Load decodes it.
+func resealed(saved, payload []byte) []byte {
+    data := append(append([]byte(nil), saved[:20]...), payload...)
+    binary.LittleEndian.PutUint64(data[12:20], uint64(len(payload)))
+    return binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(payload))
+}
+
+func TestLoadCorruptPayload(t *testing.T) {
+    corpus := []string{"hello world", "this is a test", "hello there world", "a test of the world"}
+    tokenizer := strings.Fields
+    for variant, scorer := range newVariants(t, corpus, bm25.WithExternalIDs([]string{"a", "b", "c", "d"})) {
+        scorer.DeleteDocument(1)
+        var buf bytes.Buffer
+        if err := scorer.Save(&buf); err != nil {
+            t.Fatalf("%s: unexpected error: %v", variant, err)
+        }
+        saved := buf.Bytes()
+        payload := saved[20 : len(saved)-4]
+
+        // Test case: A payload truncated anywhere, with a valid checksum, is
+        // reported as corrupt
+        for size := 0; size < len(payload); size++ {
+            if _, err := bm25.Load(bytes.NewReader(resealed(saved, payload[:size])), tokenizer, nil); !errors.Is(err, bm25.ErrCorruptIndex) {
+                t.Fatalf("%s: expected ErrCorruptIndex for a payload truncated to %d bytes, but got %v", variant, size, err)
+            }
+        }
+
+        // Test case: A payload with a damaged byte, with a valid checksum,
+        // either is reported as corrupt or loads an index that can be
+        // queried, but never panics
+        for offset := range payload {
+            for _, mask := range []byte{0x01, 0x80, 0xff} {
+                damaged := append([]byte(nil), payload...)
+                damaged[offset] ^= mask
+                loaded, err := bm25.Load(bytes.NewReader(resealed(saved, damaged)), tokenizer, nil)
+                if err != nil {
+                    if !errors.Is(err, bm25.ErrCorruptIndex) {
+                        t.Fatalf("%s: expected ErrCorruptIndex for byte %d ^ %#x, but got %v", variant, offset, mask, err)
+                    }
+                    continue
+                }
+                for _, query := range [][]string{{"hello", "world"}, {"test"}} {
+                    loaded.GetScores(query)
+                    loaded.GetBatchScores(query, []int{0})
+                    loaded.GetTopN(query, 3)
+                    loaded.Search(query, 3)
+                }
+                for docID := 0; docID < len(loaded.DocLengths()); docID++ {
+                    loaded.Document(docID)
+                }
+            }
+        }
+    }
+}
+
+func TestLoadInconsistentPostings(t *testing.T) {
+    tokenizer := strings.Fields
+    okapi, _ := bm25.NewBM25Okapi([]string{"x1 x2", "x2"}, tokenizer, 1.2, 0.75, nil)
+    var buf bytes.Buffer
+    okapi.Save(&buf)
+    saved := buf.Bytes()
+    payload := saved[20 : len(saved)-4]
+
+    // The posting lists of "x1" and "x2" in the segment, and the statistics
+    // of "x1" in the vocabulary
+    x1 := []byte("\x02x1\x01\x00\x01")
+    x2 := []byte("\x02x2\x02\x00\x01\x01\x01")
+    x1Stats := []byte("\x02x1\x01\x01")
+    if !bytes.Contains(payload, append(append([]byte(nil), x1...), x2...)) || !bytes.Contains(payload, x1Stats) {
+        t.Fatalf("Expected the payload to hold the postings and statistics of 'x1' and 'x2'")
+    }
+
+    for _, c := range []struct {
+        name     string
+        old, new []byte
+    }{
+        {"terms of a segment out of order", append(append([]byte(nil), x1...), x2...), append(append([]byte(nil), x2...), x1...)},
+        {"a term listed twice in a segment", x2, []byte("\x02x1\x02\x00\x01\x01\x01")},
+        {"postings that do not add up to the collection frequency", x2, []byte("\x02x2\x02\x00\x01\x01\x02")},
+        {"postings that do not add up to the document frequency", x1Stats, []byte("\x02x1\x02\x01")},
+    } {
+        // Test case: Inconsistent postings, with a valid checksum, are
+        // reported as corrupt
+        damaged := bytes.Replace(payload, c.old, c.new, 1)
+        if _, err := bm25.Load(bytes.NewReader(resealed(saved, damaged)), tokenizer, nil); !errors.Is(err, bm25.ErrCorruptIndex) {
+            t.Errorf("Expected ErrCorruptIndex for %s, but got %v", c.name, err)
+        }
+    }
+}
@@ -0,0 +1,122 @@
+package bm25_test
+
+import (
+    "bytes"
+    "math"
+    "strconv"
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+func TestCompressedPostings(t *testing.T) {
+    // "rare" occurs in widely spaced documents, so its document ID gaps need
+    // several bytes, "heavy" occurs hundreds of times in some documents and
+    // "common" fills many blocks.
+    corpus := make([]string, 5000)
+    for docID := range corpus {
+        tokens := []string{"d" + strconv.Itoa(docID), "common"}
+        if docID%1237 == 5 {
+            tokens = append(tokens, "rare")
+        }
+        if docID%97 == 0 {
+            tokens = append(tokens, strings.Fields(strings.Repeat("heavy ", 1+docID/10))...)
+        }
+        corpus[docID] = strings.Join(tokens, " ")
+    }
+
+    const k1, b = 1.2, 0.75
+    for _, codec := range []bm25.PostingCodec{bm25.VByteCodec, bm25.BitpackCodec} {
+        okapi, err := bm25.NewBM25Okapi(corpus, strings.Fields, k1, b, nil, bm25.WithMaxBufferedDocs(2000), bm25.WithPostingCodec(codec))
+        if err != nil {
+            t.Fatalf("%s: unexpected error: %v", codec, err)
+        }
+        checkCompressedPostings(t, codec.String(), corpus, okapi, k1, b)
+
+        // Test case: Both file formats record the codec, and any codec can
+        // be chosen when loading
+        var buf bytes.Buffer
+        if err := okapi.Save(&buf); err != nil {
+            t.Fatalf("%s: unexpected error: %v", codec, err)
+        }
+        for _, loadCodec := range []bm25.PostingCodec{bm25.VByteCodec, bm25.BitpackCodec} {
+            loaded, err := bm25.Load(bytes.NewReader(buf.Bytes()), strings.Fields, nil, bm25.WithPostingCodec(loadCodec))
+            if err != nil {
+                t.Fatalf("%s: unexpected error: %v", codec, err)
+            }
+            checkCompressedPostings(t, codec.String()+" loaded as "+loadCodec.String(), corpus, loaded, k1, b)
+        }
+        loaded, err := bm25.Load(bytes.NewReader(buf.Bytes()), strings.Fields, nil)
+        if err != nil {
+            t.Fatalf("%s: unexpected error: %v", codec, err)
+        }
+        checkCompressedPostings(t, codec.String()+" loaded", corpus, loaded, k1, b)
+        mapped, err := bm25.OpenMapped(saveMapped(t, okapi), nil)
+        if err != nil {
+            t.Fatalf("%s: unexpected error: %v", codec, err)
+        }
+        checkCompressedPostings(t, codec.String()+" mapped", corpus, mapped, k1, b)
+        mapped.Close()
+    }
+
+    // Test case: Unknown codecs are rejected
+    for _, codec := range []bm25.PostingCodec{0, 3} {
+        if _, err := bm25.NewBM25Okapi(corpus[:3], strings.Fields, k1, b, nil, bm25.WithPostingCodec(codec)); err == nil {
+            t.Errorf("Expected an error for the posting codec %s, but got nil", codec)
+        }
+    }
+}
+
+// batchedScorer is implemented by the scorers, but not by memory-mapped
+// indexes.
+type batchedScorer interface {
+    GetScoresBatched(query []string, scorer bm25.BM25, batchSize int) ([]float64, error)
+}
+
+// checkCompressedPostings checks the scores of single-term queries on the
+// index of the corpus against the Okapi scores computed from the text.
+func checkCompressedPostings(t *testing.T, name string, corpus []string, okapi bm25.BM25, k1, b float64) {
+    t.Helper()
+
+    // expected computes the Okapi score of a single-term query from the
+    // document text.
+    expected := func(term string, docID int) float64 {
+        tf, _ := bm25.CountTermFreq(term, corpus[docID], strings.Fields)
+        if tf == 0 {
+            return 0
+        }
+        idf, _ := okapi.IDF(term)
+        docLen := float64(okapi.DocLengths()[docID])
+        return idf * float64(tf) / (float64(tf) + k1*(1-b+b*docLen/okapi.AvgDocLen()))
+    }
+
+    for _, term := range []string{"rare", "heavy", "common", "d4999"} {
+        // Test case: Every posting decodes to its document and frequency
+        scores, _ := okapi.GetScores([]string{term})
+        for docID, score := range scores {
+            if math.Abs(score-expected(term, docID)) > 1e-12 {
+                t.Fatalf("%s %s: expected score %v for document %d, but got %v", name, term, expected(term, docID), docID, score)
+            }
+        }
+
+        // Test case: Skipping to documents through the block skip entries
+        docIDs := []int{0, 5, 63, 64, 1242, 2479, 3000, 4999}
+        batch, _ := okapi.GetBatchScores([]string{term}, docIDs)
+        for i, docID := range docIDs {
+            if batch[i] != scores[docID] {
+                t.Errorf("%s %s: expected score %v for document %d, but got %v", name, term, scores[docID], docID, batch[i])
+            }
+        }
+
+        // Test case: Starting batches in the middle of posting lists
+        if batcher, ok := okapi.(batchedScorer); ok {
+            batched, _ := batcher.GetScoresBatched([]string{term}, okapi, 333)
+            for docID := range scores {
+                if batched[docID] != scores[docID] {
+                    t.Errorf("%s %s: expected score %v for document %d, but got %v", name, term, scores[docID], docID, batched[docID])
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,109 @@
+package bm25_test
+
+import (
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+func TestSearch(t *testing.T) {
+    corpus := []string{"Hello there, good man!", "It is quite windy in London.", "How is the weather today?", "Hello there, good man!"}
+    tokenizer := func(s string) []string { return strings.Fields(strings.ToLower(strings.Trim(s, ".!?"))) }
+    ids := []string{"greeting-1", "weather-london", "weather-today", "greeting-2"}
+    bm25, err := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF), bm25.WithExternalIDs(ids))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    // Test case: Searching with an empty query
+    _, err = bm25.Search([]string{}, 2)
+    if err == nil {
+        t.Errorf("Expected an error for an empty query, but got nil")
+    }
+
+    // Test case: Searching with n <= 0
+    _, err = bm25.Search([]string{"windy"}, 0)
+    if err == nil {
+        t.Errorf("Expected an error for n <= 0, but got nil")
+    }
+
+    // Test case: Hits carry the document ID, score and external ID
+    scores, _ := bm25.GetScores([]string{"windy"})
+    hits, err := bm25.Search([]string{"windy"}, 3)
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if len(hits) != 1 {
+        t.Fatalf("Expected 1 hit, but got %d", len(hits))
+    }
+    if hits[0].DocID != 1 || hits[0].Score != scores[1] || hits[0].ExternalID != "weather-london" {
+        t.Errorf("Expected hit {1 %.4f weather-london}, but got %+v", scores[1], hits[0])
+    }
+
+    // Test case: Duplicate documents are distinguishable by ID
+    hits, err = bm25.Search([]string{"hello"}, 2)
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if len(hits) != 2 || hits[0].DocID == hits[1].DocID {
+        t.Errorf("Expected two distinct hits, but got %+v", hits)
+    }
+}
+
+func TestDocument(t *testing.T) {
+    corpus := []string{"Hello there, good man!", "It is quite windy in London."}
+    tokenizer := func(s string) []string { return strings.Fields(strings.ToLower(strings.Trim(s, ".!"))) }
+    bm25, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
+
+    // Test case: Fetching a document with an invalid ID
+    _, err := bm25.Document(2)
+    if err == nil {
+        t.Errorf("Expected an error for an invalid document ID, but got nil")
+    }
+
+    // Test case: Fetching the original text of a document
+    doc, err := bm25.Document(1)
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if doc != corpus[1] {
+        t.Errorf("Expected document '%s', but got '%s'", corpus[1], doc)
+    }
+
+    // Test case: Top N documents are returned with their original text
+    topDocs, err := bm25.GetTopN([]string{"windy"}, 1)
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if len(topDocs) != 1 || topDocs[0] != corpus[1] {
+        t.Errorf("Expected top document '%s', but got %v", corpus[1], topDocs)
+    }
+
+    // Test case: Without external IDs, hits have an empty external ID
+    hits, _ := bm25.Search([]string{"windy"}, 1)
+    if len(hits) != 1 || hits[0].ExternalID != "" {
+        t.Errorf("Expected one hit without an external ID, but got %+v", hits)
+    }
+}
+
+func TestWithExternalIDs(t *testing.T) {
+    corpus := []string{"hello world", "this is a test"}
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+
+    // Test case: The number of external IDs must match the corpus
+    _, err := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithExternalIDs([]string{"a"}))
+    if err == nil {
+        t.Errorf("Expected an error for mismatched external IDs, but got nil")
+    }
+
+    // Test case: External IDs can be looked up by document ID
+    okapi, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithExternalIDs([]string{"a", "b"}))
+    id, err := okapi.ExternalID(1)
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if id != "b" {
+        t.Errorf("Expected external ID 'b', but got '%s'", id)
+    }
+}
@@ -0,0 +1,191 @@
+package bm25_test
+
+import (
+    "math/rand"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+func TestSegmentMerging(t *testing.T) {
+    rng := rand.New(rand.NewSource(11))
+    corpus := randomCorpus(rng, 400)
+    policy := bm25.TieredMergePolicy{SegmentsPerTier: 3, MaxMergeAtOnce: 3, FloorSegmentDocs: 20, MaxSegmentDocs: 1000}
+
+    queries := [][]string{{"t1", "t3", "t20"}, {"t7"}, {"t2", "t2", "t400"}, {"d0", "d150", "d399"}}
+    expected := newVariants(t, corpus)
+    prefixes := map[int]map[string]bm25.BM25{}
+    for end := 50; end < len(corpus); end += 25 {
+        prefixes[end] = newVariants(t, corpus[:end])
+    }
+    for _, e := range evaluations {
+        opts := []bm25.Option{bm25.WithEvaluation(e), bm25.WithMaxBufferedDocs(10), bm25.WithMergePolicy(policy)}
+        for variant, scorer := range newVariants(t, corpus[:25], opts...) {
+            name := e.String() + " " + variant
+            for start := 25; start < len(corpus); start += 25 {
+                if err := scorer.AddDocuments(corpus[start : start+25]...); err != nil {
+                    t.Fatalf("%s: unexpected error: %v", name, err)
+                }
+                // Test case: Searching while merges run in the background
+                if expectedPrefix, ok := prefixes[start+25]; ok {
+                    checkSameRanking(t, name, scorer, expectedPrefix[variant], identityRemap(start+25), queries[0], 10)
+                }
+            }
+            scorer.WaitForMerges()
+
+            // Test case: Merging keeps the number of segments small
+            if n := scorer.NumSegments(); n < 2 || n > 10 {
+                t.Errorf("%s: expected between 2 and 10 segments, but got %d", name, n)
+            }
+
+            // Test case: The segmented index scores like a single segment
+            for _, query := range queries {
+                scores, _ := scorer.GetScores(query)
+                expectedScores, _ := expected[variant].GetScores(query)
+                for docID := range scores {
+                    if scores[docID] != expectedScores[docID] {
+                        t.Errorf("%s %v: expected score %v for document %d, but got %v", name, query, expectedScores[docID], docID, scores[docID])
+                    }
+                }
+                for _, n := range []int{1, 10, 400} {
+                    checkSameRanking(t, name, scorer, expected[variant], identityRemap(len(corpus)), query, n)
+                }
+            }
+        }
+    }
+}
+
+func TestSegmentDeletes(t *testing.T) {
+    rng := rand.New(rand.NewSource(13))
+    corpus := randomCorpus(rng, 200)
+
+    var remaining []string
+    remap := make([]int, len(corpus))
+    for docID := range corpus {
+        if docID%7 == 3 || (docID >= 40 && docID < 60) {
+            remap[docID] = -1
+            continue
+        }
+        remap[docID] = len(remaining)
+        remaining = append(remaining, corpus[docID])
+    }
+
+    expected := newVariants(t, remaining)
+    for variant, scorer := range newVariants(t, corpus, bm25.WithMaxBufferedDocs(20), bm25.WithMergePolicy(bm25.NoMergePolicy{})) {
+        // Test case: NoMergePolicy keeps every flushed segment
+        if n := scorer.NumSegments(); n != 10 {
+            t.Errorf("%s: expected 10 segments, but got %d", variant, n)
+        }
+
+        for docID := range corpus {
+            if remap[docID] < 0 {
+                if err := scorer.DeleteDocument(docID); err != nil {
+                    t.Fatalf("%s: unexpected error: %v", variant, err)
+                }
+            }
+        }
+
+        // Test case: Deletes spread over several segments
+        for _, query := range [][]string{{"t1", "t5"}, {"d45", "d46", "t2"}} {
+            checkSameRanking(t, variant, scorer, expected[variant], remap, query, 15)
+        }
+
+        // Test case: Compacting drops the segment left without live documents
+        scorer.Compact()
+        if n := scorer.NumSegments(); n != 9 {
+            t.Errorf("%s: expected 9 segments after compaction, but got %d", variant, n)
+        }
+        for _, query := range [][]string{{"t1", "t5"}, {"d45", "d46", "t2"}} {
+            checkSameRanking(t, variant, scorer, expected[variant], identityRemap(len(remaining)), query, 15)
+        }
+    }
+}
+
+func TestCompactMerges(t *testing.T) {
+    rng := rand.New(rand.NewSource(19))
+    corpus := randomCorpus(rng, 220)
+    policy := bm25.TieredMergePolicy{SegmentsPerTier: 3, MaxMergeAtOnce: 3, FloorSegmentDocs: 10, MaxSegmentDocs: 1000}
+
+    var remaining []string
+    for docID := 0; docID < len(corpus); docID += 10 {
+        remaining = append(remaining, corpus[docID])
+    }
+
+    expected := newVariants(t, remaining)
+    for variant, scorer := range newVariants(t, corpus[:40], bm25.WithMaxBufferedDocs(100), bm25.WithMergePolicy(policy)) {
+        for _, docs := range [][]string{corpus[40:80], corpus[80:120], corpus[120:]} {
+            scorer.AddDocuments(docs...)
+        }
+        scorer.WaitForMerges()
+
+        // Test case: Segments of 40, 40, 40 and 100 documents fall in two
+        // tiers, neither of which is full
+        if n := scorer.NumSegments(); n != 4 {
+            t.Fatalf("%s: expected 4 segments, but got %d", variant, n)
+        }
+
+        for docID := range corpus {
+            if docID%10 != 0 {
+                if err := scorer.DeleteDocument(docID); err != nil {
+                    t.Fatalf("%s: unexpected error: %v", variant, err)
+                }
+            }
+        }
+
+        // Test case: Compacting shrinks the segments into the lowest tier,
+        // which the merge policy then merges
+        scorer.Compact()
+        scorer.WaitForMerges()
+        if n := scorer.NumSegments(); n != 2 {
+            t.Errorf("%s: expected 2 segments after compaction, but got %d", variant, n)
+        }
+        for _, query := range [][]string{{"t1", "t5"}, {"d0", "d100", "t2"}} {
+            checkSameRanking(t, variant, scorer, expected[variant], identityRemap(len(remaining)), query, 15)
+        }
+    }
+}
+
+func TestTieredMergePolicy(t *testing.T) {
+    policy := bm25.TieredMergePolicy{SegmentsPerTier: 3, MaxMergeAtOnce: 2, FloorSegmentDocs: 10, MaxSegmentDocs: 100}
+
+    // Test case: No tier holds more than SegmentsPerTier segments
+    if _, _, ok := policy.FindMerge([]int{10, 10, 10, 50}); ok {
+        t.Errorf("Expected no merge, but got one")
+    }
+
+    // Test case: The smallest run of the overfull tier is merged
+    start, end, ok := policy.FindMerge([]int{40, 10, 5, 10, 3})
+    if !ok || start != 3 || end != 5 {
+        t.Errorf("Expected to merge segments [3, 5), but got [%d, %d) %v", start, end, ok)
+    }
+
+    // Test case: Segments of other tiers break up runs
+    start, end, ok = policy.FindMerge([]int{5, 40, 5, 40, 5, 5})
+    if !ok || start != 4 || end != 6 {
+        t.Errorf("Expected to merge segments [4, 6), but got [%d, %d) %v", start, end, ok)
+    }
+
+    // Test case: Merged segments may not exceed MaxSegmentDocs
+    policy.FloorSegmentDocs = 100
+    if _, _, ok := policy.FindMerge([]int{60, 60, 60, 60}); ok {
+        t.Errorf("Expected no merge, but got one")
+    }
+
+    // Test case: Invalid policies are rejected
+    invalid := bm25.TieredMergePolicy{SegmentsPerTier: 1, MaxMergeAtOnce: 10, FloorSegmentDocs: 10, MaxSegmentDocs: 100}
+    if _, err := bm25.NewBM25Okapi([]string{"a b"}, nil, 1.2, 0.75, nil, bm25.WithMergePolicy(invalid)); err == nil {
+        t.Errorf("Expected an error for an invalid merge policy, but got nil")
+    }
+    if _, err := bm25.NewBM25Okapi([]string{"a b"}, nil, 1.2, 0.75, nil, bm25.WithMaxBufferedDocs(0)); err == nil {
+        t.Errorf("Expected an error for zero max buffered documents, but got nil")
+    }
+}
+
+// identityRemap maps every one of n document IDs to itself.
+func identityRemap(n int) []int {
+    remap := make([]int, n)
+    for i := range remap {
+        remap[i] = i
+    }
+    return remap
+}
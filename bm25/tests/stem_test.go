@@ -0,0 +1,105 @@
+package bm25_test
+
+import (
+    "reflect"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+func TestStemmers(t *testing.T) {
+    testCases := []struct {
+        name    string
+        stemmer bm25.Stemmer
+        words   map[string]string
+    }{
+        {"english", bm25.EnglishStemmer, map[string]string{
+            "running": "run", "runs": "run", "consistently": "consist", "knightly": "knight", "generously": "generous",
+            "hoping": "hope", "hopping": "hop", "cries": "cri", "ties": "tie", "gaps": "gap", "gas": "gas",
+            "generation": "generat", "skies": "sky", "dying": "die", "innings": "inning", "'owl's": "owl",
+            "controllable": "control", "relational": "relat", "decisiveness": "decis", "saying": "say", "by": "by",
+        }},
+        {"minimal english", bm25.MinimalEnglishStemmer, map[string]string{
+            "runs": "run", "running": "running", "queries": "query", "bus": "bus", "glass": "glass", "cats": "cat",
+            "toes": "toes", "days": "day", "is": "is",
+        }},
+        {"german", bm25.GermanStemmer, map[string]string{
+            "häuser": "haus", "katzen": "katz", "laufen": "lauf", "aufeinander": "aufeinand", "kenntnisse": "kenntnis",
+            "bedeutung": "bedeut", "häufigkeit": "haufig", "abschließend": "abschliess", "feststellungen": "feststell",
+        }},
+        {"french", bm25.FrenchStemmer, map[string]string{
+            "chevaux": "cheval", "abandonner": "abandon", "abandonnée": "abandon", "abondamment": "abond",
+            "continuellement": "continuel", "nationalité": "national", "parlaient": "parl", "principaux": "principal",
+            "activement": "activ",
+        }},
+        {"spanish", bm25.SpanishStemmer, map[string]string{
+            "corriendo": "corr", "chiquito": "chiquit", "toreros": "torer", "acción": "accion", "comiéndoselo": "com",
+            "rápidamente": "rapid", "felicidad": "felic", "arqueología": "arqueolog", "diferencia": "diferent",
+            "cantaríamos": "cant",
+        }},
+    }
+
+    // Test case: Words are reduced to the stems of the Snowball algorithms
+    for _, tc := range testCases {
+        for word, expected := range tc.words {
+            if stem := tc.stemmer(word); stem != expected {
+                t.Errorf("%s: expected %q to stem to %q, but got %q", tc.name, word, expected, stem)
+            }
+        }
+    }
+
+    // Test case: Stemmers are found by language name and code
+    for _, language := range []string{"english", "EN", "german", "de", "french", "fr", "spanish", "es"} {
+        if stemmer, err := bm25.SnowballStemmer(language); err != nil || stemmer == nil {
+            t.Errorf("Expected a stemmer for %q, but got error %v", language, err)
+        }
+    }
+    if _, err := bm25.SnowballStemmer("klingon"); err == nil {
+        t.Errorf("Expected an error for an unknown language, but got nil")
+    }
+
+    // Test case: The stem filter stems every token
+    tokens := bm25.StemFilter(bm25.EnglishStemmer)([]string{"running", "dogs", "quickly"})
+    if expected := []string{"run", "dog", "quick"}; !reflect.DeepEqual(tokens, expected) {
+        t.Errorf("Expected tokens %v, but got %v", expected, tokens)
+    }
+}
+
+func TestStemmingAnalyzer(t *testing.T) {
+    corpus := []string{
+        "She runs every morning",
+        "The weather is quite windy",
+        "Running shoes for sale",
+    }
+    analyzer := bm25.NewAnalyzer(bm25.WordTokenizer, bm25.LowercaseFilter, bm25.StemFilter(bm25.EnglishStemmer))
+
+    // Test case: The stemming analyzer plugs into the constructor as a
+    // tokenizer, and as an analyzer
+    plain, err := bm25.NewBM25Okapi(corpus, analyzer.Analyze, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    okapi, err := bm25.NewBM25Okapi(corpus, nil, 1.2, 0.75, nil, bm25.WithIDF(bm25.LuceneIDF), bm25.WithAnalyzer(analyzer))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if plain.DocFreq("run") != 2 || okapi.DocFreq("run") != 2 || okapi.DocFreq("runs") != 0 {
+        t.Errorf("Expected runs and running to be indexed as run")
+    }
+
+    // Test case: Queries stemmed the same way match every inflection
+    scores, err := okapi.GetScores(okapi.Analyze("running"))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if scores[0] <= 0 || scores[2] <= 0 || scores[1] != 0 {
+        t.Errorf("Expected running to match runs and running, but got scores %v", scores)
+    }
+    hits, err := okapi.SearchText("Runs", 3)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if len(hits) != 2 {
+        t.Errorf("Expected 2 hits for runs, but got %v", hits)
+    }
+}
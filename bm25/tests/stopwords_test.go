@@ -0,0 +1,159 @@
+package bm25_test
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+func TestStopwords(t *testing.T) {
+    // Test case: Built-in lists by language name and code
+    for _, language := range []string{"english", "en", "German", "de", "french", "fr", "spanish", "es", "italian", "it", "portuguese", "pt", "dutch", "nl"} {
+        stopwords, err := bm25.StopwordsFor(language)
+        if err != nil || len(stopwords) == 0 {
+            t.Errorf("Expected stopwords for %q, but got error %v", language, err)
+        }
+    }
+    english, _ := bm25.StopwordsFor("en")
+    if !english["the"] || !english["of"] || english["london"] {
+        t.Errorf("Expected the and of, but not london, to be English stopwords")
+    }
+    if german, _ := bm25.StopwordsFor("de"); !german["und"] || !german["für"] {
+        t.Errorf("Expected und and für to be German stopwords")
+    }
+    if _, err := bm25.StopwordsFor("klingon"); err == nil {
+        t.Errorf("Expected an error for an unknown language, but got nil")
+    }
+
+    // Test case: Each call returns a new set
+    english["london"] = true
+    if again, _ := bm25.StopwordsFor("en"); again["london"] {
+        t.Errorf("Expected changes to a returned set not to affect the built-in list")
+    }
+
+    // Test case: Lists are read with Lucene and Snowball comments
+    list := "# Lucene comment\nthe\nof | Snowball comment\n\n  and or  # two words\n"
+    stopwords, err := bm25.ReadStopwords(strings.NewReader(list))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if expected := bm25.NewStopwords("the", "of", "and", "or"); !reflect.DeepEqual(stopwords, expected) {
+        t.Errorf("Expected stopwords %v, but got %v", expected, stopwords)
+    }
+
+    // Test case: Lists are loaded from a file
+    path := filepath.Join(t.TempDir(), "stopwords.txt")
+    if err := os.WriteFile(path, []byte(list), 0o644); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    loaded, err := bm25.LoadStopwords(path)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if !reflect.DeepEqual(loaded, stopwords) {
+        t.Errorf("Expected stopwords %v, but got %v", stopwords, loaded)
+    }
+    if _, err := bm25.LoadStopwords(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+        t.Errorf("Expected an error for a missing file, but got nil")
+    }
+
+    // Test case: The stop filter drops stopwords
+    tokens := bm25.StopFilter(english)([]string{"the", "cat", "of", "the", "house"})
+    if expected := []string{"cat", "house"}; !reflect.DeepEqual(tokens, expected) {
+        t.Errorf("Expected tokens %v, but got %v", expected, tokens)
+    }
+
+    // Test case: Stopwords removed by the analyzer are not indexed
+    analyzer := bm25.NewAnalyzer(bm25.WordTokenizer, bm25.LowercaseFilter, bm25.StopFilter(english))
+    okapi, err := bm25.NewBM25Okapi([]string{"The cat", "The dog and the bird"}, nil, 1.2, 0.75, nil, bm25.WithAnalyzer(analyzer))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if okapi.DocFreq("the") != 0 || okapi.DocFreq("cat") != 1 {
+        t.Errorf("Expected the to be dropped and cat to be indexed")
+    }
+}
+
+func TestUnscoredStopwords(t *testing.T) {
+    // "the" is in every document, which gives it a negative IDF with
+    // RobertsonSparckJonesIDF and a zero IDF with ProbabilisticIDF.
+    corpus := []string{
+        "the cat sat on the mat",
+        "the dog ran far away",
+        "the bird and the cat",
+        "the fish",
+        "the the the cat",
+    }
+    stopwords := bm25.NewStopwords("the", "on", "and")
+    idfs := map[string]bm25.IDFFunc{
+        "Lucene":               bm25.LuceneIDF,
+        "RobertsonSparckJones": bm25.RobertsonSparckJonesIDF,
+        "Probabilistic":        bm25.ProbabilisticIDF,
+    }
+
+    for idfName, idf := range idfs {
+        for _, e := range evaluations {
+            plain := newVariants(t, corpus, bm25.WithIDF(idf), bm25.WithEvaluation(e))
+            dropped := newVariants(t, corpus, bm25.WithIDF(idf), bm25.WithEvaluation(e), bm25.WithAnalyzer(bm25.NewAnalyzer(bm25.WhitespaceTokenizer, bm25.StopFilter(stopwords))))
+            for variant, scorer := range newVariants(t, corpus, bm25.WithIDF(idf), bm25.WithEvaluation(e), bm25.WithUnscoredStopwords(stopwords)) {
+                name := idfName + " " + e.String() + " " + variant
+
+                // Test case: Stopwords stay in the index
+                if scorer.DocFreq("the") != len(corpus) {
+                    t.Errorf("%s: expected the to be indexed in every document", name)
+                }
+
+                // Test case: Stopwords contribute nothing to any score
+                scores, err := scorer.GetScores([]string{"the"})
+                if err != nil {
+                    t.Fatalf("%s: unexpected error: %v", name, err)
+                }
+                for docID, score := range scores {
+                    if score != 0 {
+                        t.Errorf("%s: expected score 0 for document %d, but got %v", name, docID, score)
+                    }
+                }
+                if hits, err := scorer.Search([]string{"the", "and"}, 5); err != nil || len(hits) != 0 {
+                    t.Errorf("%s: expected no hits for stopwords only, but got %v, %v", name, hits, err)
+                }
+
+                // Test case: A query with stopwords ranks like the query
+                // without them, and documents keep their lengths
+                want, _ := plain[variant].Search([]string{"cat"}, 5)
+                hits, err := scorer.Search([]string{"the", "cat", "on", "the"}, 5)
+                if err != nil {
+                    t.Fatalf("%s: unexpected error: %v", name, err)
+                }
+                if !reflect.DeepEqual(hits, want) {
+                    t.Errorf("%s: expected hits %v, but got %v", name, want, hits)
+                }
+                wantTop, _ := plain[variant].GetTopN([]string{"cat"}, 3)
+                if top, _ := scorer.GetTopN([]string{"the", "cat"}, 3); !reflect.DeepEqual(top, wantTop) {
+                    t.Errorf("%s: expected top documents %v, but got %v", name, wantTop, top)
+                }
+                wantScores, _ := plain[variant].GetBatchScores([]string{"cat"}, []int{0, 2, 4})
+                if batch, _ := scorer.GetBatchScores([]string{"the", "cat"}, []int{0, 2, 4}); !reflect.DeepEqual(batch, wantScores) {
+                    t.Errorf("%s: expected batch scores %v, but got %v", name, wantScores, batch)
+                }
+
+                // Test case: Stopwords add nothing to the scores but still
+                // count toward document lengths, unlike with StopFilter
+                if !reflect.DeepEqual(scorer.DocLengths(), plain[variant].DocLengths()) || scorer.AvgDocLen() != plain[variant].AvgDocLen() {
+                    t.Errorf("%s: expected document lengths %v, but got %v", name, plain[variant].DocLengths(), scorer.DocLengths())
+                }
+                wantScores, _ = plain[variant].GetScores([]string{"dog"})
+                scores, _ = scorer.GetScores([]string{"the", "dog", "the"})
+                if !reflect.DeepEqual(scores, wantScores) {
+                    t.Errorf("%s: expected scores %v, but got %v", name, wantScores, scores)
+                }
+                if droppedScores, _ := dropped[variant].GetScores([]string{"dog"}); droppedScores[1] == scores[1] {
+                    t.Errorf("%s: expected the stopwords of document 1 to change its score, but got %v with and without them", name, scores[1])
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,143 @@
+package bm25_test
+
+import (
+    "bytes"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+// collectTerms returns the terms enumerated by fn along with their document
+// frequencies.
+func collectTerms(fn func(func(term string, docFreq int) bool)) ([]string, map[string]int) {
+    var terms []string
+    docFreqs := make(map[string]int)
+    fn(func(term string, docFreq int) bool {
+        terms = append(terms, term)
+        docFreqs[term] = docFreq
+        return true
+    })
+    return terms, docFreqs
+}
+
+func TestTermDictionary(t *testing.T) {
+    // Thousands of terms with long shared prefixes, so that the dictionary is
+    // rebuilt several times while the corpus is indexed.
+    corpus := make([]string, 1500)
+    expected := make(map[string]int)
+    for docID := range corpus {
+        tokens := []string{"doc" + strconv.Itoa(docID), "prefix" + strconv.Itoa(docID%300), "shared"}
+        if docID%2 == 0 {
+            tokens = append(tokens, "pre"+strconv.Itoa(docID%7))
+        }
+        for _, token := range tokens {
+            expected[token]++
+        }
+        corpus[docID] = strings.Join(tokens, " ")
+    }
+
+    okapi, err := bm25.NewBM25Okapi(corpus, strings.Fields, 1.2, 0.75, nil, bm25.WithMaxBufferedDocs(400))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    // Test case: The whole vocabulary is enumerated in order with its
+    // document frequencies
+    terms, docFreqs := collectTerms(func(fn func(string, int) bool) { okapi.Terms("", fn) })
+    if !sort.StringsAreSorted(terms) {
+        t.Errorf("Expected terms in order")
+    }
+    if !reflect.DeepEqual(docFreqs, expected) {
+        t.Errorf("Expected %d terms with their document frequencies, but got %d", len(expected), len(docFreqs))
+    }
+    for _, term := range []string{"doc0", "doc1499", "prefix7", "pre3", "shared"} {
+        if okapi.DocFreq(term) != expected[term] {
+            t.Errorf("Expected document frequency %d for %q, but got %d", expected[term], term, okapi.DocFreq(term))
+        }
+    }
+
+    // Test case: Prefix enumeration
+    terms, _ = collectTerms(func(fn func(string, int) bool) { okapi.Terms("prefix29", fn) })
+    want := []string{"prefix29", "prefix290", "prefix291", "prefix292", "prefix293", "prefix294", "prefix295", "prefix296", "prefix297", "prefix298", "prefix299"}
+    if !reflect.DeepEqual(terms, want) {
+        t.Errorf("Expected terms %v, but got %v", want, terms)
+    }
+
+    // Test case: Range enumeration excludes the upper bound
+    terms, _ = collectTerms(func(fn func(string, int) bool) { okapi.TermRange("pre0", "pre4", fn) })
+    want = []string{"pre0", "pre1", "pre2", "pre3"}
+    if !reflect.DeepEqual(terms, want) {
+        t.Errorf("Expected terms %v, but got %v", want, terms)
+    }
+
+    // Test case: Enumeration stops when fn returns false
+    count := 0
+    okapi.Terms("doc", func(term string, docFreq int) bool {
+        count++
+        return count < 5
+    })
+    if count != 5 {
+        t.Errorf("Expected enumeration to stop after 5 terms, but got %d", count)
+    }
+
+    // Test case: A prefix matching no term enumerates nothing
+    terms, _ = collectTerms(func(fn func(string, int) bool) { okapi.Terms("zzz", fn) })
+    if len(terms) != 0 {
+        t.Errorf("Expected no terms, but got %v", terms)
+    }
+
+    // Test case: Added and deleted documents update the dictionary
+    if err := okapi.AddDocuments("doc1500 prefix1 fresh"); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if err := okapi.DeleteDocument(7); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    terms, _ = collectTerms(func(fn func(string, int) bool) { okapi.Terms("doc150", fn) })
+    want = []string{"doc150", "doc1500"}
+    if !reflect.DeepEqual(terms, want) {
+        t.Errorf("Expected terms %v, but got %v", want, terms)
+    }
+    if okapi.DocFreq("doc7") != 0 || okapi.DocFreq("fresh") != 1 || okapi.DocFreq("prefix1") != expected["prefix1"]+1 {
+        t.Errorf("Expected document frequencies to follow the changes")
+    }
+    if idf, _ := okapi.IDF("doc7"); idf != 0 {
+        t.Errorf("Expected IDF 0 for a deleted term, but got %v", idf)
+    }
+    terms, _ = collectTerms(func(fn func(string, int) bool) { okapi.TermRange("doc7", "doc70", fn) })
+    if len(terms) != 0 {
+        t.Errorf("Expected deleted term to leave the vocabulary, but got %v", terms)
+    }
+
+    // Test case: Saved, loaded and memory-mapped indexes enumerate the same
+    // terms
+    okapi.Compact()
+    all, allFreqs := collectTerms(func(fn func(string, int) bool) { okapi.Terms("", fn) })
+    var buf bytes.Buffer
+    if err := okapi.Save(&buf); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    loaded, err := bm25.Load(&buf, strings.Fields, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    mapped, err := bm25.OpenMapped(saveMapped(t, okapi), nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    defer mapped.Close()
+    for name, scorer := range map[string]bm25.BM25{"loaded": loaded, "mapped": mapped} {
+        terms, docFreqs := collectTerms(func(fn func(string, int) bool) { scorer.Terms("", fn) })
+        if !reflect.DeepEqual(terms, all) || !reflect.DeepEqual(docFreqs, allFreqs) {
+            t.Errorf("%s: expected the same vocabulary as the original index", name)
+        }
+        terms, _ = collectTerms(func(fn func(string, int) bool) { scorer.TermRange("pre0", "pre4", fn) })
+        if !reflect.DeepEqual(terms, []string{"pre0", "pre1", "pre2", "pre3"}) {
+            t.Errorf("%s: expected range enumeration to match, but got %v", name, terms)
+        }
+    }
+}
@@ -0,0 +1,68 @@
+package bm25_test
+
+import (
+    "reflect"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+func TestWordTokenizer(t *testing.T) {
+    testCases := []struct {
+        name     string
+        types    bm25.TokenType
+        text     string
+        expected []string
+    }{
+        // Test case: Punctuation and white space are dropped
+        {"punctuation", bm25.DefaultTokenTypes, "Hello there, good man!", []string{"Hello", "there", "good", "man"}},
+        // Test case: Apostrophes and number separators are kept by default
+        {"defaults", bm25.DefaultTokenTypes, "Don't pay O'Neil 1,000.50 for v1.2", []string{"Don't", "pay", "O'Neil", "1,000.50", "for", "v1.2"}},
+        // Test case: Without TokenApostrophes words are split at apostrophes
+        {"apostrophes", bm25.TokenNumbers, "don't pay 1'000", []string{"don", "t", "pay", "1'000"}},
+        // Test case: Without TokenNumbers numbers are split at separators
+        {"numbers", bm25.TokenApostrophes, "1,000.50 isn't 3.14", []string{"1", "000", "50", "isn't", "3", "14"}},
+        // Test case: Hyphenated words are split unless TokenHyphens is set
+        {"hyphens split", bm25.DefaultTokenTypes, "state-of-the-art", []string{"state", "of", "the", "art"}},
+        {"hyphens kept", bm25.DefaultTokenTypes | bm25.TokenHyphens, "state-of-the-art - a--b", []string{"state-of-the-art", "a", "b"}},
+        // Test case: E-mail addresses and URLs
+        {"emails split", bm25.DefaultTokenTypes, "mail jane.doe@example.com", []string{"mail", "jane.doe", "example.com"}},
+        {"emails kept", bm25.DefaultTokenTypes | bm25.TokenEmails, "mail jane.doe@example.com.", []string{"mail", "jane.doe@example.com"}},
+        {"urls kept", bm25.DefaultTokenTypes | bm25.TokenURLs | bm25.TokenEmails, "see (https://example.com/a?b=c), www.Go.dev!", []string{"see", "https://example.com/a?b=c", "www.Go.dev"}},
+        // Test case: Ideographs are single tokens, and Katakana and Thai runs
+        // are kept together
+        {"cjk", bm25.DefaultTokenTypes, "東京タワーに", []string{"東", "京", "タワー", "に"}},
+        {"thai", bm25.DefaultTokenTypes, "สวัสดีครับ world", []string{"สวัสดีครับ", "world"}},
+        // Test case: Combining marks and connector punctuation stay in words
+        {"marks", bm25.DefaultTokenTypes, "café foo_bar 🇺🇸", []string{"café", "foo_bar"}},
+        // Test case: Empty text
+        {"empty", bm25.DefaultTokenTypes, " ... ", nil},
+    }
+
+    for _, tc := range testCases {
+        tokens := bm25.NewWordTokenizer(tc.types)(tc.text)
+        if !reflect.DeepEqual(tokens, tc.expected) {
+            t.Errorf("%s: expected tokens %q, but got %q", tc.name, tc.expected, tokens)
+        }
+    }
+
+    // Test case: WordTokenizer uses the default token types
+    text := "Don't stop 1,000 times, jane@example.com"
+    if tokens, want := bm25.WordTokenizer(text), bm25.NewWordTokenizer(bm25.DefaultTokenTypes)(text); !reflect.DeepEqual(tokens, want) {
+        t.Errorf("Expected tokens %q, but got %q", want, tokens)
+    }
+
+    // Test case: The word tokenizer is accepted as a tokenizer function
+    corpus := []string{"Hello there good man!", "It is quite windy in London.", "How is the weather today?"}
+    okapi, err := bm25.NewBM25Okapi(corpus, bm25.WordTokenizer, 1.2, 0.75, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if okapi.DocFreq("man") != 1 || okapi.DocFreq("man!") != 0 || okapi.DocFreq("London") != 1 {
+        t.Errorf("Expected the corpus to be tokenized without punctuation")
+    }
+    analyzer := bm25.NewAnalyzer(bm25.NewWordTokenizer(bm25.DefaultTokenTypes|bm25.TokenHyphens), bm25.LowercaseFilter)
+    if tokens := analyzer.Analyze("Windy, rain-soaked LONDON."); !reflect.DeepEqual(tokens, []string{"windy", "rain-soaked", "london"}) {
+        t.Errorf("Expected analyzed tokens [windy rain-soaked london], but got %q", tokens)
+    }
+}
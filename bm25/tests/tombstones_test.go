@@ -0,0 +1,215 @@
+package bm25_test
+
+import (
+    "bytes"
+    "math/rand"
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+// checkSameRanking checks that scorer, whose document IDs map to those of
+// expected through remap, ranks like expected.
+func checkSameRanking(t *testing.T, name string, scorer, expected bm25.BM25, remap []int, query []string, n int) {
+    expectedHits, _ := expected.Search(query, n)
+    hits, err := scorer.Search(query, n)
+    if err != nil {
+        t.Fatalf("%s: unexpected error: %v", name, err)
+    }
+    if len(hits) != len(expectedHits) {
+        t.Fatalf("%s %v: expected %d hits, but got %d", name, query, len(expectedHits), len(hits))
+    }
+    for i := range hits {
+        if remap[hits[i].DocID] != expectedHits[i].DocID || hits[i].Score != expectedHits[i].Score {
+            t.Errorf("%s %v: expected hit %+v at rank %d, but got %+v", name, query, expectedHits[i], i, hits[i])
+        }
+    }
+
+    expectedDocs, _ := expected.GetTopN(query, n)
+    topDocs, _ := scorer.GetTopN(query, n)
+    if strings.Join(topDocs, "|") != strings.Join(expectedDocs, "|") {
+        t.Errorf("%s %v: expected top documents %v, but got %v", name, query, expectedDocs, topDocs)
+    }
+}
+
+func TestDeleteDocument(t *testing.T) {
+    rng := rand.New(rand.NewSource(5))
+    corpus := randomCorpus(rng, 300)
+
+    var remaining []string
+    remap := make([]int, len(corpus))
+    deleted := map[int]bool{}
+    for docID := range corpus {
+        if rng.Intn(3) == 0 {
+            deleted[docID] = true
+            remap[docID] = -1
+            continue
+        }
+        remap[docID] = len(remaining)
+        remaining = append(remaining, corpus[docID])
+    }
+
+    queries := [][]string{{"t1", "t3", "t20"}, {"t7"}, {"t2", "t2", "t400"}, {"d0", "d1", "d2"}}
+    expected := newVariants(t, remaining)
+    for _, e := range evaluations {
+        for variant, scorer := range newVariants(t, corpus, bm25.WithEvaluation(e)) {
+            name := e.String() + " " + variant
+            for docID := range deleted {
+                if err := scorer.DeleteDocument(docID); err != nil {
+                    t.Fatalf("%s: unexpected error: %v", name, err)
+                }
+            }
+
+            // Test case: Deleted documents leave the collection statistics
+            if scorer.CorpusSize() != len(remaining) {
+                t.Errorf("%s: expected corpus size %d, but got %d", name, len(remaining), scorer.CorpusSize())
+            }
+            if scorer.AvgDocLen() != expected["Okapi"].AvgDocLen() {
+                t.Errorf("%s: expected average length %.4f, but got %.4f", name, expected["Okapi"].AvgDocLen(), scorer.AvgDocLen())
+            }
+
+            // Test case: Deleted documents score zero and are never returned,
+            // not even to fill up the top N
+            for _, query := range queries {
+                scores, _ := scorer.GetScores(query)
+                expectedScores, _ := expected[variant].GetScores(query)
+                for docID, score := range scores {
+                    if remap[docID] < 0 && score != 0 {
+                        t.Errorf("%s %v: expected score 0 for deleted document %d, but got %v", name, query, docID, score)
+                    }
+                    if remap[docID] >= 0 && score != expectedScores[remap[docID]] {
+                        t.Errorf("%s %v: expected score %v for document %d, but got %v", name, query, expectedScores[remap[docID]], docID, score)
+                    }
+                }
+                for _, n := range []int{1, 10, 250} {
+                    checkSameRanking(t, name, scorer, expected[variant], remap, query, n)
+                }
+            }
+
+            // Test case: Compacting renumbers the documents and keeps the scores
+            compacted := scorer.Compact()
+            for docID := range compacted {
+                if compacted[docID] != remap[docID] {
+                    t.Fatalf("%s: expected document %d to move to %d, but got %d", name, docID, remap[docID], compacted[docID])
+                }
+            }
+            identity := make([]int, len(remaining))
+            for i := range identity {
+                identity[i] = i
+            }
+            for _, query := range queries {
+                checkSameRanking(t, name, scorer, expected[variant], identity, query, 10)
+            }
+        }
+    }
+}
+
+func TestDeleteDocumentErrors(t *testing.T) {
+    corpus := []string{"hello world", "this is a test"}
+    tokenizer := func(s string) []string { return strings.Split(s, " ") }
+    okapi, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil)
+
+    // Test case: Deleting an invalid document ID
+    if err := okapi.DeleteDocument(2); err == nil {
+        t.Errorf("Expected an error for an invalid document ID, but got nil")
+    }
+
+    // Test case: Deleting a document twice
+    if err := okapi.DeleteDocument(0); err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if err := okapi.DeleteDocument(0); err == nil {
+        t.Errorf("Expected an error for a deleted document, but got nil")
+    }
+
+    // Test case: Deleted documents cannot be fetched
+    if _, err := okapi.Document(0); err == nil {
+        t.Errorf("Expected an error for a deleted document, but got nil")
+    }
+
+    // Test case: Terms only found in deleted documents leave the vocabulary
+    if df := okapi.DocFreq("hello"); df != 0 {
+        t.Errorf("Expected document frequency 0, but got %d", df)
+    }
+
+    // Test case: The last live document cannot be deleted
+    if err := okapi.DeleteDocument(1); err == nil {
+        t.Errorf("Expected an error for deleting the last document, but got nil")
+    }
+}
+
+func TestDeleteDocumentAfterLoad(t *testing.T) {
+    corpus := []string{"hello world", "hello there world world", "this is a test", "a test of the world"}
+    expected, _ := bm25.NewBM25Okapi(corpus, strings.Fields, 1.2, 0.75, nil)
+    expected.DeleteDocument(1)
+
+    okapi, _ := bm25.NewBM25Okapi(corpus, strings.Fields, 1.2, 0.75, nil)
+    var buf bytes.Buffer
+    if err := okapi.Save(&buf); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    // Test case: Deleting a document after loading the index with a different
+    // tokenizer removes the terms it was indexed with from the statistics
+    upper := func(s string) []string { return strings.Fields(strings.ToUpper(s)) }
+    loaded, err := bm25.Load(&buf, upper, nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if err := loaded.DeleteDocument(1); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    var terms, expectedTerms []string
+    loaded.Terms("", func(term string, docFreq int) bool {
+        terms = append(terms, term)
+        return true
+    })
+    expected.Terms("", func(term string, docFreq int) bool {
+        expectedTerms = append(expectedTerms, term)
+        return true
+    })
+    if strings.Join(terms, " ") != strings.Join(expectedTerms, " ") {
+        t.Errorf("Expected terms %v, but got %v", expectedTerms, terms)
+    }
+    for _, term := range append(expectedTerms, "there") {
+        if loaded.DocFreq(term) != expected.DocFreq(term) || loaded.CollectionFreq(term) != expected.CollectionFreq(term) {
+            t.Errorf("Expected frequencies %d and %d for '%s', but got %d and %d", expected.DocFreq(term), expected.CollectionFreq(term), term, loaded.DocFreq(term), loaded.CollectionFreq(term))
+        }
+        idf, _ := loaded.IDF(term)
+        expectedIDF, _ := expected.IDF(term)
+        if idf != expectedIDF {
+            t.Errorf("Expected IDF %v for '%s', but got %v", expectedIDF, term, idf)
+        }
+    }
+}
+
+func TestUpdateDocument(t *testing.T) {
+    corpus := []string{"hello world", "this is a test", "hello there"}
+    tokenizer := strings.Fields
+    okapi, _ := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, bm25.WithExternalIDs([]string{"a", "b", "c"}))
+
+    // Test case: Updating with a document that tokenizes to an empty slice
+    if _, err := okapi.UpdateDocument(0, ""); err == nil {
+        t.Errorf("Expected an error for an empty document, but got nil")
+    }
+
+    // Test case: The new text gets a new ID and keeps the external ID
+    newID, err := okapi.UpdateDocument(0, "goodbye world")
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if newID != 3 {
+        t.Errorf("Expected new document ID 3, but got %d", newID)
+    }
+    if id, _ := okapi.ExternalID(newID); id != "a" {
+        t.Errorf("Expected external ID 'a', but got '%s'", id)
+    }
+
+    // Test case: The index matches one built from the updated corpus
+    expected, _ := bm25.NewBM25Okapi([]string{"this is a test", "hello there", "goodbye world"}, tokenizer, 1.2, 0.75, nil)
+    for _, query := range [][]string{{"hello"}, {"world", "goodbye"}, {"test"}} {
+        checkSameRanking(t, "Okapi", okapi, expected, []int{-1, 0, 1, 2}, query, 3)
+    }
+}
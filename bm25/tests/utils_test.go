@@ -1,6 +1,9 @@
 package bm25_test
 
 import (
+    "math/rand"
+    "sort"
+    "strings"
     "testing"
 
     "lenaxia/bm25_golang/bm25"
@@ -99,17 +102,89 @@ func TestTopNIndices(t *testing.T) {
     }
 }
 
+func TestTopNIndicesTieBreak(t *testing.T) {
+    // Test case: Ties are broken by ascending index
+    indices, err := bm25.TopNIndices([]float64{1.0, 2.0, 2.0, 0.0, 2.0}, 4)
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    expected := []int{1, 2, 4, 0}
+    if len(indices) != len(expected) {
+        t.Fatalf("Expected %d indices, but got %d", len(expected), len(indices))
+    }
+    for i, idx := range indices {
+        if idx != expected[i] {
+            t.Errorf("Expected index %d at position %d, but got %d", expected[i], i, idx)
+        }
+    }
+
+    // Test case: The heap selection agrees with a full stable sort
+    rng := rand.New(rand.NewSource(42))
+    scores := make([]float64, 5000)
+    for i := range scores {
+        scores[i] = float64(rng.Intn(50))
+    }
+    sorted := make([]int, len(scores))
+    for i := range sorted {
+        sorted[i] = i
+    }
+    sort.SliceStable(sorted, func(i, j int) bool { return scores[sorted[i]] > scores[sorted[j]] })
+    indices, _ = bm25.TopNIndices(scores, 100)
+    for i, idx := range indices {
+        if idx != sorted[i] {
+            t.Fatalf("Expected index %d at position %d, but got %d", sorted[i], i, idx)
+        }
+    }
+}
+
+func TestTopNNonZeroIndices(t *testing.T) {
+    // Test case: Getting top N non-zero indices for n <= 0
+    _, err := bm25.TopNNonZeroIndices([]float64{1.0, 2.0}, 0)
+    if err == nil {
+        t.Errorf("Expected an error for n <= 0, but got nil")
+    }
+
+    // Test case: Zero scores are skipped, negative scores are kept
+    indices, err := bm25.TopNNonZeroIndices([]float64{0.0, 2.0, 0.0, -1.0, 3.0}, 5)
+    if err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    expected := []int{4, 1, 3}
+    if len(indices) != len(expected) {
+        t.Fatalf("Expected %d indices, but got %d", len(expected), len(indices))
+    }
+    for i, idx := range indices {
+        if idx != expected[i] {
+            t.Errorf("Expected index %d at position %d, but got %d", expected[i], i, idx)
+        }
+    }
+}
+
+func BenchmarkTopNIndices(b *testing.B) {
+    rng := rand.New(rand.NewSource(1))
+    scores := make([]float64, 1000000)
+    for i := range scores {
+        scores[i] = rng.Float64()
+    }
+
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        bm25.TopNIndices(scores, 10)
+    }
+}
+
 func TestJoinTokens(t *testing.T) {
     // Test case: Joining an empty slice
-    joined := bm25.JoinTokens([]string{})
+    joined := bm25.JoinTokens([]string{}, " ")
     if joined != "" {
         t.Errorf("Expected an empty string, but got '%s'", joined)
     }
 
-    // Test case: Joining a slice with empty strings
-    joined = bm25.JoinTokens([]string{"", "", ""})
-    if joined != " " {
-        t.Errorf("Expected a single space, but got '%s'", joined)
+    // Test case: Joining a slice with empty strings keeps the separator
+    // between every pair of tokens, as strings.Join does
+    joined = bm25.JoinTokens([]string{"", "", ""}, " ")
+    if joined != "  " {
+        t.Errorf("Expected two spaces, but got '%s'", joined)
     }
 
     // Test case: Joining a slice with different separators
@@ -0,0 +1,153 @@
+package bm25_test
+
+import (
+    "fmt"
+    "math/rand"
+    "strings"
+    "testing"
+
+    "lenaxia/bm25_golang/bm25"
+)
+
+// randomCorpus returns a corpus of the given size whose terms follow a Zipf
+// distribution, so that queries mix frequent and rare terms. Every document
+// starts with a unique token so that documents can be told apart by text.
+func randomCorpus(rng *rand.Rand, size int) []string {
+    zipf := rand.NewZipf(rng, 1.1, 1, 500)
+    corpus := make([]string, size)
+    for i := range corpus {
+        tokens := []string{fmt.Sprintf("d%d", i)}
+        for j := rng.Intn(40); j >= 0; j-- {
+            tokens = append(tokens, fmt.Sprintf("t%d", zipf.Uint64()))
+        }
+        corpus[i] = strings.Join(tokens, " ")
+    }
+    return corpus
+}
+
+// newVariants builds every BM25 variant over the corpus.
+func newVariants(t testing.TB, corpus []string, opts ...bm25.Option) map[string]bm25.BM25 {
+    tokenizer := strings.Fields
+    okapi, err := bm25.NewBM25Okapi(corpus, tokenizer, 1.2, 0.75, nil, opts...)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    l, err := bm25.NewBM25L(corpus, tokenizer, 1.2, 0.75, 0.5, nil, opts...)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    plus, err := bm25.NewBM25Plus(corpus, tokenizer, 1.2, 0.75, 1.0, 0.25, nil, opts...)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    adpt, err := bm25.NewBM25Adpt(corpus, tokenizer, 1.2, 0.75, 0.5, nil, opts...)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    bm25t, err := bm25.NewBM25T(corpus, tokenizer, 1.2, 0.75, nil, opts...)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    return map[string]bm25.BM25{"Okapi": okapi, "L": l, "Plus": plus, "Adpt": adpt, "T": bm25t}
+}
+
+// checkTopN compares GetTopN and Search against exhaustive scoring.
+func checkTopN(t *testing.T, name string, scorer bm25.BM25, corpus []string, query []string, n int) {
+    scores, err := scorer.GetScores(query)
+    if err != nil {
+        t.Fatalf("%s: unexpected error: %v", name, err)
+    }
+
+    indices, _ := bm25.TopNIndices(scores, n)
+    topDocs, err := scorer.GetTopN(query, n)
+    if err != nil {
+        t.Fatalf("%s: unexpected error: %v", name, err)
+    }
+    if len(topDocs) != len(indices) {
+        t.Fatalf("%s %v top %d: expected %d documents, but got %d", name, query, n, len(indices), len(topDocs))
+    }
+    for i, idx := range indices {
+        if topDocs[i] != corpus[idx] {
+            t.Errorf("%s %v top %d: expected document %d at rank %d, but got '%s'", name, query, n, idx, i, topDocs[i])
+        }
+    }
+
+    // Search leaves out the documents without any query term, which score
+    // the query floor of BM25Plus rather than zero.
+    floor := queryFloor(scores, corpus, query)
+    matching := make([]float64, len(scores))
+    for i, score := range scores {
+        if score != floor {
+            matching[i] = score
+        }
+    }
+    indices, _ = bm25.TopNNonZeroIndices(matching, n)
+    hits, err := scorer.Search(query, n)
+    if err != nil {
+        t.Fatalf("%s: unexpected error: %v", name, err)
+    }
+    if len(hits) != len(indices) {
+        t.Fatalf("%s %v search %d: expected %d hits, but got %d", name, query, n, len(indices), len(hits))
+    }
+    for i, idx := range indices {
+        if hits[i].DocID != idx || hits[i].Score != scores[idx] {
+            t.Errorf("%s %v search %d: expected hit {%d %v} at rank %d, but got %+v", name, query, n, idx, scores[idx], i, hits[i])
+        }
+    }
+}
+
+// queryFloor returns the score of the live documents that contain none of the
+// query terms, which is zero for every variant but BM25Plus.
+func queryFloor(scores []float64, corpus []string, query []string) float64 {
+    for docID, doc := range corpus {
+        if scores[docID] == 0 {
+            continue
+        }
+        matches := false
+        for _, token := range strings.Fields(doc) {
+            for _, q := range query {
+                matches = matches || token == q
+            }
+        }
+        if !matches {
+            return scores[docID]
+        }
+    }
+    return 0
+}
+
+func TestWANDMatchesExhaustiveScoring(t *testing.T) {
+    rng := rand.New(rand.NewSource(42))
+    corpus := randomCorpus(rng, 500)
+    variants := newVariants(t, corpus)
+
+    // Test case: Random queries of common and rare terms, including repeated
+    // and unknown terms, return the same ranking as exhaustive scoring
+    for q := 0; q < 50; q++ {
+        query := randomQuery(rng)
+        for name, scorer := range variants {
+            for _, n := range []int{1, 10, 100, 1000} {
+                checkTopN(t, name, scorer, corpus, query, n)
+            }
+        }
+    }
+
+    // Test case: Fewer matching documents than requested are padded with
+    // non-matching documents in document order
+    for name, scorer := range variants {
+        checkTopN(t, name, scorer, corpus, []string{"d7"}, 5)
+    }
+}
+
+func TestWANDNegativeIDF(t *testing.T) {
+    rng := rand.New(rand.NewSource(7))
+    corpus := randomCorpus(rng, 200)
+
+    // Test case: Terms with a negative IDF fall back to exhaustive scoring
+    variants := newVariants(t, corpus, bm25.WithIDF(bm25.RobertsonSparckJonesIDF))
+    for name, scorer := range variants {
+        for _, n := range []int{1, 10, 300} {
+            checkTopN(t, name, scorer, corpus, []string{"t1", "t2", "t50"}, n)
+        }
+    }
+}
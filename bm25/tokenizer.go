@@ -0,0 +1,209 @@
+package bm25
+
+import (
+    "regexp"
+    "sort"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// TokenType is a set of kinds of tokens that the word tokenizer keeps whole
+// rather than splitting at their punctuation. Token types are combined with
+// the | operator.
+type TokenType uint
+
+const (
+    // TokenApostrophes keeps words with inner apostrophes, such as "don't"
+    // and "O'Neil", whole, as UAX #29 does. Without it they are split at the
+    // apostrophes.
+    TokenApostrophes TokenType = 1 << iota
+
+    // TokenHyphens keeps hyphenated words, such as "state-of-the-art", whole.
+    // UAX #29 splits them at the hyphens.
+    TokenHyphens
+
+    // TokenNumbers keeps numbers with separators, such as "1,000.50" and
+    // "3.14", whole, as UAX #29 does. Without it they are split at the
+    // separators.
+    TokenNumbers
+
+    // TokenEmails keeps e-mail addresses, such as "jane.doe@example.com",
+    // whole.
+    TokenEmails
+
+    // TokenURLs keeps URLs, such as "https://example.com/a?b=c" and
+    // "www.example.com", whole.
+    TokenURLs
+)
+
+// DefaultTokenTypes are the token types of WordTokenizer: the word
+// boundaries of UAX #29 without additions.
+const DefaultTokenTypes = TokenApostrophes | TokenNumbers
+
+var (
+    urlPattern   = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.\-]*://|www\.)[^\s<>"]+`)
+    emailPattern = regexp.MustCompile(`[\p{L}\p{N}._%+\-]+@[\p{L}\p{N}\-]+(?:\.[\p{L}\p{N}\-]+)+`)
+)
+
+// defaultWordTokenizer is the tokenizer returned by WordTokenizer.
+var defaultWordTokenizer = NewWordTokenizer(DefaultTokenTypes)
+
+// WordTokenizer splits text into words at the word boundaries of Unicode
+// Standard Annex #29, dropping white space and punctuation. It keeps words
+// with inner apostrophes and numbers with separators whole. Ideographs are
+// returned one per token, and runs of letters of scripts written without
+// spaces, such as Thai, as a single token.
+//
+// Tokens are returned as they appear in the text; use LowercaseFilter to
+// lowercase them.
+func WordTokenizer(text string) []string {
+    return defaultWordTokenizer(text)
+}
+
+// NewWordTokenizer returns a tokenizer that splits text like WordTokenizer,
+// keeping the given token types whole.
+func NewWordTokenizer(types TokenType) Tokenizer {
+    return func(text string) []string {
+        return tokenizeWords(text, types)
+    }
+}
+
+// tokenizeWords returns the words of the text, keeping the given token types
+// whole.
+func tokenizeWords(text string, types TokenType) []string {
+    var tokens []string
+    last := 0
+    for _, span := range specialSpans(text, types) {
+        tokens = appendWords(tokens, text[last:span[0]], types)
+        tokens = append(tokens, text[span[0]:span[1]])
+        last = span[1]
+    }
+    return appendWords(tokens, text[last:], types)
+}
+
+// specialSpans returns the byte offsets of the e-mail addresses and URLs of
+// the text that the token types keep whole, in order and without overlaps.
+func specialSpans(text string, types TokenType) [][]int {
+    var spans [][]int
+    if types&TokenURLs != 0 && (strings.Contains(text, "://") || containsFold(text, "www.")) {
+        for _, span := range urlPattern.FindAllStringIndex(text, -1) {
+            // Trailing punctuation belongs to the sentence, not the URL.
+            span[1] = span[0] + len(strings.TrimRight(text[span[0]:span[1]], ".,;:!?'\")]}"))
+            spans = append(spans, span)
+        }
+    }
+    if types&TokenEmails != 0 && strings.Contains(text, "@") {
+        spans = append(spans, emailPattern.FindAllStringIndex(text, -1)...)
+    }
+    if len(spans) < 2 {
+        return spans
+    }
+
+    // URLs come before e-mail addresses starting at the same offset, so the
+    // stable sort lets them win.
+    sort.SliceStable(spans, func(i, j int) bool {
+        return spans[i][0] < spans[j][0]
+    })
+    kept := spans[:1]
+    for _, span := range spans[1:] {
+        if span[0] >= kept[len(kept)-1][1] {
+            kept = append(kept, span)
+        }
+    }
+    return kept
+}
+
+// containsFold reports whether substr, which must be lowercase ASCII, is in s
+// regardless of case.
+func containsFold(s, substr string) bool {
+    for i := 0; i+len(substr) <= len(s); i++ {
+        if strings.EqualFold(s[i:i+len(substr)], substr) {
+            return true
+        }
+    }
+    return false
+}
+
+// appendWords appends the words of the text to tokens, applying the token
+// types that change the UAX #29 word boundaries.
+func appendWords(tokens []string, text string, types TokenType) []string {
+    if text == "" {
+        return tokens
+    }
+
+    // Collect the word segments, joining those separated by a single hyphen
+    // when hyphenated words are kept.
+    var words [][2]int
+    wordSegments(text, func(start, end int) {
+        if !isWord(text[start:end]) {
+            return
+        }
+        if n := len(words); n > 0 && types&TokenHyphens != 0 && isHyphen(text[words[n-1][1]:start]) {
+            words[n-1][1] = end
+            return
+        }
+        words = append(words, [2]int{start, end})
+    })
+
+    for _, word := range words {
+        token := text[word[0]:word[1]]
+        if types&TokenApostrophes != 0 && types&TokenNumbers != 0 {
+            tokens = append(tokens, token)
+            continue
+        }
+        tokens = appendSplit(tokens, token, types)
+    }
+    return tokens
+}
+
+// isWord reports whether a segment is a word, that is, whether it has a
+// letter or a number.
+func isWord(segment string) bool {
+    for _, r := range segment {
+        if unicode.IsLetter(r) || unicode.IsNumber(r) {
+            return true
+        }
+    }
+    return false
+}
+
+// isHyphen reports whether the text between two words is a single hyphen.
+func isHyphen(s string) bool {
+    r, size := utf8.DecodeRuneInString(s)
+    return size == len(s) && (r == '-' || r == 0x2010 || r == 0x2011)
+}
+
+// isApostrophe reports whether the rune is an apostrophe.
+func isApostrophe(r rune) bool {
+    return r == '\'' || r == 0x2019 || r == 0xFF07
+}
+
+// appendSplit appends the parts of a word to tokens, splitting it at the
+// apostrophes between letters unless TokenApostrophes is set, and at the
+// separators between digits unless TokenNumbers is set.
+func appendSplit(tokens []string, word string, types TokenType) []string {
+    start := 0
+    prev := rune(-1)
+    for i, r := range word {
+        if i == start || i+utf8.RuneLen(r) >= len(word) {
+            prev = r
+            continue
+        }
+        next, _ := utf8.DecodeRuneInString(word[i+utf8.RuneLen(r):])
+        betweenDigits := unicode.IsDigit(prev) && unicode.IsDigit(next)
+        split := false
+        switch prop := wordBreakProperty(r); {
+        case betweenDigits:
+            split = types&TokenNumbers == 0 && (prop == wbMidNum || isMidNumLetQ(prop))
+        case isApostrophe(r):
+            split = types&TokenApostrophes == 0
+        }
+        if split {
+            tokens = append(tokens, word[start:i])
+            start = i + utf8.RuneLen(r)
+        }
+        prev = r
+    }
+    return append(tokens, word[start:])
+}
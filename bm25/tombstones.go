@@ -0,0 +1,248 @@
+package bm25
+
+import (
+    "errors"
+    "strconv"
+)
+
+// bitmap is a set of document IDs.
+type bitmap []uint64
+
+// set adds the document ID to the set, growing it as needed.
+func (m *bitmap) set(docID int) {
+    for len(*m) <= docID/64 {
+        *m = append(*m, 0)
+    }
+    (*m)[docID/64] |= 1 << (uint(docID) % 64)
+}
+
+// has reports whether the document ID is in the set.
+func (m bitmap) has(docID int) bool {
+    return docID/64 < len(m) && m[docID/64]&(1<<(uint(docID)%64)) != 0
+}
+
+// termParamsResetter is implemented by the variants that cache per-term
+// parameters derived from the collection statistics.
+type termParamsResetter interface {
+    resetTermParams()
+}
+
+// maxDoc returns the number of document slots in the index, including the
+// slots of deleted documents that were not compacted yet.
+func (b *bm25Base) maxDoc() int {
+    return b.docLengths.len()
+}
+
+// isDeleted reports whether the document with the given ID was deleted.
+func (b *bm25Base) isDeleted(docID int) bool {
+    return b.deleted.has(docID)
+}
+
+// statsChanged recomputes the average document length after the live
+// documents changed and drops everything derived from the old collection
+// statistics: the IDF floor, the score bounds and the per-term parameters of
+// the variant, which are recomputed on demand.
+func (b *bm25Base) statsChanged() {
+    b.avgDocLen = float64(b.totalDocLen) / float64(b.corpusSize)
+    if b.floorIDF {
+        b.computeIDFFloor()
+    }
+    for _, seg := range b.segmentsSnapshot() {
+        seg.resetBounds()
+    }
+    if r, ok := b.scorer.(termParamsResetter); ok {
+        r.resetTermParams()
+    }
+}
+
+// checkLive returns an error unless docID refers to a live document.
+func (b *bm25Base) checkLive(docID int) error {
+    if docID < 0 || docID >= b.maxDoc() {
+        return errors.New("invalid document ID: " + strconv.Itoa(docID))
+    }
+    if b.isDeleted(docID) {
+        return errors.New("document " + strconv.Itoa(docID) + " has been deleted")
+    }
+    return nil
+}
+
+// tombstone marks the document as deleted and removes it from the collection
+// statistics. Its postings stay in their segment until the index is compacted.
+// The terms of the document are read from its postings rather than from its
+// text, as the tokenizer or the analyzer may differ from the one the document
+// was indexed with, for instance after Load.
+func (b *bm25Base) tombstone(docID int) {
+    // A term whose every posting is deleted drops out of the vocabulary.
+    segmentOf(b.segmentsSnapshot(), docID).forEachDocTerm(docID, func(term string, freq int) {
+        b.vocab.add(term, -1, -freq)
+    })
+    b.vocab.maybeRebuild()
+
+    b.deleted.set(docID)
+    b.numDeleted++
+    b.corpusSize--
+    b.totalDocLen -= b.docLengths.at(docID)
+}
+
+// DeleteDocument deletes the document with the given ID. The document is only
+// marked as deleted: it no longer matches any query and no longer counts in the
+// collection statistics, but its ID is not reused and the space it takes is
+// reclaimed by Compact. The last live document cannot be deleted.
+func (b *bm25Base) DeleteDocument(docID int) error {
+    if b.file != nil {
+        return ErrReadOnly
+    }
+
+    if err := b.checkLive(docID); err != nil {
+        return err
+    }
+
+    if b.corpusSize == 1 {
+        return errors.New("cannot delete the last document of the corpus")
+    }
+
+    b.tombstone(docID)
+    b.statsChanged()
+
+    if b.logger != nil {
+        b.logger.Printf("Deleted document %d, Corpus size: %d, Average document length: %.2f", docID, b.corpusSize, b.avgDocLen)
+    }
+
+    return nil
+}
+
+// UpdateDocument replaces the text of the document with the given ID. The old
+// document is deleted and the new text is added as a new document, which keeps
+// the external ID of the old one. It returns the ID of the new document.
+func (b *bm25Base) UpdateDocument(docID int, doc string) (int, error) {
+    if b.file != nil {
+        return 0, ErrReadOnly
+    }
+
+    if err := b.checkLive(docID); err != nil {
+        return 0, err
+    }
+
+    tokens := b.tokenizer(doc)
+    if len(tokens) == 0 {
+        return 0, errors.New("tokenizer function returned an empty slice for the document")
+    }
+
+    b.tombstone(docID)
+    newID := b.maxDoc()
+    segs := b.flush([][]string{tokens})
+    b.docs = append(b.docs, doc)
+    if b.externalIDs != nil {
+        b.externalIDs = append(b.externalIDs, b.externalIDs[docID])
+    }
+    b.corpusSize++
+    b.statsChanged()
+    b.addSegments(segs...)
+
+    if b.logger != nil {
+        b.logger.Printf("Updated document %d as document %d", docID, newID)
+    }
+
+    return newID, nil
+}
+
+// Compact physically removes the deleted documents from the index and
+// renumbers the remaining ones, keeping their order. It returns, for every old
+// document ID, the new ID of the document, or -1 if it was deleted. Scores are
+// unchanged by compaction. Compact waits for the running merges and rewrites
+// every segment, dropping the segments left without live documents. The
+// merge policy is then applied to the smaller segments, in the background.
+func (b *bm25Base) Compact() []int {
+    b.WaitForMerges()
+
+    remap := make([]int, b.maxDoc())
+    live := 0
+    for docID := range remap {
+        if b.isDeleted(docID) {
+            remap[docID] = -1
+            continue
+        }
+        remap[docID] = live
+        live++
+    }
+
+    if b.numDeleted == 0 {
+        return remap
+    }
+
+    // Copy into new slices so that the space taken by the deleted documents is
+    // released.
+    docs := make([]string, 0, live)
+    docLengths := make([]int, 0, live)
+    for docID, newID := range remap {
+        if newID >= 0 {
+            docs = append(docs, b.docs[docID])
+            docLengths = append(docLengths, b.docLengths.at(docID))
+        }
+    }
+    if b.externalIDs != nil {
+        externalIDs := make([]string, 0, live)
+        for docID, newID := range remap {
+            if newID >= 0 {
+                externalIDs = append(externalIDs, b.externalIDs[docID])
+            }
+        }
+        b.externalIDs = externalIDs
+    }
+    b.docs = docs
+    b.docLengths = docLengthTable{lengths: docLengths}
+
+    // Segments keep covering contiguous ranges, as the remapping keeps the
+    // order of the documents. The new segments come with empty bounds, as the
+    // postings moved between blocks.
+    b.segMu.Lock()
+    segments := make([]*segment, 0, len(b.segments))
+    for _, seg := range b.segments {
+        compacted := compactSegment(seg, remap, b.codec)
+        if compacted.numDocs > 0 {
+            segments = append(segments, compacted)
+        }
+    }
+    b.segments = segments
+    b.segMu.Unlock()
+
+    // The compacted segments may be small enough for the merge policy to
+    // merge them.
+    b.addSegments()
+
+    if b.logger != nil {
+        b.logger.Printf("Compacted %d deleted documents, Corpus size: %d", b.numDeleted, b.corpusSize)
+    }
+
+    b.deleted = nil
+    b.numDeleted = 0
+
+    return remap
+}
+
+// compactSegment returns a copy of the segment without the postings of deleted
+// documents, renumbered through remap and compressed with the given codec.
+func compactSegment(seg *segment, remap []int, codec PostingCodec) *segment {
+    base := 0
+    for docID := seg.base - 1; docID >= 0; docID-- {
+        if remap[docID] >= 0 {
+            base = remap[docID] + 1
+            break
+        }
+    }
+    compacted := newSegmentBuilder(base)
+    for docID := seg.base; docID < seg.base+seg.numDocs; docID++ {
+        if remap[docID] >= 0 {
+            compacted.seg.numDocs++
+        }
+    }
+
+    seg.forEachPostingList(func(term string, p *postingList) {
+        for it := p.iterator(); it.valid(); it.next() {
+            if newID := remap[it.doc()]; newID >= 0 {
+                compacted.addPosting(term, newID, it.freq())
+            }
+        }
+    })
+    return compacted.build(codec)
+}
@@ -0,0 +1,100 @@
+package bm25
+
+import "sort"
+
+// scoredDoc is a document ID together with its score.
+type scoredDoc struct {
+    docID int
+    score float64
+}
+
+// ranksBefore reports whether a ranks before b: higher scores first, with ties
+// broken by ascending document ID.
+func (a scoredDoc) ranksBefore(b scoredDoc) bool {
+    if a.score != b.score {
+        return a.score > b.score
+    }
+    return a.docID < b.docID
+}
+
+// topKHeap keeps the k best documents offered to it in a bounded min-heap,
+// whose root is the worst of the documents kept so far.
+type topKHeap struct {
+    k    int
+    docs []scoredDoc
+}
+
+// newTopKHeap creates a heap keeping at most k documents.
+func newTopKHeap(k int) *topKHeap {
+    return &topKHeap{k: k, docs: make([]scoredDoc, 0, k)}
+}
+
+// full reports whether the heap holds k documents.
+func (h *topKHeap) full() bool {
+    return len(h.docs) == h.k
+}
+
+// worst returns the lowest ranked document kept so far. It must only be
+// called on a non-empty heap.
+func (h *topKHeap) worst() scoredDoc {
+    return h.docs[0]
+}
+
+// offer adds the document if it ranks among the k best seen so far and
+// reports whether it was kept.
+func (h *topKHeap) offer(docID int, score float64) bool {
+    d := scoredDoc{docID: docID, score: score}
+    if len(h.docs) < h.k {
+        h.docs = append(h.docs, d)
+        h.up(len(h.docs) - 1)
+        return true
+    }
+
+    if h.k == 0 || !d.ranksBefore(h.docs[0]) {
+        return false
+    }
+    h.docs[0] = d
+    h.down(0)
+    return true
+}
+
+// up restores the heap order from position i towards the root.
+func (h *topKHeap) up(i int) {
+    for i > 0 {
+        parent := (i - 1) / 2
+        if !h.docs[parent].ranksBefore(h.docs[i]) {
+            break
+        }
+        h.docs[parent], h.docs[i] = h.docs[i], h.docs[parent]
+        i = parent
+    }
+}
+
+// down restores the heap order from position i towards the leaves.
+func (h *topKHeap) down(i int) {
+    n := len(h.docs)
+    for {
+        worst := i
+        left, right := 2*i+1, 2*i+2
+        if left < n && h.docs[worst].ranksBefore(h.docs[left]) {
+            worst = left
+        }
+        if right < n && h.docs[worst].ranksBefore(h.docs[right]) {
+            worst = right
+        }
+        if worst == i {
+            return
+        }
+        h.docs[i], h.docs[worst] = h.docs[worst], h.docs[i]
+        i = worst
+    }
+}
+
+// sorted returns the kept documents from best to worst.
+func (h *topKHeap) sorted() []scoredDoc {
+    docs := append([]scoredDoc(nil), h.docs...)
+    sort.Slice(docs, func(i, j int) bool {
+        return docs[i].ranksBefore(docs[j])
+    })
+    return docs
+}
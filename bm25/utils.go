@@ -2,7 +2,6 @@ package bm25
 
 import (
     "errors"
-    "sort"
     "strings"
 )
 
@@ -31,21 +30,39 @@ func CountTermFreq(term string, doc string, tokenizer func(string) []string) (in
 }
 
 // TopNIndices returns the indices of the top N scores in the given slice.
+// Scores are ranked from highest to lowest, with ties broken by ascending
+// index. Selection uses a bounded heap and runs in O(len(scores) log n).
 func TopNIndices(scores []float64, n int) ([]int, error) {
+    return topNIndices(scores, n, false)
+}
+
+// TopNNonZeroIndices is like TopNIndices but leaves out zero scores, which
+// belong to documents that match none of the query terms.
+func TopNNonZeroIndices(scores []float64, n int) ([]int, error) {
+    return topNIndices(scores, n, true)
+}
+
+// topNIndices selects the top N scores, optionally skipping zero scores.
+func topNIndices(scores []float64, n int, skipZero bool) ([]int, error) {
     if n <= 0 {
         return nil, errors.New("n must be a positive integer")
     }
 
-    indices := make([]int, len(scores))
-    for i := range indices {
-        indices[i] = i
+    h := newTopKHeap(Min(n, len(scores)))
+    for i, score := range scores {
+        if skipZero && score == 0 {
+            continue
+        }
+        h.offer(i, score)
     }
 
-    sort.Slice(indices, func(i, j int) bool {
-        return scores[indices[i]] > scores[indices[j]]
-    })
+    docs := h.sorted()
+    indices := make([]int, len(docs))
+    for i, d := range docs {
+        indices[i] = d.docID
+    }
 
-    return indices[:Min(n, len(indices))], nil
+    return indices, nil
 }
 
 // JoinTokens joins the tokens in a document into a single string using the provided separator.
@@ -0,0 +1,137 @@
+package bm25
+
+import "sort"
+
+// minVocabularyOverlay is the number of changed terms a vocabulary holds in
+// its overlay before the overlay is merged into the dictionary, however small
+// the dictionary.
+const minVocabularyOverlay = 1024
+
+// termFreqs holds the collection statistics of a term.
+type termFreqs struct {
+    docFreq  int
+    collFreq int
+}
+
+// vocabulary holds the collection statistics of the terms of an in-memory
+// index. The bulk of the terms live in an immutable front-coded dictionary,
+// with their statistics in arrays indexed by ordinal. Terms added or changed
+// since the dictionary was built are kept in a small overlay map, which is
+// merged into a new dictionary once it holds more than an eighth of the
+// terms, so that updates stay cheap while the overlay stays small.
+type vocabulary struct {
+    dict      *termDict
+    docFreqs  []uint32
+    collFreqs []uint64
+    overlay   map[string]termFreqs
+    size      int
+}
+
+// newVocabulary creates an empty vocabulary.
+func newVocabulary() *vocabulary {
+    return &vocabulary{dict: newTermDict(nil), overlay: make(map[string]termFreqs)}
+}
+
+// stats returns the statistics of the term, and whether it is in the
+// vocabulary.
+func (v *vocabulary) stats(term string) (termFreqs, bool) {
+    if f, ok := v.overlay[term]; ok {
+        return f, f.docFreq > 0
+    }
+    if ord, ok := v.dict.lookup(term); ok {
+        return termFreqs{docFreq: int(v.docFreqs[ord]), collFreq: int(v.collFreqs[ord])}, true
+    }
+    return termFreqs{}, false
+}
+
+// add adds to the statistics of the term. A term whose document frequency
+// drops to zero leaves the vocabulary.
+func (v *vocabulary) add(term string, docFreq, collFreq int) {
+    f, ok := v.stats(term)
+    f.docFreq += docFreq
+    f.collFreq += collFreq
+    if !ok && f.docFreq > 0 {
+        v.size++
+    } else if ok && f.docFreq <= 0 {
+        v.size--
+        f = termFreqs{}
+    }
+    v.overlay[term] = f
+}
+
+// maybeRebuild merges the overlay into a new dictionary if it grew too large.
+func (v *vocabulary) maybeRebuild() {
+    if len(v.overlay) > minVocabularyOverlay && len(v.overlay) > v.dict.numTerms/8 {
+        v.rebuild()
+    }
+}
+
+// rebuild merges the overlay into a new dictionary.
+func (v *vocabulary) rebuild() {
+    terms := make([]string, 0, v.size)
+    docFreqs := make([]uint32, 0, v.size)
+    collFreqs := make([]uint64, 0, v.size)
+    v.forRange("", "", func(term string, f termFreqs) bool {
+        terms = append(terms, term)
+        docFreqs = append(docFreqs, uint32(f.docFreq))
+        collFreqs = append(collFreqs, uint64(f.collFreq))
+        return true
+    })
+    v.dict = newTermDict(terms)
+    v.docFreqs = docFreqs
+    v.collFreqs = collFreqs
+    v.overlay = make(map[string]termFreqs)
+}
+
+// forRange calls fn, in order, for every term of the vocabulary from lo up to
+// but excluding hi, until fn returns false. An empty hi has no upper bound.
+func (v *vocabulary) forRange(lo, hi string, fn func(term string, f termFreqs) bool) {
+    var changed []string
+    for term := range v.overlay {
+        if term >= lo && (hi == "" || term < hi) {
+            changed = append(changed, term)
+        }
+    }
+    sort.Strings(changed)
+
+    it := v.dict.seek(lo)
+    for {
+        inDict := it.valid() && (hi == "" || string(it.term) < hi)
+        if !inDict && len(changed) == 0 {
+            return
+        }
+
+        // Take the smaller of the next dictionary term and the next changed
+        // term; the overlay holds the current statistics of a changed term.
+        var term string
+        var f termFreqs
+        if len(changed) > 0 && (!inDict || changed[0] <= string(it.term)) {
+            term, f = changed[0], v.overlay[changed[0]]
+            if inDict && changed[0] == string(it.term) {
+                it.next()
+            }
+            changed = changed[1:]
+        } else {
+            term = string(it.term)
+            f = termFreqs{docFreq: int(v.docFreqs[it.ord]), collFreq: int(v.collFreqs[it.ord])}
+            it.next()
+        }
+        if f.docFreq > 0 && !fn(term, f) {
+            return
+        }
+    }
+}
+
+// prefixEnd returns the smallest string greater than every string starting
+// with prefix, or the empty string if there is none.
+func prefixEnd(prefix string) string {
+    end := []byte(prefix)
+    for len(end) > 0 && end[len(end)-1] == 0xff {
+        end = end[:len(end)-1]
+    }
+    if len(end) == 0 {
+        return ""
+    }
+    end[len(end)-1]++
+    return string(end)
+}
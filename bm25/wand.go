@@ -0,0 +1,395 @@
+package bm25
+
+import (
+    "context"
+    "errors"
+    "math"
+    "sort"
+)
+
+// boundSlack widens score upper bounds by a relative margin so that rounding
+// differences between summing bounds and summing actual term scores never
+// cause a competitive document to be skipped.
+const boundSlack = 1e-9
+
+// computeMaxScores records the scorer of the variant and computes, for every
+// term of every segment, the highest score the term contributes to any
+// document of the segment, both over the whole posting list and within each of
+// its blocks. These upper bounds drive the dynamic pruning of the top-N
+// evaluators.
+func (b *bm25Base) computeMaxScores(s termScorer) {
+    b.scorer = s
+    for _, seg := range b.segmentsSnapshot() {
+        seg.forEachPostingList(func(term string, p *postingList) {
+            b.termBounds(seg, term)
+        })
+    }
+}
+
+// termBounds returns the maximum score of the term over its posting list in
+// the segment and within each block, computing and caching them if the index
+// changed since they were last computed. The maximum is -Inf if none of the
+// postings belongs to a live document. It reports false if the term cannot be
+// scored.
+func (b *bm25Base) termBounds(seg *segment, term string) (float64, []float64, bool) {
+    seg.boundsMu.RLock()
+    blockMax, ok := seg.blockMaxScores[term]
+    maxScore := seg.maxScores[term]
+    seg.boundsMu.RUnlock()
+    if ok {
+        return maxScore, blockMax, true
+    }
+
+    p, ok := seg.postingList(term)
+    if !ok {
+        return 0, nil, false
+    }
+
+    weight, err := b.scorer.termWeight(term)
+    if err != nil {
+        return 0, nil, false
+    }
+
+    // Concurrent queries may compute the same bounds; they agree, so the last
+    // one stored wins.
+    blockMax = make([]float64, p.numBlocks())
+    maxScore = math.Inf(-1)
+    for blk := range blockMax {
+        blockMax[blk] = math.Inf(-1)
+    }
+    for it := p.iterator(); it.valid(); it.next() {
+        if b.isDeleted(it.doc()) {
+            continue
+        }
+        if w := weight(float64(it.freq()), b.docLengths.at(it.doc())); w > blockMax[it.block()] {
+            blockMax[it.block()] = w
+        }
+    }
+    for _, blkMax := range blockMax {
+        if blkMax > maxScore {
+            maxScore = blkMax
+        }
+    }
+    seg.boundsMu.Lock()
+    seg.maxScores[term] = maxScore
+    seg.blockMaxScores[term] = blockMax
+    seg.boundsMu.Unlock()
+
+    return maxScore, blockMax, true
+}
+
+// MaxScore returns the highest score the given term contributes to any single
+// document, or 0 if the term is not in the vocabulary. For BM25Plus it leaves
+// out the delta that every document gets.
+func (b *bm25Base) MaxScore(term string) float64 {
+    if _, _, ok := b.termStats(term); !ok {
+        return 0
+    }
+
+    maxScore := math.Inf(-1)
+    for _, seg := range b.segmentsSnapshot() {
+        if segMax, _, ok := b.termBounds(seg, term); ok && segMax > maxScore {
+            maxScore = segMax
+        }
+    }
+    return maxScore
+}
+
+// wandCursor walks the postings of one query term, stepping over the
+// postings of deleted documents.
+type wandCursor struct {
+    postings postingIterator
+    deleted  bitmap
+    weight   func(tf float64, docLen int) float64
+    maxScore float64
+    blockMax []float64
+}
+
+// doc returns the current document of the cursor, or -1 once it is exhausted.
+func (c *wandCursor) doc() int {
+    if !c.postings.valid() {
+        return -1
+    }
+    return c.postings.doc()
+}
+
+// skipDeleted moves the cursor past the postings of deleted documents.
+func (c *wandCursor) skipDeleted() {
+    for c.postings.valid() && c.deleted.has(c.postings.doc()) {
+        c.postings.next()
+    }
+}
+
+// next moves the cursor to the next posting.
+func (c *wandCursor) next() {
+    c.postings.next()
+    c.skipDeleted()
+}
+
+// skipTo moves the cursor to the first posting with a document ID of at
+// least target.
+func (c *wandCursor) skipTo(target int) {
+    c.postings.advance(target)
+    c.skipDeleted()
+}
+
+// score returns the score of the cursor's current posting.
+func (c *wandCursor) score(docLengths docLengthTable) float64 {
+    return c.weight(float64(c.postings.freq()), docLengths.at(c.postings.doc()))
+}
+
+// sortCursors orders the cursors by their current document. Only the cursors
+// moved since the last call are out of place, so an insertion sort is cheap.
+func sortCursors(cursors []*wandCursor) {
+    for i := 1; i < len(cursors); i++ {
+        for j := i; j > 0 && cursors[j].doc() < cursors[j-1].doc(); j-- {
+            cursors[j], cursors[j-1] = cursors[j-1], cursors[j]
+        }
+    }
+}
+
+// queryWeights returns the weighting function of every query term, or nil
+// for the terms that are not in the vocabulary or cannot be scored.
+func (b *bm25Base) queryWeights(query []string, s termScorer) []func(tf float64, docLen int) float64 {
+    weights := make([]func(tf float64, docLen int) float64, len(query))
+    for i, q := range query {
+        if _, _, ok := b.termStats(q); ok {
+            weights[i] = b.lookupWeight(s, q)
+        }
+    }
+    return weights
+}
+
+// queryCursors returns a cursor over the segment for every query term with
+// live postings in it, in query order. Repeated query terms get one cursor per
+// occurrence, as exhaustive scoring counts them once per occurrence too. It
+// reports false if a term has a negative weight, as dynamic pruning relies on
+// term scores being non-negative.
+func (b *bm25Base) queryCursors(seg *segment, query []string, weights []func(tf float64, docLen int) float64) ([]*wandCursor, bool) {
+    cursors := make([]*wandCursor, 0, len(query))
+    for i, q := range query {
+        if weights[i] == nil {
+            continue
+        }
+
+        p, ok := seg.postingList(q)
+        if !ok {
+            continue
+        }
+
+        maxScore, blockMax, ok := b.termBounds(seg, q)
+        if !ok || math.IsInf(maxScore, -1) {
+            continue
+        }
+        if maxScore < 0 {
+            return nil, false
+        }
+
+        c := &wandCursor{
+            postings: p.iterator(),
+            deleted:  b.deleted,
+            weight:   weights[i],
+            maxScore: maxScore,
+            blockMax: blockMax,
+        }
+        c.skipDeleted()
+        cursors = append(cursors, c)
+    }
+
+    return cursors, true
+}
+
+// scoreDoc fully scores the document the given cursors are positioned on and
+// advances them past it. The score is accumulated in query order, exactly as
+// in exhaustive scoring, so that both produce identical results.
+func (b *bm25Base) scoreDoc(cursors []*wandCursor, docID int) float64 {
+    var score float64
+    for _, c := range cursors {
+        if c.doc() == docID {
+            score += c.score(b.docLengths)
+            c.next()
+        }
+    }
+    return score
+}
+
+// liveCursors drops the exhausted cursors and sorts the others by their
+// current document.
+func liveCursors(cursors []*wandCursor) []*wandCursor {
+    live := cursors[:0]
+    for _, c := range cursors {
+        if c.doc() >= 0 {
+            live = append(live, c)
+        }
+    }
+    sortCursors(live)
+    return live
+}
+
+// competitive reports whether a document whose score is bounded by bound can
+// enter the heap. Documents are evaluated in increasing order, so a tie with
+// the current worst document always loses.
+func competitive(h *topKHeap, bound float64) bool {
+    return !h.full() || bound*(1+boundSlack) > h.worst().score
+}
+
+// wand collects the best documents of a segment into the heap using the WAND
+// algorithm (Broder et al., "Efficient Query Evaluation using a Two-Level
+// Retrieval Process"). Documents are only fully scored once the sum of the
+// upper bounds of the terms they may contain can beat the current n-th best
+// score.
+func (b *bm25Base) wand(cursors []*wandCursor, h *topKHeap, cc *canceller) error {
+    // active holds the cursors that are not yet exhausted, sorted by their
+    // current document. cursors keeps the query order used for scoring.
+    active := append([]*wandCursor(nil), cursors...)
+    for {
+        if err := cc.step(); err != nil {
+            return err
+        }
+        active = liveCursors(active)
+        if len(active) == 0 {
+            break
+        }
+
+        pivot := findPivot(active, h)
+        if pivot < 0 {
+            break
+        }
+
+        pivotDoc := active[pivot].doc()
+        if active[0].doc() == pivotDoc {
+            h.offer(pivotDoc, b.scoreDoc(cursors, pivotDoc))
+            continue
+        }
+
+        for _, c := range active[:pivot] {
+            c.skipTo(pivotDoc)
+        }
+    }
+    return nil
+}
+
+// findPivot returns the index of the first cursor at which the accumulated
+// upper bounds of the sorted cursors could beat the current threshold, or -1
+// if no remaining document can enter the heap.
+func findPivot(active []*wandCursor, h *topKHeap) int {
+    var bound float64
+    for i, c := range active {
+        bound += c.maxScore
+        if competitive(h, bound) {
+            return i
+        }
+    }
+    return -1
+}
+
+// topDocs returns the n best documents for the query, as TopNIndices or, with
+// skipZero, TopNNonZeroIndices would rank the exhaustive scores, and adds the
+// query floor to their scores. Documents are ranked, and with skipZero left
+// out, by the scores of their postings only; the floor is the same for every
+// document.
+func (b *bm25Base) topDocs(ctx context.Context, query []string, n int, s termScorer, skipZero bool) ([]scoredDoc, error) {
+    docs, err := b.rankQuery(ctx, query, n, s, skipZero)
+    if err != nil {
+        return nil, err
+    }
+
+    if floor := b.queryFloor(query, s); floor != 0 {
+        for i := range docs {
+            docs[i].score += floor
+        }
+    }
+
+    return docs, nil
+}
+
+// rankQuery returns the n best documents for the query by the scores of their
+// postings. It uses the configured evaluator and falls back to exhaustive
+// scoring when the evaluator cannot guarantee the same result.
+func (b *bm25Base) rankQuery(ctx context.Context, query []string, n int, s termScorer, skipZero bool) ([]scoredDoc, error) {
+    if len(query) == 0 {
+        return nil, errors.New("query cannot be empty")
+    }
+
+    if n <= 0 {
+        return nil, errors.New("n must be a positive integer")
+    }
+
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    n = Min(n, b.corpusSize)
+    docs, ok, err := b.evaluate(ctx, query, n, s)
+    if err != nil {
+        return nil, err
+    }
+    if ok && skipZero {
+        // All scores are non-negative here, so the zero scores are the ones at
+        // the end of the ranking.
+        for len(docs) > 0 && docs[len(docs)-1].score == 0 {
+            docs = docs[:len(docs)-1]
+        }
+        return docs, nil
+    }
+
+    if ok && len(docs) == n && docs[n-1].score > 0 {
+        return docs, nil
+    }
+
+    if ok && len(docs) < n {
+        // Fewer than n documents match: the ranking is completed with
+        // non-matching live documents, which all score zero, in document order.
+        matched := make(map[int]bool, len(docs))
+        for _, d := range docs {
+            matched[d.docID] = true
+        }
+        for docID := 0; docID < b.maxDoc() && len(docs) < n+len(matched); docID++ {
+            if !matched[docID] && !b.isDeleted(docID) {
+                docs = append(docs, scoredDoc{docID: docID})
+            }
+        }
+        sort.Slice(docs, func(i, j int) bool {
+            return docs[i].ranksBefore(docs[j])
+        })
+        return docs[:n], nil
+    }
+
+    scores, err := b.scorePostings(ctx, query, s)
+    if err != nil {
+        return nil, err
+    }
+
+    return b.rankScores(scores, n, skipZero)
+}
+
+// topNDocuments returns the original text of the top N documents for the query.
+func (b *bm25Base) topNDocuments(ctx context.Context, query []string, n int, s termScorer) ([]string, error) {
+    docs, err := b.topDocs(ctx, query, n, s, false)
+    if err != nil {
+        return nil, err
+    }
+
+    topDocs := make([]string, len(docs))
+    for i, d := range docs {
+        topDocs[i] = b.document(d.docID)
+    }
+
+    return topDocs, nil
+}
+
+// search returns the top N hits for the query.
+func (b *bm25Base) search(ctx context.Context, query []string, n int, s termScorer) ([]Hit, error) {
+    docs, err := b.topDocs(ctx, query, n, s, true)
+    if err != nil {
+        return nil, err
+    }
+
+    hits := make([]Hit, len(docs))
+    for i, d := range docs {
+        hits[i] = Hit{DocID: d.docID, Score: d.score}
+        hits[i].ExternalID = b.externalID(d.docID)
+    }
+
+    return hits, nil
+}
@@ -0,0 +1,253 @@
+package bm25
+
+import (
+    "unicode"
+    "unicode/utf8"
+)
+
+// wordBreakProp is the Word_Break property of a character, as defined by
+// Unicode Standard Annex #29, "Unicode Text Segmentation".
+type wordBreakProp uint8
+
+const (
+    wbOther wordBreakProp = iota
+    wbCR
+    wbLF
+    wbNewline
+    wbExtend
+    wbZWJ
+    wbRegionalIndicator
+    wbFormat
+    wbKatakana
+    wbHebrewLetter
+    wbALetter
+    wbSingleQuote
+    wbDoubleQuote
+    wbMidNumLet
+    wbMidLetter
+    wbMidNum
+    wbNumeric
+    wbExtendNumLet
+    wbWSegSpace
+    // wbComplexContext is not a Word_Break value: it marks the letters of the
+    // scripts written without spaces, such as Thai, which UAX #29 leaves to
+    // dictionary-based segmentation. Runs of them are kept together.
+    wbComplexContext
+)
+
+// complexContextScripts are the scripts whose words UAX #29 does not
+// segment, Line_Break=SA.
+var complexContextScripts = []*unicode.RangeTable{
+    unicode.Thai, unicode.Lao, unicode.Myanmar, unicode.Khmer,
+    unicode.Tai_Le, unicode.New_Tai_Lue, unicode.Tai_Tham, unicode.Tai_Viet,
+}
+
+// wordBreakProperty returns the Word_Break property of the rune, derived from
+// the general categories, scripts and properties of the unicode package.
+func wordBreakProperty(r rune) wordBreakProp {
+    switch r {
+    case '\r':
+        return wbCR
+    case '\n':
+        return wbLF
+    case 0x0B, 0x0C, 0x85, 0x2028, 0x2029:
+        return wbNewline
+    case 0x200D:
+        return wbZWJ
+    case 0x200C:
+        return wbExtend
+    case '\'':
+        return wbSingleQuote
+    case '"':
+        return wbDoubleQuote
+    case '.', 0x2018, 0x2019, 0x2024, 0xFE52, 0xFF07, 0xFF0E:
+        return wbMidNumLet
+    case ':', 0xB7, 0x387, 0x55F, 0x5F4, 0x2027, 0xFE13, 0xFE55, 0xFF1A:
+        return wbMidLetter
+    case ',', ';', 0x37E, 0x589, 0x60C, 0x60D, 0x66C, 0x7F8, 0x2044, 0xFE10, 0xFE14, 0xFE50, 0xFE54, 0xFF0C, 0xFF1B:
+        return wbMidNum
+    case 0x202F:
+        return wbExtendNumLet
+    case 0x3031, 0x3032, 0x3033, 0x3034, 0x3035, 0x309B, 0x309C, 0x30A0, 0x30FC, 0xFF70:
+        return wbKatakana
+    }
+    if r < 0x80 {
+        // Fast path for ASCII, which covers most text.
+        switch {
+        case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+            return wbALetter
+        case r >= '0' && r <= '9':
+            return wbNumeric
+        case r == ' ':
+            return wbWSegSpace
+        case r == '_':
+            return wbExtendNumLet
+        }
+        return wbOther
+    }
+
+    switch {
+    case r >= 0x1F1E6 && r <= 0x1F1FF:
+        return wbRegionalIndicator
+    case r >= 0x1F3FB && r <= 0x1F3FF, unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc), r == 0xFF9E, r == 0xFF9F:
+        return wbExtend
+    case unicode.Is(unicode.Zs, r):
+        if r == 0xA0 || r == 0x2007 {
+            return wbOther
+        }
+        return wbWSegSpace
+    case unicode.Is(unicode.Cf, r):
+        if r == 0x200B {
+            return wbOther
+        }
+        return wbFormat
+    case unicode.Is(unicode.Katakana, r):
+        return wbKatakana
+    case unicode.Is(unicode.Nd, r):
+        return wbNumeric
+    case unicode.Is(unicode.Pc, r):
+        return wbExtendNumLet
+    case unicode.In(r, complexContextScripts...):
+        if unicode.IsLetter(r) {
+            return wbComplexContext
+        }
+        return wbOther
+    case unicode.In(r, unicode.Ideographic, unicode.Hiragana):
+        return wbOther
+    case unicode.Is(unicode.Hebrew, r) && unicode.Is(unicode.Lo, r):
+        return wbHebrewLetter
+    case unicode.IsLetter(r), unicode.Is(unicode.Nl, r), unicode.Is(unicode.Other_Alphabetic, r):
+        return wbALetter
+    }
+    return wbOther
+}
+
+// isExtendedPictographic approximates the Extended_Pictographic property with
+// the blocks that hold the emoji.
+func isExtendedPictographic(r rune) bool {
+    return (r >= 0x1F000 && r <= 0x1FAFF) || (r >= 0x2600 && r <= 0x27BF) || (r >= 0x2300 && r <= 0x23FF) ||
+        (r >= 0x2B00 && r <= 0x2BFF) || r == 0xA9 || r == 0xAE || r == 0x203C || r == 0x2049 || r == 0x2122 ||
+        r == 0x2139 || (r >= 0x2194 && r <= 0x21AA) || r == 0x3030 || r == 0x303D || r == 0x3297 || r == 0x3299
+}
+
+// isAHLetter reports whether the property is ALetter or Hebrew_Letter.
+func isAHLetter(p wordBreakProp) bool {
+    return p == wbALetter || p == wbHebrewLetter
+}
+
+// isMidNumLetQ reports whether the property is MidNumLet or Single_Quote.
+func isMidNumLetQ(p wordBreakProp) bool {
+    return p == wbMidNumLet || p == wbSingleQuote
+}
+
+// wordUnit is a character together with the Extend, Format and ZWJ
+// characters that follow it, which rule WB4 attaches to it.
+type wordUnit struct {
+    start, end int
+    prop       wordBreakProp
+    first      rune
+    lastZWJ    bool
+}
+
+// wordUnits splits the text into units for the word boundary rules.
+func wordUnits(text string) []wordUnit {
+    var units []wordUnit
+    for i := 0; i < len(text); {
+        r, size := utf8.DecodeRuneInString(text[i:])
+        prop := wordBreakProperty(r)
+        if n := len(units); n > 0 && (prop == wbExtend || prop == wbFormat || prop == wbZWJ) {
+            // WB4: ignore Extend, Format and ZWJ, except after sot, CR, LF
+            // and Newline.
+            if last := &units[n-1]; last.prop != wbCR && last.prop != wbLF && last.prop != wbNewline {
+                last.end = i + size
+                last.lastZWJ = prop == wbZWJ
+                i += size
+                continue
+            }
+        }
+        units = append(units, wordUnit{start: i, end: i + size, prop: prop, first: r, lastZWJ: prop == wbZWJ})
+        i += size
+    }
+    return units
+}
+
+// wordBreakBefore reports whether the word boundary rules of UAX #29 allow a
+// break between units[i-1] and units[i].
+func wordBreakBefore(units []wordUnit, i int) bool {
+    prev, cur := units[i-1].prop, units[i].prop
+    prev2, next := wbOther, wbOther
+    if i >= 2 {
+        prev2 = units[i-2].prop
+    }
+    if i+1 < len(units) {
+        next = units[i+1].prop
+    }
+
+    switch {
+    case prev == wbCR && cur == wbLF: // WB3
+        return false
+    case prev == wbCR || prev == wbLF || prev == wbNewline: // WB3a
+        return true
+    case cur == wbCR || cur == wbLF || cur == wbNewline: // WB3b
+        return true
+    case units[i-1].lastZWJ && isExtendedPictographic(units[i].first): // WB3c
+        return false
+    case prev == wbWSegSpace && cur == wbWSegSpace: // WB3d
+        return false
+    case isAHLetter(prev) && isAHLetter(cur): // WB5
+        return false
+    case isAHLetter(prev) && (cur == wbMidLetter || isMidNumLetQ(cur)) && isAHLetter(next): // WB6
+        return false
+    case isAHLetter(prev2) && (prev == wbMidLetter || isMidNumLetQ(prev)) && isAHLetter(cur): // WB7
+        return false
+    case prev == wbHebrewLetter && cur == wbSingleQuote: // WB7a
+        return false
+    case prev == wbHebrewLetter && cur == wbDoubleQuote && next == wbHebrewLetter: // WB7b
+        return false
+    case prev2 == wbHebrewLetter && prev == wbDoubleQuote && cur == wbHebrewLetter: // WB7c
+        return false
+    case prev == wbNumeric && cur == wbNumeric: // WB8
+        return false
+    case isAHLetter(prev) && cur == wbNumeric: // WB9
+        return false
+    case prev == wbNumeric && isAHLetter(cur): // WB10
+        return false
+    case prev2 == wbNumeric && (prev == wbMidNum || isMidNumLetQ(prev)) && cur == wbNumeric: // WB11
+        return false
+    case prev == wbNumeric && (cur == wbMidNum || isMidNumLetQ(cur)) && next == wbNumeric: // WB12
+        return false
+    case prev == wbKatakana && cur == wbKatakana: // WB13
+        return false
+    case (isAHLetter(prev) || prev == wbNumeric || prev == wbKatakana || prev == wbExtendNumLet) && cur == wbExtendNumLet: // WB13a
+        return false
+    case prev == wbExtendNumLet && (isAHLetter(cur) || cur == wbNumeric || cur == wbKatakana): // WB13b
+        return false
+    case prev == wbRegionalIndicator && cur == wbRegionalIndicator: // WB15, WB16
+        count := 0
+        for j := i - 1; j >= 0 && units[j].prop == wbRegionalIndicator; j-- {
+            count++
+        }
+        return count%2 == 0
+    case prev == wbComplexContext && cur == wbComplexContext:
+        return false
+    }
+    return true // WB999
+}
+
+// wordSegments calls fn with the byte offsets of every segment of the text
+// delimited by UAX #29 word boundaries, in order. Word segments alternate
+// with segments of spaces and punctuation.
+func wordSegments(text string, fn func(start, end int)) {
+    units := wordUnits(text)
+    if len(units) == 0 {
+        return
+    }
+    start := 0
+    for i := 1; i < len(units); i++ {
+        if wordBreakBefore(units, i) {
+            fn(start, units[i].start)
+            start = units[i].start
+        }
+    }
+    fn(start, len(text))
+}